@@ -118,7 +118,9 @@ func run() error {
 		CoreComponent.LogInfo("Starting API ... done")
 		CoreComponent.LogInfo("Starting API server ...")
 
-		_ = api.NewServer(deps.Collector, deps.Echo, deps.Collector.WrappedLogger, ctx)
+		if _, err := api.NewServer(deps.Collector, deps.Echo, *ParamsRestAPI, deps.Collector.WrappedLogger, ctx); err != nil {
+			CoreComponent.LogErrorfAndExit("Starting API server failed: %s", err)
+		}
 
 		go func() {
 			if err := deps.Echo.Start(ParamsRestAPI.BindAddress); err != nil && !errors.Is(err, http.ErrServerClosed) {
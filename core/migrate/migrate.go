@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/migration"
+	"collector/pkg/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+// Command is the subcommand running a migration instead of the plugin.
+const Command = "migrate"
+
+// progressInterval is how often the progress of the migration is printed.
+const progressInterval = 10 * time.Second
+
+// Run migrates the objects of a bucket as described by the command line arguments, until it is done or interrupted.
+func Run(args []string) error {
+	flags := flag.NewFlagSet(Command, flag.ContinueOnError)
+	var source, target storage.Parameters
+	storageFlags(flags, "source", &source)
+	storageFlags(flags, "target", &target)
+
+	var shared storage.Parameters
+	flags.StringVar(&shared.Encryption.MasterKeyFile, "masterKeyFile", "", "the file holding the master keys of the encrypted buckets")
+	flags.StringVar(&shared.ObjectExtension, "objectExtension", "", "the file extension of the objects inside the storages")
+	flags.StringVar(&shared.InstanceId, "instanceId", "", "the id of the collector recorded in the migrated objects, defaults to the host name")
+
+	var options migration.Options
+	flags.StringVar(&options.SourceBucket, "sourceBucket", "", "the bucket the objects are migrated from")
+	flags.StringVar(&options.TargetBucket, "targetBucket", "", "the bucket the objects are migrated to, created if needed")
+	flags.BoolVar(&options.Move, "move", false, "whether the objects are deleted from the source bucket once migrated")
+	flags.StringVar(&options.Format, "format", "", "the serialization of the objects in the target bucket (json, binary)")
+	flags.StringVar(&options.Compression, "compression", "", "the compression of the objects in the target bucket (gzip, zstd)")
+	flags.StringVar(&options.KeyLayout, "keyLayout", "", "the key layout of the objects in the target bucket")
+	flags.StringVar(&options.StartAfter, "startAfter", "", "the key after which an interrupted migration is resumed")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if options.SourceBucket == "" || options.TargetBucket == "" {
+		return errors.New("both -sourceBucket and -targetBucket are required")
+	}
+	// without a target backend the objects are migrated within the source storage
+	sameStorage := target.Type == ""
+	if sameStorage && options.SourceBucket == options.TargetBucket {
+		return errors.New("the objects can't be migrated to the bucket they are in")
+	}
+	if target.Type == storage.BackendMemory {
+		return storage.ErrVolatileTarget
+	}
+
+	rootLogger, err := logger.NewRootLogger(logger.Config{
+		Level:           "info",
+		DisableCaller:   true,
+		StacktraceLevel: "panic",
+		Encoding:        "console",
+		OutputPaths:     []string{"stdout"},
+		DisableEvents:   true,
+	})
+	if err != nil {
+		return err
+	}
+	log := logger.NewWrappedLogger(rootLogger.Named("Migration"))
+
+	sourceStorage, err := newStorage(source, shared, log)
+	if err != nil {
+		return fmt.Errorf("can't open source storage, error: %w", err)
+	}
+	defer sourceStorage.Close()
+	targetStorage := sourceStorage
+	if !sameStorage {
+		targetStorage, err = newStorage(target, shared, log)
+		if err != nil {
+			return fmt.Errorf("can't open target storage, error: %w", err)
+		}
+		defer targetStorage.Close()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := migration.Configure(targetStorage, options, ctx); err != nil {
+		return err
+	}
+
+	job := jobs.NewManager(log).Start(migration.JobMigration, options.SourceBucket, "", func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+		return migration.Run(sourceStorage, targetStorage, options, job, ctx)
+	}, ctx)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-job.Done():
+			done = true
+		case <-ticker.C:
+			status := job.Status()
+			log.LogInfof("Migrated %d/%d objects of bucket '%s'", status.Processed, status.Total, options.SourceBucket)
+		}
+	}
+
+	status := job.Status()
+	report, err := json.MarshalIndent(status.Result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(report))
+
+	switch status.State {
+	case jobs.StateCanceled:
+		return errors.New("migration interrupted, run it again with -startAfter set to the resumeAfter key of the report")
+	case jobs.StateFailed:
+		return errors.New(status.Error)
+	default:
+		return nil
+	}
+}
+
+// storageFlags registers the flags describing a storage backend, prefixed with the role of the storage.
+func storageFlags(flags *flag.FlagSet, prefix string, params *storage.Parameters) {
+	flags.StringVar(&params.Type, prefix+".type", "", "the "+prefix+" storage backend type (s3, filesystem)")
+	flags.StringVar(&params.Endpoint, prefix+".endpoint", "", "the "+prefix+" storage endpoint")
+	flags.StringVar(&params.AccessKeyID, prefix+".accessKeyID", "", "the access id for the "+prefix+" storage")
+	flags.StringVar(&params.SecretAccessKey, prefix+".secretAccessKey", "", "the password for the given access id of the "+prefix+" storage")
+	flags.StringVar(&params.Region, prefix+".region", "eu-south-1", "the region of the "+prefix+" S3 storage")
+	flags.BoolVar(&params.Secure, prefix+".secure", true, "whether the connection to the "+prefix+" storage should be secure")
+	flags.StringVar(&params.Filesystem.Path, prefix+".path", "storage", "the directory of the "+prefix+" filesystem storage")
+}
+
+func newStorage(params storage.Parameters, shared storage.Parameters, log *logger.WrappedLogger) (*storage.Storage, error) {
+	params.Encryption.MasterKeyFile = shared.Encryption.MasterKeyFile
+	params.ObjectExtension = shared.ObjectExtension
+	params.InstanceId = shared.InstanceId
+	if params.Type == "" {
+		params.Type = storage.BackendS3
+	}
+	return storage.NewStorage(params, log)
+}
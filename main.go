@@ -1,7 +1,22 @@
 package main
 
-import "collector/core/app"
+import (
+	"fmt"
+	"os"
+
+	"collector/core/app"
+	"collector/core/migrate"
+)
 
 func main() {
+	// the migrate subcommand runs a migration of the stored objects instead of the plugin
+	if len(os.Args) > 1 && os.Args[1] == migrate.Command {
+		if err := migrate.Run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed, error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	app.App().Run()
 }
@@ -10,4 +10,10 @@ type Parameters struct {
 
 	// DebugRequestLoggerEnabled defines whether the debug logging for requests should be enabled
 	DebugRequestLoggerEnabled bool `default:"false" usage:"whether the debug logging for requests should be enabled"`
+
+	// UsersFile defines the file holding the users owning private buckets and the hashes of their tokens
+	UsersFile string `default:"" usage:"the file holding the users owning private buckets and the hashes of their tokens, empty disables private buckets"`
+
+	// MigrationTargetsFile defines the file holding the storages the objects can be migrated to, by name
+	MigrationTargetsFile string `default:"" usage:"the file holding the storages the objects can be migrated to, by name, empty only allows migrations within the storage of the collector"`
 }
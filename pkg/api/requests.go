@@ -1,6 +1,9 @@
 package api
 
 import (
+	"collector/pkg/listener"
+	"collector/pkg/migration"
+	"collector/pkg/storage"
 	"encoding/json"
 	"io"
 	"strconv"
@@ -11,7 +14,7 @@ import (
 )
 
 type RequestConstraint interface {
-	RequestSubscribeBody | RequestStoreBody | RequestCreateBucket
+	RequestSubscribeBody | RequestStoreBody | RequestCreateBucket | RequestUpdateBucket | RequestSetLifecycle | RequestLegalHold | RequestMigration | RequestBackfill | RequestBackfillAdHoc
 }
 
 type RequestSubscribeBody struct {
@@ -20,6 +23,9 @@ type RequestSubscribeBody struct {
 	Duration   string `json:"duration"`
 	BucketName string `json:"bucketName"`
 	WithPOI    bool   `json:"withPOI"`
+	KeyLayout  string `json:"keyLayout"`
+	// RetentionDays sets how long the objects stored by the filter are kept, 0 leaves them to the lifecycle of the bucket
+	RetentionDays int `json:"retentionDays" validate:"min=0"`
 }
 
 type RequestStoreBody struct {
@@ -31,6 +37,46 @@ type RequestStoreBody struct {
 type RequestCreateBucket struct {
 	BucketName    string `json:"bucketName" validate:"required"`
 	LifecycleDays int    `json:"days"`
+	Compression   string `json:"compression"`
+	Encryption    bool   `json:"encryption"`
+	KeyLayout     string `json:"keyLayout"`
+	Format        string `json:"format"`
+	// ObjectLock creates a write-once-read-many bucket, with the optional default retention of its objects
+	ObjectLock bool              `json:"objectLock"`
+	Retention  storage.Retention `json:"retention"`
+	Quota      storage.Quota     `json:"quota"`
+}
+
+type RequestUpdateBucket struct {
+	LifecycleDays *int           `json:"days" validate:"omitempty,min=0"`
+	Quota         *storage.Quota `json:"quota"`
+}
+
+type RequestSetLifecycle struct {
+	Rules []storage.LifecycleRule `json:"rules" validate:"dive"`
+}
+
+type RequestLegalHold struct {
+	Hold *bool `json:"hold" validate:"required"`
+}
+
+type RequestMigration struct {
+	migration.Options
+	// TargetStorage is the name of the migration target the objects are migrated to, the storage of the collector if empty
+	TargetStorage string `json:"targetStorage"`
+}
+
+type RequestBackfill struct {
+	listener.MilestoneRange
+}
+
+type RequestBackfillAdHoc struct {
+	listener.MilestoneRange
+	Tag        string `json:"tag" validate:"required"`
+	PublicKey  string `json:"publicKey"`
+	BucketName string `json:"bucketName"`
+	WithPOI    bool   `json:"withPOI"`
+	KeyLayout  string `json:"keyLayout"`
 }
 
 type ObjectParams struct {
@@ -56,18 +102,14 @@ func extractRequestBody[Request RequestConstraint](request *Request, c echo.Cont
 	return nil
 }
 
-func (s Server) parseObjectInput(c echo.Context) (ObjectParams, error) {
+func (s *Server) parseObjectInput(c echo.Context) (ObjectParams, error) {
 	var params ObjectParams
 	params.BlockId = strings.ToLower(c.Param(ParameterBlockID))
-	params.BucketName = s.Collector.Storage.DefaultBucketName
 
 	err := c.Request().ParseForm()
 	if err != nil {
 		return params, err
 	}
-	if c.Request().Form.Has(ParameterBucketName) {
-		params.BucketName = c.QueryParam(ParameterBucketName)
-	}
 	if c.Request().Form.Has(ParameterWithPOI) {
 		params.WithPOI, err = strconv.ParseBool(c.QueryParam(ParameterWithPOI))
 		if err != nil {
@@ -75,5 +117,14 @@ func (s Server) parseObjectInput(c echo.Context) (ObjectParams, error) {
 		}
 	}
 
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return params, err
+	}
+	params.BucketName, err = s.resolveBucket(c.QueryParam(ParameterBucketName), userId)
+	if err != nil {
+		return params, err
+	}
+
 	return params, nil
 }
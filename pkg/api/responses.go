@@ -0,0 +1,23 @@
+package api
+
+import (
+	"collector/pkg/storage"
+	"time"
+)
+
+type ResponseBucket struct {
+	Name         string    `json:"name"`
+	CreationDate time.Time `json:"creationDate"`
+}
+
+type ResponseBucketDetails struct {
+	Name          string                  `json:"name"`
+	LifecycleDays int                     `json:"days"`
+	Lifecycle     []storage.LifecycleRule `json:"lifecycle"`
+	ObjectCount   int                     `json:"objectCount"`
+	TotalSize     int64                   `json:"totalSize"`
+	Config        storage.BucketConfig    `json:"config"`
+	ObjectLock    bool                    `json:"objectLock"`
+	Retention     storage.Retention       `json:"retention"`
+	Filters       []string                `json:"filters"`
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"collector/pkg/jobs"
 	"collector/pkg/listener"
 	"collector/pkg/storage"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"strings"
 
@@ -29,13 +32,42 @@ const (
 	ParameterFilterId = "filterId"
 	// ParameterLifecycleDays is used to express the number of days before data expiration in the bucket.
 	ParameterLifecycleDays = "days"
-
-	RouteGetBlock     = "/block/:" + ParameterBlockID
-	RouteDeleteBlock  = "/block/:" + ParameterBlockID
-	RouteStore        = "/block"
-	RouteSubscribe    = "/filter"
-	RouteUnsubscribe  = "/filter/:" + ParameterFilterId
-	RouteCreateBucket = "/bucket"
+	// ParameterRepair is used to identify wether a scrub should repair the damaged objects.
+	ParameterRepair = "repair"
+	// ParameterJobId is used to identify the job id
+	ParameterJobId = "jobId"
+	// ParameterExpiry is used to express how long a presigned URL is valid.
+	ParameterExpiry = "expiry"
+
+	// defaultPresignExpiry is the validity of the presigned URLs when no expiry is requested.
+	defaultPresignExpiry = 15 * time.Minute
+
+	RouteGetBlock       = "/block/:" + ParameterBlockID
+	RouteDeleteBlock    = "/block/:" + ParameterBlockID
+	RouteStore          = "/block"
+	RouteGetLock        = "/block/:" + ParameterBlockID + "/lock"
+	RoutePresignBlock   = "/block/:" + ParameterBlockID + "/url"
+	RouteSetLegalHold   = "/block/:" + ParameterBlockID + "/legalhold"
+	RouteSubscribe      = "/filter"
+	RouteUnsubscribe    = "/filter/:" + ParameterFilterId
+	RouteCreateBucket   = "/bucket"
+	RouteListBuckets    = "/bucket"
+	RouteGetBucket      = "/bucket/:" + ParameterBucketName
+	RouteUpdateBucket   = "/bucket/:" + ParameterBucketName
+	RouteDeleteBucket   = "/bucket/:" + ParameterBucketName
+	RouteGetLifecycle   = "/bucket/:" + ParameterBucketName + "/lifecycle"
+	RouteSetLifecycle   = "/bucket/:" + ParameterBucketName + "/lifecycle"
+	RouteRotateKey      = "/encryption/rotate"
+	RouteRebuildCatalog = "/catalog/rebuild"
+	RouteGetSpool       = "/spool"
+	RouteGetListener    = "/listener"
+	RouteScrubBucket    = "/bucket/:" + ParameterBucketName + "/scrub"
+	RouteListJobs       = "/job"
+	RouteGetJob         = "/job/:" + ParameterJobId
+	RouteCancelJob      = "/job/:" + ParameterJobId
+	RouteMigrate        = "/migration"
+	RouteBackfill       = "/filter/:" + ParameterFilterId + "/backfill"
+	RouteBackfillAdHoc  = "/filter/backfill"
 )
 
 func (s *Server) setupRoutes(e *echo.Echo) {
@@ -46,7 +78,7 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 
 		params, err := s.parseObjectInput(c)
 		if err != nil {
-			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
 		}
 		if params.WithPOI {
 			resp, err := s.getBlockWithPOI(params.BlockId, params.BucketName, c)
@@ -62,14 +94,28 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 		}
 		return httpserver.JSONResponse(c, http.StatusOK, &resp)
 	})
+	e.GET(RoutePresignBlock, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RoutePresignBlock)
+		defer s.apiLogEnd(RoutePresignBlock, err)
+
+		resp, err := s.presignBlockFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, presignErrorStatus(err), fmt.Sprintf("could not presign block, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
 	e.POST(RouteStore, func(c echo.Context) error {
 		var err error
 		s.apiLogStart(RouteStore)
 		defer s.apiLogEnd(RouteStore, err)
 
 		blockId, bucketName, err := s.storeBlockFromTangle(c)
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			return httpserver.JSONResponse(c, http.StatusInsufficientStorage, fmt.Sprintf("%v", err))
+		}
 		if err != nil {
-			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
 		}
 		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Block '%s' uploaded to bucket '%s'", blockId, bucketName))
 	})
@@ -80,7 +126,7 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 
 		filterId, tag, err := s.subscribeToTag(c)
 		if err != nil {
-			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
 		}
 		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Subscription to '%s' started, id is: '%s'", tag, filterId))
 	})
@@ -91,10 +137,233 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 
 		bucketName, err := s.createBucketFromRequest(c)
 		if err != nil {
-			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("could not create bucket, error: %v", err))
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not create bucket, error: %v", err))
 		}
 		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Bucket '%s' created", bucketName))
 	})
+	e.GET(RouteListBuckets, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteListBuckets)
+		defer s.apiLogEnd(RouteListBuckets, err)
+
+		resp, err := s.listBucketsFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not list buckets, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.GET(RouteGetBucket, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetBucket)
+		defer s.apiLogEnd(RouteGetBucket, err)
+
+		resp, err := s.getBucketDetailsFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not retrieve bucket, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.PUT(RouteUpdateBucket, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteUpdateBucket)
+		defer s.apiLogEnd(RouteUpdateBucket, err)
+
+		bucketName, err := s.updateBucketFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not update bucket, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Bucket '%s' updated", bucketName))
+	})
+	e.DELETE(RouteDeleteBucket, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteDeleteBucket)
+		defer s.apiLogEnd(RouteDeleteBucket, err)
+
+		bucketName := c.Param(ParameterBucketName)
+		err = s.deleteBucketFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not delete bucket, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Bucket '%s' deleted", bucketName))
+	})
+	e.GET(RouteGetLifecycle, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetLifecycle)
+		defer s.apiLogEnd(RouteGetLifecycle, err)
+
+		resp, err := s.getBucketLifecycleFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not retrieve lifecycle, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.PUT(RouteSetLifecycle, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteSetLifecycle)
+		defer s.apiLogEnd(RouteSetLifecycle, err)
+
+		bucketName, rules, err := s.setBucketLifecycleFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not set lifecycle, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Lifecycle of bucket '%s' set, %d rules applied", bucketName, rules))
+	})
+	e.GET(RouteGetSpool, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetSpool)
+		defer s.apiLogEnd(RouteGetSpool, err)
+
+		resp, err := s.Collector.Storage.SpoolStats()
+		if err != nil {
+			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("could not retrieve spool, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.GET(RouteGetListener, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetListener)
+		defer s.apiLogEnd(RouteGetListener, err)
+
+		resp := s.Collector.ListenerStatus()
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.POST(RouteScrubBucket, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteScrubBucket)
+		defer s.apiLogEnd(RouteScrubBucket, err)
+
+		bucketName := c.Param(ParameterBucketName)
+		jobId, err := s.scrubBucketFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not scrub bucket, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Scrub of bucket '%s' started, job id is: '%s'", bucketName, jobId))
+	})
+	e.POST(RouteMigrate, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteMigrate)
+		defer s.apiLogEnd(RouteMigrate, err)
+
+		bucketName, jobId, err := s.migrateFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not migrate bucket, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Migration of bucket '%s' started, job id is: '%s'", bucketName, jobId))
+	})
+	e.POST(RouteBackfill, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteBackfill)
+		defer s.apiLogEnd(RouteBackfill, err)
+
+		filterId, jobId, err := s.backfillFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not backfill filter, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Backfill of filter '%s' started, job id is: '%s'", filterId, jobId))
+	})
+	e.POST(RouteBackfillAdHoc, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteBackfillAdHoc)
+		defer s.apiLogEnd(RouteBackfillAdHoc, err)
+
+		tag, jobId, err := s.backfillAdHocFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not backfill tag, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Backfill of tag '%s' started, job id is: '%s'", tag, jobId))
+	})
+	e.GET(RouteListJobs, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteListJobs)
+		defer s.apiLogEnd(RouteListJobs, err)
+
+		resp, err := s.jobsFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not list jobs, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.GET(RouteGetJob, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetJob)
+		defer s.apiLogEnd(RouteGetJob, err)
+
+		resp, err := s.jobFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not retrieve job, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.DELETE(RouteCancelJob, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteCancelJob)
+		defer s.apiLogEnd(RouteCancelJob, err)
+
+		jobId := c.Param(ParameterJobId)
+		_, err = s.jobFromRequest(c)
+		if err == nil {
+			err = s.Collector.Jobs.Cancel(jobId)
+		}
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not cancel job, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Job '%s' canceled", jobId))
+	})
+	e.POST(RouteRotateKey, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteRotateKey)
+		defer s.apiLogEnd(RouteRotateKey, err)
+
+		if err = s.checkAdmin(c); err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not rotate master key, error: %v", err))
+		}
+		rewrapped, err := s.Collector.Storage.RotateMasterKey(s.Context)
+		if err != nil {
+			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("could not rotate master key, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Master key rotated, %d data keys re-wrapped", rewrapped))
+	})
+	e.POST(RouteRebuildCatalog, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteRebuildCatalog)
+		defer s.apiLogEnd(RouteRebuildCatalog, err)
+
+		if err = s.checkAdmin(c); err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not rebuild catalog, error: %v", err))
+		}
+		recorded, err := s.Collector.Storage.RebuildCatalog(s.Context)
+		if err != nil {
+			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("could not rebuild catalog, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Catalog rebuilt, %d objects recorded", recorded))
+	})
+	e.GET(RouteGetLock, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteGetLock)
+		defer s.apiLogEnd(RouteGetLock, err)
+
+		params, err := s.parseObjectInput(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
+		}
+
+		resp, err := s.Collector.Storage.GetObjectLock(params.BucketName, params.BlockId, s.Context)
+		if err != nil {
+			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, &resp)
+	})
+	e.PUT(RouteSetLegalHold, func(c echo.Context) error {
+		var err error
+		s.apiLogStart(RouteSetLegalHold)
+		defer s.apiLogEnd(RouteSetLegalHold, err)
+
+		params, hold, err := s.setLegalHoldFromRequest(c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("could not set legal hold, error: %v", err))
+		}
+		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Legal hold of object '%s' in bucket '%s' set to %t", params.BlockId, params.BucketName, hold))
+	})
 	e.DELETE(RouteDeleteBlock, func(c echo.Context) error {
 		var err error
 		s.apiLogStart(RouteDeleteBlock)
@@ -102,10 +371,13 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 
 		params, err := s.parseObjectInput(c)
 		if err != nil {
-			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
 		}
 
 		err = s.Collector.Storage.DeleteObject(params.BucketName, params.BlockId, s.Context)
+		if errors.Is(err, storage.ErrObjectLocked) {
+			return httpserver.JSONResponse(c, http.StatusForbidden, fmt.Sprintf("%v", err))
+		}
 		if err != nil {
 			return httpserver.JSONResponse(c, http.StatusBadRequest, fmt.Sprintf("%v", err))
 		}
@@ -118,7 +390,10 @@ func (s *Server) setupRoutes(e *echo.Echo) {
 		defer s.apiLogEnd(RouteUnsubscribe, err)
 
 		filterId := strings.ToLower(c.Param(ParameterFilterId))
-		s.Collector.Listener.RemoveFilter(filterId)
+		err = s.unsubscribeFromRequest(filterId, c)
+		if err != nil {
+			return httpserver.JSONResponse(c, bucketErrorStatus(err), fmt.Sprintf("%v", err))
+		}
 
 		return httpserver.JSONResponse(c, http.StatusOK, fmt.Sprintf("Subscription with id '%s' has stopped", filterId))
 	})
@@ -146,18 +421,64 @@ func (s *Server) getBlockWithPOI(blockId string, bucketName string, c echo.Conte
 
 func (s *Server) getObjectFromStorage(blockId string, bucketName string) (storage.Object, error) {
 	var object storage.Object
-	resp, err := s.Collector.Storage.GetObject(bucketName, blockId, s.Context)
+	resp, format, err := s.Collector.Storage.GetObject(bucketName, blockId, s.Context)
 	if err != nil {
 		return object, err
 	}
+	defer resp.Close()
 
-	err = json.NewDecoder(resp).Decode(&object)
+	// the object is returned the same way whether the bucket stores it as JSON or in the binary format
+	object, err = storage.DecodeObject(resp, format)
 	if err != nil {
 		return object, err
 	}
 	return object, nil
 }
 
+func (s *Server) presignBlockFromRequest(c echo.Context) (storage.PresignedObject, error) {
+	params, err := s.parseObjectInput(c)
+	if err != nil {
+		return storage.PresignedObject{}, err
+	}
+	expiry := defaultPresignExpiry
+	if c.QueryParam(ParameterExpiry) != "" {
+		expiry, err = time.ParseDuration(c.QueryParam(ParameterExpiry))
+		if err != nil {
+			return storage.PresignedObject{}, err
+		}
+	}
+	return s.Collector.Storage.PresignObject(params.BucketName, params.BlockId, params.WithPOI, expiry, s.Context)
+}
+
+// setLegalHoldFromRequest places or removes the legal hold of an object, the private requests being allowed on the buckets of
+// their user only and the shared requests being reserved to the admins.
+func (s *Server) setLegalHoldFromRequest(c echo.Context) (ObjectParams, bool, error) {
+	params, err := s.parseObjectInput(c)
+	if err != nil {
+		return params, false, err
+	}
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return params, false, err
+	}
+	if userId == "" {
+		if err = s.checkAdmin(c); err != nil {
+			return params, false, err
+		}
+	}
+	var request RequestLegalHold
+	err = extractRequestBody(&request, c)
+	if err != nil {
+		return params, false, err
+	}
+
+	err = s.Collector.Storage.SetLegalHold(params.BucketName, params.BlockId, *request.Hold, s.Context)
+	if err != nil {
+		return params, false, err
+	}
+	return params, *request.Hold, nil
+}
+
 func (s *Server) storeBlockFromTangle(c echo.Context) (string, string, error) {
 	var request RequestStoreBody
 	err := extractRequestBody(&request, c)
@@ -165,9 +486,13 @@ func (s *Server) storeBlockFromTangle(c echo.Context) (string, string, error) {
 		return "", "", err
 	}
 
-	bucketName := s.Collector.Storage.DefaultBucketName
-	if request.BucketName != "" {
-		bucketName = request.BucketName
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+	bucketName, err := s.resolveBucket(request.BucketName, userId)
+	if err != nil {
+		return "", "", err
 	}
 
 	var object storage.Object
@@ -180,12 +505,17 @@ func (s *Server) storeBlockFromTangle(c echo.Context) (string, string, error) {
 		return "", "", err
 	}
 
-	err = s.Collector.Storage.UploadObject(request.BlockId, bucketName, object, s.Context)
+	attributes, err := listener.GetObjectAttributes(request.BlockId, s.Collector.NodeBridge.Client(), s.Context)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.Collector.Storage.UploadObject(attributes, bucketName, object, s.Context)
 	if err != nil {
 		return "", "", err
 	}
 
-	return request.BlockId, bucketName, nil
+	return request.BlockId, storage.UserBucketName(userId, bucketName), nil
 }
 
 func (s *Server) subscribeToTag(c echo.Context) (string, string, error) {
@@ -195,17 +525,21 @@ func (s *Server) subscribeToTag(c echo.Context) (string, string, error) {
 		return "", "", err
 	}
 
-	bucketName := s.Collector.Storage.DefaultBucketName
-	if request.BucketName != "" {
-		bucketName = request.BucketName
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+	bucketName, err := s.resolveBucket(request.BucketName, userId)
+	if err != nil {
+		return "", "", err
 	}
 
-	filter, err := listener.NewFilter(request.Tag, request.PublicKey, bucketName, request.Duration, request.WithPOI)
+	filter, err := listener.NewFilter(request.Tag, request.PublicKey, bucketName, request.Duration, request.WithPOI, request.KeyLayout, request.RetentionDays)
 	if err != nil {
 		return "", "", err
 	}
 
-	filterId, err := s.Collector.Listener.AddFilter(filter)
+	filterId, err := s.Collector.Listener.AddFilter(filter, s.Context)
 	if err != nil {
 		return "", "", err
 	}
@@ -213,20 +547,80 @@ func (s *Server) subscribeToTag(c echo.Context) (string, string, error) {
 	return filterId, request.Tag, nil
 }
 
+// unsubscribeFromRequest removes the filter, the filters storing blocks in a private bucket can only be removed by its owner.
+func (s *Server) unsubscribeFromRequest(filterId string, c echo.Context) error {
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return err
+	}
+	filter, ok := s.Collector.Listener.GetFilter(filterId)
+	if !ok {
+		return listener.ErrFilterNotFound
+	}
+	config, err := s.Collector.Storage.GetBucketConfig(filter.BucketName, s.Context)
+	if err != nil {
+		return err
+	}
+	if config.Owner != userId {
+		return listener.ErrFilterNotFound
+	}
+	return s.Collector.Listener.RemoveFilter(filterId, s.Context)
+}
+
 func (s *Server) createBucketFromRequest(c echo.Context) (string, error) {
 	var request RequestCreateBucket
 	err := extractRequestBody(&request, c)
 	if err != nil {
 		return "", err
 	}
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", err
+	}
 
-	err = s.Collector.Storage.CreateBucket(request.BucketName, s.Context)
+	// the private buckets are created in the namespace of their owner
+	bucketName := request.BucketName
+	if userId != "" {
+		bucketName = storage.PrivateBucketName(userId, request.BucketName)
+		if request.Quota.OverflowBucket != "" {
+			request.Quota.OverflowBucket = storage.PrivateBucketName(userId, request.Quota.OverflowBucket)
+		}
+	} else if storage.IsPrivateBucketName(bucketName) {
+		return "", fmt.Errorf("bucket '%s' is in the namespace reserved to the private buckets", bucketName)
+	}
+
+	config := storage.BucketConfig{Compression: request.Compression, Encryption: request.Encryption, KeyLayout: request.KeyLayout, Format: request.Format, Quota: request.Quota, Owner: userId}
+	err = config.Validate()
 	if err != nil {
 		return "", err
 	}
 
+	if request.ObjectLock {
+		err = s.Collector.Storage.CreateLockedBucket(bucketName, request.Retention, s.Context)
+	} else if request.Retention != (storage.Retention{}) {
+		err = fmt.Errorf("a default retention needs object lock")
+	} else {
+		err = s.Collector.Storage.CreateBucket(bucketName, s.Context)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if config != (storage.BucketConfig{}) {
+		err = s.Collector.Storage.SetBucketConfig(bucketName, config, s.Context)
+		if err != nil {
+			// a private bucket without its owner would be shared
+			if userId != "" {
+				if err := s.Collector.Storage.DeleteBucket(bucketName, s.Context); err != nil {
+					s.WrappedLogger.LogWarnf("Can't delete private bucket '%s' without owner, error: %s", bucketName, err)
+				}
+			}
+			return "", err
+		}
+	}
+
 	if request.LifecycleDays != 0 {
-		err = s.Collector.Storage.SetBucketExpirationDays(request.BucketName, request.LifecycleDays, s.Context)
+		err = s.Collector.Storage.SetBucketExpirationDays(bucketName, request.LifecycleDays, s.Context)
 		if err != nil {
 			return "", err
 		}
@@ -234,3 +628,302 @@ func (s *Server) createBucketFromRequest(c echo.Context) (string, error) {
 
 	return request.BucketName, nil
 }
+
+func (s *Server) listBucketsFromRequest(c echo.Context) ([]ResponseBucket, error) {
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := s.Collector.Storage.ListOwnedBuckets(userId, s.Context)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]ResponseBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		resp = append(resp, ResponseBucket{Name: storage.UserBucketName(userId, bucket.Name), CreationDate: bucket.CreationDate})
+	}
+	return resp, nil
+}
+
+func (s *Server) getBucketDetailsFromRequest(c echo.Context) (ResponseBucketDetails, error) {
+	var resp ResponseBucketDetails
+	bucketName, userId, err := s.bucketFromRequest(c)
+	if err != nil {
+		return resp, err
+	}
+	err = s.checkBucketExists(bucketName)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Name = storage.UserBucketName(userId, bucketName)
+	resp.LifecycleDays, err = s.Collector.Storage.GetBucketExpirationDays(bucketName, s.Context)
+	if err != nil {
+		return resp, err
+	}
+	resp.Lifecycle, err = s.Collector.Storage.GetBucketLifecycle(bucketName, s.Context)
+	if err != nil {
+		return resp, err
+	}
+	resp.ObjectCount, resp.TotalSize, err = s.Collector.Storage.GetBucketUsage(bucketName, s.Context)
+	if err != nil {
+		return resp, err
+	}
+	resp.Config, err = s.Collector.Storage.GetBucketConfig(bucketName, s.Context)
+	if err != nil {
+		return resp, err
+	}
+	resp.Retention, resp.ObjectLock, err = s.Collector.Storage.GetBucketRetention(bucketName, s.Context)
+	if err != nil {
+		return resp, err
+	}
+	resp.Filters = s.Collector.Listener.FiltersForBucket(bucketName)
+	return resp, nil
+}
+
+func (s *Server) updateBucketFromRequest(c echo.Context) (string, error) {
+	var request RequestUpdateBucket
+	err := extractRequestBody(&request, c)
+	if err != nil {
+		return "", err
+	}
+
+	bucketName, userId, err := s.bucketFromRequest(c)
+	if err != nil {
+		return "", err
+	}
+	err = s.checkBucketExists(bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	if request.LifecycleDays == nil && request.Quota == nil {
+		return "", fmt.Errorf("nothing to update, set the days or the quota of the bucket")
+	}
+
+	if request.LifecycleDays != nil {
+		err = s.Collector.Storage.SetBucketExpirationDays(bucketName, *request.LifecycleDays, s.Context)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if request.Quota != nil {
+		config, err := s.Collector.Storage.GetBucketConfig(bucketName, s.Context)
+		if err != nil {
+			return "", err
+		}
+		config.Quota = *request.Quota
+		if userId != "" && config.Quota.OverflowBucket != "" {
+			config.Quota.OverflowBucket = storage.PrivateBucketName(userId, config.Quota.OverflowBucket)
+		}
+		err = s.Collector.Storage.SetBucketConfig(bucketName, config, s.Context)
+		if err != nil {
+			return "", err
+		}
+	}
+	return c.Param(ParameterBucketName), nil
+}
+
+func (s *Server) getBucketLifecycleFromRequest(c echo.Context) ([]storage.LifecycleRule, error) {
+	bucketName, _, err := s.bucketFromRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkBucketExists(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return s.Collector.Storage.GetBucketLifecycle(bucketName, s.Context)
+}
+
+func (s *Server) setBucketLifecycleFromRequest(c echo.Context) (string, int, error) {
+	var request RequestSetLifecycle
+	err := extractRequestBody(&request, c)
+	if err != nil {
+		return "", 0, err
+	}
+
+	bucketName, _, err := s.bucketFromRequest(c)
+	if err != nil {
+		return "", 0, err
+	}
+	err = s.checkBucketExists(bucketName)
+	if err != nil {
+		return "", 0, err
+	}
+
+	err = s.Collector.Storage.SetBucketUserLifecycle(bucketName, request.Rules, s.Context)
+	if err != nil {
+		return "", 0, err
+	}
+	return c.Param(ParameterBucketName), len(request.Rules), nil
+}
+
+func (s *Server) deleteBucketFromRequest(c echo.Context) error {
+	bucketName, _, err := s.bucketFromRequest(c)
+	if err != nil {
+		return err
+	}
+	if bucketName == s.Collector.Storage.DefaultBucketName {
+		return fmt.Errorf("the default bucket can't be deleted")
+	}
+	if filters := s.Collector.Listener.FiltersForBucket(bucketName); len(filters) != 0 {
+		return fmt.Errorf("bucket is targeted by filters %s", strings.Join(filters, ", "))
+	}
+	err = s.checkBucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+	return s.Collector.Storage.DeleteBucket(bucketName, s.Context)
+}
+
+func (s *Server) scrubBucketFromRequest(c echo.Context) (string, error) {
+	bucketName, userId, err := s.bucketFromRequest(c)
+	if err != nil {
+		return "", err
+	}
+	repair := false
+	if c.QueryParam(ParameterRepair) != "" {
+		repair, err = strconv.ParseBool(c.QueryParam(ParameterRepair))
+		if err != nil {
+			return "", err
+		}
+	}
+	return s.Collector.StartScrub(bucketName, repair, userId, s.Context)
+}
+
+func (s *Server) migrateFromRequest(c echo.Context) (string, string, error) {
+	var request RequestMigration
+	err := extractRequestBody(&request, c)
+	if err != nil {
+		return "", "", err
+	}
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+	bucketName := request.SourceBucket
+	request.SourceBucket, err = s.resolveBucket(request.SourceBucket, userId)
+	if err != nil {
+		return "", "", err
+	}
+
+	// the other storages are only reached through the targets configured on the server
+	if request.TargetStorage != "" {
+		migrationTarget, ok := s.MigrationTargets[request.TargetStorage]
+		if !ok {
+			return "", "", fmt.Errorf("unknown migration target '%s'", request.TargetStorage)
+		}
+		target, err := s.Collector.Storage.NewSiblingStorage(migrationTarget.parameters())
+		if err != nil {
+			return "", "", err
+		}
+		jobId, err := s.Collector.StartMigration(request.Options, target, userId, s.Context)
+		return bucketName, jobId, err
+	}
+
+	// a private bucket is created with its owner, so the private target bucket must already exist
+	if userId != "" {
+		request.TargetBucket, err = s.resolveBucket(request.TargetBucket, userId)
+		if err != nil {
+			return "", "", err
+		}
+	} else if storage.IsPrivateBucketName(request.TargetBucket) {
+		return "", "", fmt.Errorf("bucket '%s' is in the namespace reserved to the private buckets", request.TargetBucket)
+	}
+	jobId, err := s.Collector.StartMigration(request.Options, nil, userId, s.Context)
+	return bucketName, jobId, err
+}
+
+// backfillFromRequest backfills the bucket of the filter, the filters storing blocks in a private bucket can only be backfilled by its owner.
+func (s *Server) backfillFromRequest(c echo.Context) (string, string, error) {
+	var request RequestBackfill
+	err := extractRequestBody(&request, c)
+	if err != nil {
+		return "", "", err
+	}
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+
+	filterId := strings.ToLower(c.Param(ParameterFilterId))
+	filter, ok := s.Collector.Listener.GetFilter(filterId)
+	if !ok {
+		return "", "", listener.ErrFilterNotFound
+	}
+	config, err := s.Collector.Storage.GetBucketConfig(filter.BucketName, s.Context)
+	if err != nil {
+		return "", "", err
+	}
+	if config.Owner != userId {
+		return "", "", listener.ErrFilterNotFound
+	}
+
+	jobId, err := s.Collector.StartBackfill(filter, request.MilestoneRange, userId, s.Context)
+	return filterId, jobId, err
+}
+
+// backfillAdHocFromRequest backfills a bucket with the blocks matched by a filter given in the request, which is not added to the listener.
+func (s *Server) backfillAdHocFromRequest(c echo.Context) (string, string, error) {
+	var request RequestBackfillAdHoc
+	err := extractRequestBody(&request, c)
+	if err != nil {
+		return "", "", err
+	}
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+	bucketName, err := s.resolveBucket(request.BucketName, userId)
+	if err != nil {
+		return "", "", err
+	}
+
+	filter, err := listener.NewAdHocFilter(request.Tag, request.PublicKey, bucketName, request.WithPOI, request.KeyLayout)
+	if err != nil {
+		return "", "", err
+	}
+	jobId, err := s.Collector.StartBackfill(filter, request.MilestoneRange, userId, s.Context)
+	return request.Tag, jobId, err
+}
+
+func (s *Server) checkBucketExists(bucketName string) error {
+	exists, err := s.Collector.Storage.BucketExists(bucketName, s.Context)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrBucketNotFound
+	}
+	return nil
+}
+
+// bucketErrorStatus returns the HTTP status of an error raised managing a bucket.
+func bucketErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, storage.ErrBucketNotFound), errors.Is(err, listener.ErrFilterNotFound), errors.Is(err, jobs.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrBucketNotEmpty):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// presignErrorStatus returns the HTTP status of an error raised presigning an object.
+func presignErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrObjectNotFound), errors.Is(err, storage.ErrBucketNotFound), errors.Is(err, storage.ErrNoProof):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, storage.ErrPresignNotSupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusBadRequest
+	}
+}
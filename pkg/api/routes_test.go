@@ -0,0 +1,162 @@
+package api
+
+import (
+	"collector/pkg/storage"
+	"net/http"
+	"testing"
+)
+
+func TestSetLegalHoldAuthorization(t *testing.T) {
+	server, e := newTestServer(t)
+
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket", "", RequestCreateBucket{BucketName: "archive", ObjectLock: true}), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket?private=true&userId=bob", "bob", RequestCreateBucket{BucketName: "archive", ObjectLock: true}), http.StatusOK)
+	shared := storeTestBlock(t, server, "archive", "shared")
+	private := storeTestBlock(t, server, "private-bob-archive", "private")
+
+	hold := true
+	release := false
+	sharedHold := "/block/" + shared + "/legalhold?bucketName=archive"
+	privateHold := "/block/" + private + "/legalhold?bucketName=archive&private=true"
+
+	// the legal holds of the shared buckets are reserved to the admins
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, sharedHold, "", RequestLegalHold{Hold: &hold}), http.StatusUnauthorized)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, sharedHold+"&userId=bob", "bob", RequestLegalHold{Hold: &hold}), http.StatusUnauthorized)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, sharedHold+"&userId=alice", "alice", RequestLegalHold{Hold: &hold}), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/block/"+shared+"?bucketName=archive", "", nil), http.StatusForbidden)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, sharedHold+"&userId=bob", "bob", RequestLegalHold{Hold: &release}), http.StatusUnauthorized)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, sharedHold+"&userId=alice", "alice", RequestLegalHold{Hold: &release}), http.StatusOK)
+
+	// the legal holds of a private bucket can be changed by its owner only
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, privateHold+"&userId=alice", "alice", RequestLegalHold{Hold: &hold}), http.StatusNotFound)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, privateHold+"&userId=bob", "bob", RequestLegalHold{Hold: &hold}), http.StatusOK)
+}
+
+func TestBucketRoutes(t *testing.T) {
+	server, e := newTestServer(t)
+
+	create := RequestCreateBucket{BucketName: "archive", LifecycleDays: 7, Compression: storage.CompressionGzip}
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket", "", create), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket", "", create), http.StatusBadRequest)
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket", "", RequestCreateBucket{BucketName: "invalid", Compression: "lz4"}), http.StatusBadRequest)
+
+	var buckets []ResponseBucket
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket", "", nil), &buckets)
+	if len(buckets) != 2 || buckets[0].Name != "archive" || buckets[1].Name != "default" {
+		t.Errorf("buckets are %v, want archive and default", buckets)
+	}
+
+	var details ResponseBucketDetails
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket/archive", "", nil), &details)
+	if details.Name != "archive" || details.LifecycleDays != 7 || details.Config.Compression != storage.CompressionGzip || details.ObjectCount != 0 {
+		t.Errorf("bucket details are %+v", details)
+	}
+	expectStatus(t, doTestRequest(t, e, http.MethodGet, "/bucket/missing", "", nil), http.StatusNotFound)
+
+	days := 3
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, "/bucket/archive", "", RequestUpdateBucket{LifecycleDays: &days}), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, "/bucket/archive", "", RequestUpdateBucket{}), http.StatusBadRequest)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, "/bucket/missing", "", RequestUpdateBucket{LifecycleDays: &days}), http.StatusNotFound)
+	storeTestBlock(t, server, "archive", "archived")
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket/archive", "", nil), &details)
+	if details.LifecycleDays != days || details.ObjectCount != 1 || details.TotalSize == 0 {
+		t.Errorf("bucket details are %+v, want %d days and one object", details, days)
+	}
+
+	// the lifecycle rules replace the expiration days
+	rules := []storage.LifecycleRule{{Id: "sensors", Prefix: "sensors/", Days: 30}}
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, "/bucket/archive/lifecycle", "", RequestSetLifecycle{Rules: rules}), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodPut, "/bucket/archive/lifecycle", "", RequestSetLifecycle{Rules: []storage.LifecycleRule{{Id: "never"}}}), http.StatusBadRequest)
+	var lifecycle []storage.LifecycleRule
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket/archive/lifecycle", "", nil), &lifecycle)
+	if len(lifecycle) != 1 || lifecycle[0].Id != "sensors" || lifecycle[0].Prefix != "sensors/" {
+		t.Errorf("lifecycle is %v, want %v", lifecycle, rules)
+	}
+
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/bucket/default", "", nil), http.StatusBadRequest)
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/bucket/missing", "", nil), http.StatusNotFound)
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/bucket/archive", "", nil), http.StatusConflict)
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/bucket", "", nil), http.StatusMethodNotAllowed)
+	for _, object := range listTestObjects(t, server, "archive") {
+		if err := server.Collector.Storage.Backend().DeleteObject("archive", object, server.Context); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectStatus(t, doTestRequest(t, e, http.MethodDelete, "/bucket/archive", "", nil), http.StatusOK)
+	expectStatus(t, doTestRequest(t, e, http.MethodGet, "/bucket/archive", "", nil), http.StatusNotFound)
+}
+
+func TestPrivateBucketAccess(t *testing.T) {
+	server, e := newTestServer(t)
+
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket?private=true&userId=bob", "bob", RequestCreateBucket{BucketName: "vault"}), http.StatusOK)
+	blockId := storeTestBlock(t, server, "private-bob-vault", "secret")
+
+	// the private buckets are listed to their owner only, under the name the owner gave them
+	var buckets []ResponseBucket
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket?private=true&userId=bob", "bob", nil), &buckets)
+	if len(buckets) != 1 || buckets[0].Name != "vault" {
+		t.Errorf("buckets of bob are %v, want [vault]", buckets)
+	}
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket?private=true&userId=alice", "alice", nil), &buckets)
+	if len(buckets) != 0 {
+		t.Errorf("buckets of alice are %v, want none", buckets)
+	}
+	decodeTestResponse(t, doTestRequest(t, e, http.MethodGet, "/bucket", "", nil), &buckets)
+	if len(buckets) != 1 || buckets[0].Name != "default" {
+		t.Errorf("shared buckets are %v, want [default]", buckets)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		userId     string
+		wantStatus int
+	}{
+		{name: "owner reads the block", method: http.MethodGet, target: "/block/" + blockId + "?bucketName=vault&private=true&userId=bob", userId: "bob", wantStatus: http.StatusOK},
+		{name: "another user reads the block", method: http.MethodGet, target: "/block/" + blockId + "?bucketName=vault&private=true&userId=alice", userId: "alice", wantStatus: http.StatusNotFound},
+		{name: "shared request reads the block", method: http.MethodGet, target: "/block/" + blockId + "?bucketName=private-bob-vault", wantStatus: http.StatusNotFound},
+		{name: "wrong token", method: http.MethodGet, target: "/block/" + blockId + "?bucketName=vault&private=true&userId=bob", userId: "alice", wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", method: http.MethodGet, target: "/bucket/vault?private=true&userId=carol", userId: "carol", wantStatus: http.StatusUnauthorized},
+		{name: "another user reads the bucket", method: http.MethodGet, target: "/bucket/vault?private=true&userId=alice", userId: "alice", wantStatus: http.StatusNotFound},
+		{name: "another user deletes the block", method: http.MethodDelete, target: "/block/" + blockId + "?bucketName=vault&private=true&userId=alice", userId: "alice", wantStatus: http.StatusNotFound},
+		{name: "another user deletes the bucket", method: http.MethodDelete, target: "/bucket/vault?private=true&userId=alice", userId: "alice", wantStatus: http.StatusNotFound},
+		{name: "shared request deletes the bucket", method: http.MethodDelete, target: "/bucket/private-bob-vault", wantStatus: http.StatusNotFound},
+		{name: "owner reads the bucket", method: http.MethodGet, target: "/bucket/vault?private=true&userId=bob", userId: "bob", wantStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectStatus(t, doTestRequest(t, e, test.method, test.target, test.userId, nil), test.wantStatus)
+		})
+	}
+
+	// the namespace of the private buckets is reserved to them
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket", "", RequestCreateBucket{BucketName: "private-bob-other"}), http.StatusBadRequest)
+	// the same name can be used by several users
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket?private=true&userId=alice", "alice", RequestCreateBucket{BucketName: "vault"}), http.StatusOK)
+	config, err := server.Collector.Storage.GetBucketConfig("private-alice-vault", server.Context)
+	if err != nil || config.Owner != "alice" {
+		t.Errorf("owner of the bucket of alice is '%s' (%v)", config.Owner, err)
+	}
+}
+
+func TestPrivateBucketsDisabled(t *testing.T) {
+	server, e := newTestServer(t)
+	server.Users = nil
+	expectStatus(t, doTestRequest(t, e, http.MethodPost, "/bucket?private=true&userId=bob", "bob", RequestCreateBucket{BucketName: "vault"}), http.StatusBadRequest)
+}
+
+// listTestObjects returns the names of the objects stored in the bucket, index entries included.
+func listTestObjects(t *testing.T, server *Server, bucketName string) []string {
+	t.Helper()
+	objects, err := server.Collector.Storage.Backend().ListObjects(bucketName, server.Context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, object.Key)
+	}
+	return names
+}
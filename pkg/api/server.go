@@ -12,14 +12,32 @@ type Server struct {
 	*logger.WrappedLogger
 	Collector *collector.Collector
 	Context   context.Context
+	// Users authenticates the requests to the private buckets, nil if they are disabled
+	Users *Users
+	// MigrationTargets are the storages the objects can be migrated to, by name
+	MigrationTargets map[string]MigrationTarget
 }
 
-func NewServer(collector *collector.Collector, echo *echo.Echo, log *logger.WrappedLogger, ctx context.Context) *Server {
+func NewServer(collector *collector.Collector, echo *echo.Echo, params Parameters, log *logger.WrappedLogger, ctx context.Context) (*Server, error) {
 	s := &Server{
 		WrappedLogger: logger.NewWrappedLogger(log.LoggerNamed("ServerRestAPI")),
 		Collector:     collector,
 		Context:       ctx,
 	}
+	if params.UsersFile != "" {
+		users, err := LoadUsers(params.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		s.Users = users
+	}
+	if params.MigrationTargetsFile != "" {
+		targets, err := LoadMigrationTargets(params.MigrationTargetsFile)
+		if err != nil {
+			return nil, err
+		}
+		s.MigrationTargets = targets
+	}
 	s.setupRoutes(echo)
-	return s
+	return s, nil
 }
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"collector/pkg/collector"
+	"collector/pkg/jobs"
+	"collector/pkg/listener"
+	"collector/pkg/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iotaledger/hive.go/core/logger"
+	iotago "github.com/iotaledger/iota.go/v3"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestServer returns a server storing the objects in memory, with the default bucket created and the users alice, an admin, and bob,
+// whose tokens are their user ids followed by "-token".
+func newTestServer(t *testing.T) (*Server, *echo.Echo) {
+	t.Helper()
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	s := storage.NewStorageWithBackend(storage.NewMemoryBackend(), storage.Parameters{DefaultBucketName: "default"}, log)
+	if err := s.CreateBucket("default", context.Background()); err != nil {
+		t.Fatalf("can't create bucket, error: %s", err)
+	}
+
+	file := UsersFile{Users: map[string]string{}, Admins: []string{"alice"}}
+	for _, userId := range []string{"alice", "bob"} {
+		hash := sha256.Sum256([]byte(userId + "-token"))
+		file.Users[userId] = hex.EncodeToString(hash[:])
+	}
+	fileBytes, err := json.Marshal(file)
+	if err != nil {
+		t.Fatalf("can't encode users file, error: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, fileBytes, 0o600); err != nil {
+		t.Fatalf("can't write users file, error: %s", err)
+	}
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("can't load users, error: %s", err)
+	}
+
+	server := &Server{
+		WrappedLogger: log,
+		Collector: &collector.Collector{
+			WrappedLogger: log,
+			Storage:       s,
+			Listener:      listener.Listener{WrappedLogger: log, Filters: listener.NewRegistry(), Storage: s},
+			Jobs:          jobs.NewManager(log),
+		},
+		Context: context.Background(),
+		Users:   users,
+	}
+	e := echo.New()
+	server.setupRoutes(e)
+	return server, e
+}
+
+// doTestRequest serves the request with the credentials of the user, none for an empty user id, and returns the response.
+func doTestRequest(t *testing.T, e *echo.Echo, method string, target string, userId string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("can't encode body, error: %s", err)
+		}
+		reader = bytes.NewReader(bodyBytes)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	request := httptest.NewRequest(method, target, reader)
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if userId != "" {
+		request.Header.Set(HeaderUserToken, userId+"-token")
+	}
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// storeTestBlock stores a block with a tagged data payload in the bucket and returns its block id.
+func storeTestBlock(t *testing.T, server *Server, bucketName string, data string) string {
+	t.Helper()
+	block := &iotago.Block{
+		ProtocolVersion: 2,
+		Parents:         iotago.BlockIDs{{1}},
+		Payload:         &iotago.TaggedData{Tag: []byte("sensors"), Data: []byte(data)},
+	}
+	blockId, err := block.ID()
+	if err != nil {
+		t.Fatalf("can't compute block id, error: %s", err)
+	}
+	attributes := storage.ObjectAttributes{
+		BlockId:            hex.EncodeToString(blockId[:]),
+		Tag:                []byte("sensors"),
+		MilestoneIndex:     10,
+		MilestoneTimestamp: 1680000010,
+	}
+	err = server.Collector.Storage.UploadObject(attributes, bucketName, storage.Object{Block: block}, context.Background())
+	if err != nil {
+		t.Fatalf("can't store block, error: %s", err)
+	}
+	return attributes.BlockId
+}
+
+func expectStatus(t *testing.T, response *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if response.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, response.Code, response.Body.String())
+	}
+}
+
+// decodeTestResponse checks that the request succeeded and decodes its JSON body into value.
+func decodeTestResponse(t *testing.T, response *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+	expectStatus(t, response, http.StatusOK)
+	if err := json.Unmarshal(response.Body.Bytes(), value); err != nil {
+		t.Fatalf("can't decode response %s, error: %s", response.Body.String(), err)
+	}
+}
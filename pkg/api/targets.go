@@ -0,0 +1,62 @@
+package api
+
+import (
+	"collector/pkg/storage"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MigrationTarget describes a storage the objects can be migrated to.
+type MigrationTarget struct {
+	Type            string `json:"type" validate:"required"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	Region          string `json:"region"`
+	Secure          bool   `json:"secure"`
+	// Path is the directory of the filesystem backend
+	Path string `json:"path"`
+}
+
+// MigrationTargetsFile is the content of the file holding the storages the objects can be migrated to, by name.
+type MigrationTargetsFile struct {
+	Targets map[string]MigrationTarget `json:"targets"`
+}
+
+func LoadMigrationTargets(path string) (map[string]MigrationTarget, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read migration targets file, error: %w", err)
+	}
+	var file MigrationTargetsFile
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		return nil, fmt.Errorf("can't parse migration targets file, error: %w", err)
+	}
+
+	for name, target := range file.Targets {
+		if err := validator.New().Struct(target); err != nil {
+			return nil, fmt.Errorf("invalid migration target '%s', error: %w", name, err)
+		}
+		if target.Type == storage.BackendMemory {
+			return nil, fmt.Errorf("invalid migration target '%s', error: %w", name, storage.ErrVolatileTarget)
+		}
+	}
+	return file.Targets, nil
+}
+
+// parameters returns the parameters of the storage backend of the target.
+func (t MigrationTarget) parameters() storage.Parameters {
+	params := storage.Parameters{
+		Type:            t.Type,
+		Endpoint:        t.Endpoint,
+		AccessKeyID:     t.AccessKeyID,
+		SecretAccessKey: t.SecretAccessKey,
+		Region:          t.Region,
+		Secure:          t.Secure,
+	}
+	params.Filesystem.Path = t.Path
+	return params
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/storage"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderUserToken is the header carrying the token of the user of a private request.
+const HeaderUserToken = "X-User-Token"
+
+// ErrUnauthorized is returned when a private request doesn't carry valid credentials.
+var ErrUnauthorized = errors.New("invalid user id or token")
+
+// UsersFile is the content of the file holding the users allowed to own private buckets.
+type UsersFile struct {
+	// Users maps the user ids to the hex encoded SHA-256 hashes of their tokens
+	Users map[string]string `json:"users"`
+	// Admins are the ids of the users allowed to manage the whole plugin
+	Admins []string `json:"admins"`
+}
+
+// Users authenticates the private requests.
+type Users struct {
+	tokenHashes map[string][]byte
+	admins      map[string]bool
+}
+
+func LoadUsers(path string) (*Users, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read users file, error: %w", err)
+	}
+	var file UsersFile
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		return nil, fmt.Errorf("can't parse users file, error: %w", err)
+	}
+
+	users := &Users{tokenHashes: make(map[string][]byte, len(file.Users)), admins: make(map[string]bool, len(file.Admins))}
+	for userId, hashHex := range file.Users {
+		if err := storage.ValidateUserId(userId); err != nil {
+			return nil, err
+		}
+		hash, err := hex.DecodeString(hashHex)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid token hash for user '%s', it must be a hex encoded SHA-256 hash", userId)
+		}
+		users.tokenHashes[userId] = hash
+	}
+	for _, userId := range file.Admins {
+		if _, ok := users.tokenHashes[userId]; !ok {
+			return nil, fmt.Errorf("admin '%s' is not a user", userId)
+		}
+		users.admins[userId] = true
+	}
+	return users, nil
+}
+
+// Authenticate checks the token of the user.
+func (u *Users) Authenticate(userId string, token string) error {
+	hash, ok := u.tokenHashes[userId]
+	tokenHash := sha256.Sum256([]byte(token))
+	if !ok || subtle.ConstantTimeCompare(hash, tokenHash[:]) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// IsAdmin reports whether the user is allowed to manage the whole plugin.
+func (u *Users) IsAdmin(userId string) bool {
+	return u.admins[userId]
+}
+
+// parseUser returns the authenticated user of a private request, an empty user id for the other requests.
+func (s *Server) parseUser(c echo.Context) (string, error) {
+	if c.QueryParam(ParameterPrivate) == "" {
+		return "", nil
+	}
+	private, err := strconv.ParseBool(c.QueryParam(ParameterPrivate))
+	if err != nil || !private {
+		return "", err
+	}
+	if s.Users == nil {
+		return "", fmt.Errorf("private buckets are disabled, no users file is configured")
+	}
+
+	userId := c.QueryParam(ParameterUserId)
+	if err := s.Users.Authenticate(userId, c.Request().Header.Get(HeaderUserToken)); err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+// resolveBucket returns the name in the storage of the bucket the user calls bucketName,
+// the shared buckets being resolved for an empty user id, and checks that the bucket belongs to the user.
+func (s *Server) resolveBucket(bucketName string, userId string) (string, error) {
+	if userId == "" {
+		if bucketName == "" {
+			bucketName = s.Collector.Storage.DefaultBucketName
+		}
+	} else {
+		if bucketName == "" {
+			return "", fmt.Errorf("the bucket name is required for private requests")
+		}
+		bucketName = storage.PrivateBucketName(userId, bucketName)
+	}
+
+	err := s.Collector.Storage.CheckBucketOwner(bucketName, userId, s.Context)
+	if err != nil {
+		return "", err
+	}
+	return bucketName, nil
+}
+
+// bucketFromRequest returns the name in the storage of the bucket in the path of the request.
+func (s *Server) bucketFromRequest(c echo.Context) (string, string, error) {
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return "", "", err
+	}
+	bucketName, err := s.resolveBucket(c.Param(ParameterBucketName), userId)
+	return bucketName, userId, err
+}
+
+// checkAdmin checks that the request carries the credentials of an admin, in the user id parameter and the token header
+// as the private requests carry those of their user, so that the shared requests can be checked too.
+// Without a users file there are no credentials to check and every request can manage the plugin.
+func (s *Server) checkAdmin(c echo.Context) error {
+	if s.Users == nil {
+		return nil
+	}
+	userId := c.QueryParam(ParameterUserId)
+	if err := s.Users.Authenticate(userId, c.Request().Header.Get(HeaderUserToken)); err != nil {
+		return err
+	}
+	if !s.Users.IsAdmin(userId) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// canAccessJob reports whether the user of the request can see and cancel the job: the users their own jobs,
+// the shared requests the jobs of the shared buckets and the admins every job.
+func (s *Server) canAccessJob(status jobs.Status, userId string) bool {
+	return status.Owner == userId || (s.Users != nil && s.Users.IsAdmin(userId))
+}
+
+// jobFromRequest returns the job in the path of the request, as not found if it belongs to another user.
+func (s *Server) jobFromRequest(c echo.Context) (jobs.Status, error) {
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return jobs.Status{}, err
+	}
+	status, err := s.Collector.Jobs.Get(c.Param(ParameterJobId))
+	if err != nil {
+		return jobs.Status{}, err
+	}
+	if !s.canAccessJob(status, userId) {
+		return jobs.Status{}, jobs.ErrJobNotFound
+	}
+	return status, nil
+}
+
+// jobsFromRequest returns the jobs the user of the request can see.
+func (s *Server) jobsFromRequest(c echo.Context) ([]jobs.Status, error) {
+	userId, err := s.parseUser(c)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []jobs.Status{}
+	for _, status := range s.Collector.Jobs.List() {
+		if s.canAccessJob(status, userId) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/listener"
+	"context"
+)
+
+// JobBackfill is the kind of the jobs backfilling a bucket with the blocks matched by a filter.
+const JobBackfill = "backfill"
+
+// BackfillReport is the result of a backfill.
+type BackfillReport struct {
+	FilterId   string `json:"filterId"`
+	Tag        string `json:"tag"`
+	Bucket     string `json:"bucket"`
+	StartIndex uint32 `json:"startIndex"`
+	EndIndex   uint32 `json:"endIndex"`
+	// LastIndex is the last milestone whose blocks were all checked
+	LastIndex uint32 `json:"lastIndex,omitempty"`
+	Checked   int    `json:"checked"`
+	Stored    int    `json:"stored"`
+	Failed    int    `json:"failed"`
+}
+
+// StartBackfill starts a job walking the milestones of the range which the node still retains, and storing the blocks they reference
+// which the filter matches, as the filter would have stored them if it had been listening. The job belongs to owner. It returns the id of the job,
+// which identifies the backfill: backfills of the same filter, or of ad-hoc filters with the same tag and bucket, run as separate jobs.
+func (c *Collector) StartBackfill(filter listener.Filter, milestoneRange listener.MilestoneRange, owner string, ctx context.Context) (string, error) {
+	client := c.NodeBridge.Client()
+	startIndex, endIndex, err := listener.ResolveMilestoneRange(milestoneRange, client, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	job := c.Jobs.Start(JobBackfill, filter.Id, owner, func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+		return c.backfill(filter, startIndex, endIndex, job, ctx)
+	}, ctx)
+	return job.Status().Id, nil
+}
+
+func (c *Collector) backfill(filter listener.Filter, startIndex uint32, endIndex uint32, job *jobs.Job, ctx context.Context) (BackfillReport, error) {
+	report := BackfillReport{FilterId: filter.Id, Tag: filter.Tag, Bucket: filter.BucketName, StartIndex: startIndex, EndIndex: endIndex}
+	job.SetTotal(int(endIndex - startIndex + 1))
+
+	client := c.NodeBridge.Client()
+	for milestoneIndex := startIndex; milestoneIndex <= endIndex; milestoneIndex++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		result, err := c.Listener.BackfillMilestone(filter, milestoneIndex, client, ctx)
+		report.Checked += result.Checked
+		report.Stored += result.Stored
+		report.Failed += result.Failed
+		if err != nil {
+			return report, err
+		}
+		report.LastIndex = milestoneIndex
+		job.Advance(1)
+		job.SetResult(report)
+	}
+
+	c.WrappedLogger.LogInfof("Backfilled filter '%s' over milestones %d to %d: %d blocks checked, %d stored, %d failed", filter.Id, startIndex, endIndex, report.Checked, report.Stored, report.Failed)
+	return report, nil
+}
@@ -1,11 +1,11 @@
 package collector
 
 import (
+	"collector/pkg/jobs"
 	"collector/pkg/listener"
 	"collector/pkg/poi"
 	"collector/pkg/storage"
 	"context"
-	"fmt"
 
 	"github.com/iotaledger/hive.go/core/app/pkg/shutdown"
 	"github.com/iotaledger/hive.go/core/logger"
@@ -18,8 +18,9 @@ type Collector struct {
 	NodeBridge      *nodebridge.NodeBridge
 	shutdownHandler *shutdown.ShutdownHandler
 	Listener        listener.Listener
-	Storage         storage.Storage
+	Storage         *storage.Storage
 	POIHandler      poi.POIHandler
+	Jobs            *jobs.Manager
 }
 
 func NewCollector(log *logger.Logger, bridge *nodebridge.NodeBridge,
@@ -29,6 +30,7 @@ func NewCollector(log *logger.Logger, bridge *nodebridge.NodeBridge,
 		NodeBridge:      bridge,
 		shutdownHandler: shutdownHandler,
 	}
+	collector.Jobs = jobs.NewManager(collector.WrappedLogger)
 
 	storage, err := storage.NewStorage(storageParameters, collector.WrappedLogger)
 	if err != nil {
@@ -49,32 +51,39 @@ func NewCollector(log *logger.Logger, bridge *nodebridge.NodeBridge,
 }
 
 func (c *Collector) Run(ctx context.Context) error {
+	defer func() {
+		if err := c.Storage.Close(); err != nil {
+			c.WrappedLogger.LogWarnf("Can't close storage : %s", err)
+		}
+	}()
 
 	// manage default storage
-	exists, err := c.Storage.CheckCreateBucket(c.Storage.DefaultBucketName, ctx)
+	err := c.checkCreateDefaultBucket(ctx)
 	if err != nil {
 		c.WrappedLogger.LogErrorf("Can't istantiate storage : %w", err)
 		return err
 	}
-	if exists {
-		days, err := c.Storage.GetBucketExpirationDays(c.Storage.DefaultBucketName, ctx)
+
+	// a new catalog is filled in background with the objects already stored
+	if catalog := c.Storage.Catalog(); catalog != nil {
+		empty, err := catalog.Empty()
 		if err != nil {
 			c.WrappedLogger.LogErrorf("Can't istantiate storage : %w", err)
 			return err
 		}
-		if days != c.Storage.DefaultBucketExpirationDays {
-			err = fmt.Errorf("default bucket already exists, but expiration days are %d instead of the specified %d", days, c.Storage.DefaultBucketExpirationDays)
-			c.WrappedLogger.LogErrorf("Can't istantiate storage : %w", err)
-			return err
-		}
-	} else {
-		err := c.Storage.SetBucketExpirationDays(c.Storage.DefaultBucketName, c.Storage.DefaultBucketExpirationDays, ctx)
-		if err != nil {
-			c.WrappedLogger.LogErrorf("Can't istantiate storage : %w", err)
-			return err
+		if empty {
+			go c.Storage.RebuildCatalog(ctx)
 		}
 	}
 
+	// enforce the backend semantics in background
+	go c.Storage.Run(ctx)
+
+	// check the stored objects in background
+	if c.Storage.ScrubInterval > 0 {
+		go c.runScrubs(ctx)
+	}
+
 	// load startup filters
 	err = c.Listener.LoadStartupFilters(ctx)
 	if err != nil {
@@ -93,3 +102,39 @@ func (c *Collector) Run(ctx context.Context) error {
 
 	return nil
 }
+
+// ListenerStatus returns the status of the listener, with the number of milestones confirmed by the node it didn't process yet.
+func (c *Collector) ListenerStatus() listener.Status {
+	status := c.Listener.Status()
+	status.ConfirmedMilestoneIndex = c.NodeBridge.ConfirmedMilestoneIndex()
+	if status.ConfirmedMilestoneIndex > status.ProcessedMilestoneIndex {
+		status.Lag = status.ConfirmedMilestoneIndex - status.ProcessedMilestoneIndex
+	}
+	return status
+}
+
+// checkCreateDefaultBucket creates the default bucket when it doesn't exist. Its configuration and expiration days are only written
+// when it is created, not to undo the changes made through the REST API.
+func (c *Collector) checkCreateDefaultBucket(ctx context.Context) error {
+	exists, err := c.Storage.CheckCreateBucket(c.Storage.DefaultBucketName, ctx)
+	if err != nil || exists {
+		return err
+	}
+	return c.configureDefaultBucket(ctx)
+}
+
+// configureDefaultBucket writes the configured expiration days in the lifecycle of the default bucket and the configured settings in its tags.
+func (c *Collector) configureDefaultBucket(ctx context.Context) error {
+	// the overflow bucket of the default bucket must exist before it is configured
+	if overflowBucket := c.Storage.DefaultBucketConfig.Quota.OverflowBucket; overflowBucket != "" {
+		_, err := c.Storage.CheckCreateBucket(overflowBucket, ctx)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.Storage.SetBucketExpirationDays(c.Storage.DefaultBucketName, c.Storage.DefaultBucketExpirationDays, ctx)
+	if err != nil {
+		return err
+	}
+	return c.Storage.SetBucketConfig(c.Storage.DefaultBucketName, c.Storage.DefaultBucketConfig, ctx)
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"collector/pkg/storage"
+	"context"
+	"testing"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+func TestCheckCreateDefaultBucket(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	backend := storage.NewMemoryBackend()
+	newCollector := func(days int) *Collector {
+		params := storage.Parameters{DefaultBucketName: "default", DefaultBucketExpirationDays: days}
+		return &Collector{WrappedLogger: log, Storage: storage.NewStorageWithBackend(backend, params, log)}
+	}
+	expirationDays := func(c *Collector) int {
+		t.Helper()
+		days, err := c.Storage.GetBucketExpirationDays("default", ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return days
+	}
+
+	c := newCollector(30)
+	if err := c.checkCreateDefaultBucket(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if days := expirationDays(c); days != 30 {
+		t.Fatalf("expiration days of the new bucket are %d, want 30", days)
+	}
+
+	// the days set through the REST API survive a restart with different configured days
+	if err := c.Storage.SetBucketExpirationDays("default", 7, ctx); err != nil {
+		t.Fatal(err)
+	}
+	c = newCollector(60)
+	if err := c.checkCreateDefaultBucket(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if days := expirationDays(c); days != 7 {
+		t.Errorf("expiration days after a restart are %d, want 7", days)
+	}
+}
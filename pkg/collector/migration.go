@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/migration"
+	"collector/pkg/storage"
+	"context"
+	"fmt"
+)
+
+// StartMigration starts a job copying, or moving, the objects of the source bucket into the target bucket of the target storage,
+// nil meaning the storage of the collector, which is closed once the job is over. The job belongs to owner. It returns the id of the job.
+func (c *Collector) StartMigration(options migration.Options, target *storage.Storage, owner string, ctx context.Context) (string, error) {
+	external := target != nil
+	if !external {
+		target = c.Storage
+	}
+	jobId, err := c.startMigration(options, target, external, owner, ctx)
+	if err != nil && external {
+		target.Close()
+	}
+	return jobId, err
+}
+
+func (c *Collector) startMigration(options migration.Options, target *storage.Storage, external bool, owner string, ctx context.Context) (string, error) {
+	if !external && options.SourceBucket == options.TargetBucket {
+		return "", fmt.Errorf("the objects can't be migrated to the bucket they are in")
+	}
+
+	exists, err := c.Storage.BucketExists(options.SourceBucket, ctx)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", storage.ErrBucketNotFound
+	}
+	if c.Jobs.Running(migration.JobMigration, options.SourceBucket) {
+		return "", fmt.Errorf("a migration of bucket '%s' is already running", options.SourceBucket)
+	}
+
+	err = migration.Configure(target, options, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	job := c.Jobs.Start(migration.JobMigration, options.SourceBucket, owner, func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+		if external {
+			defer target.Close()
+		}
+		return migration.Run(c.Storage, target, options, job, ctx)
+	}, ctx)
+	return job.Status().Id, nil
+}
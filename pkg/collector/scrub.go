@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/listener"
+	"collector/pkg/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// JobScrub is the kind of the jobs scrubbing a bucket.
+const JobScrub = "scrub"
+
+// ScrubReport is the result of the scrub of a bucket.
+type ScrubReport struct {
+	Bucket   string               `json:"bucket"`
+	Checked  int                  `json:"checked"`
+	Repaired int                  `json:"repaired"`
+	Issues   []storage.ScrubIssue `json:"issues"`
+}
+
+// StartScrub starts a job checking every object of the bucket, and re-fetching the damaged ones from the node if repair is set.
+// The job belongs to owner. It returns the id of the job.
+func (c *Collector) StartScrub(bucketName string, repair bool, owner string, ctx context.Context) (string, error) {
+	exists, err := c.Storage.BucketExists(bucketName, ctx)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", storage.ErrBucketNotFound
+	}
+	if c.Jobs.Running(JobScrub, bucketName) {
+		return "", fmt.Errorf("a scrub of bucket '%s' is already running", bucketName)
+	}
+
+	job := c.Jobs.Start(JobScrub, bucketName, owner, func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+		return c.scrub(bucketName, repair, job, ctx)
+	}, ctx)
+	return job.Status().Id, nil
+}
+
+func (c *Collector) scrub(bucketName string, repair bool, job *jobs.Job, ctx context.Context) (ScrubReport, error) {
+	report := ScrubReport{Bucket: bucketName, Issues: []storage.ScrubIssue{}}
+
+	targets, issues, err := c.Storage.ScrubTargets(bucketName, ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Issues = append(report.Issues, issues...)
+	job.SetTotal(len(targets))
+
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		issue, err := c.Storage.ScrubObject(bucketName, target, ctx)
+		if err != nil {
+			return report, err
+		}
+		if issue != nil {
+			c.WrappedLogger.LogWarnf("Scrub found %s object '%s' in bucket '%s': %s", issue.Problem, issue.Key, bucketName, issue.Detail)
+			report.Issues = append(report.Issues, *issue)
+		}
+		report.Checked++
+		job.Advance(1)
+	}
+
+	if repair {
+		for i := range report.Issues {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			c.repairObject(bucketName, &report.Issues[i], ctx)
+			if report.Issues[i].Repaired {
+				report.Repaired++
+			}
+		}
+	}
+
+	c.WrappedLogger.LogInfof("Scrubbed bucket '%s': %d objects checked, %d issues found, %d repaired", bucketName, report.Checked, len(report.Issues), report.Repaired)
+	return report, nil
+}
+
+// repairObject stores again the object of the issue from the block still held by the node, recording the outcome in the issue.
+func (c *Collector) repairObject(bucketName string, issue *storage.ScrubIssue, ctx context.Context) {
+	client := c.NodeBridge.Client()
+
+	var object storage.Object
+	var err error
+	if issue.WithPOI {
+		object, err = listener.GetObjectFromTanglePOI(issue.BlockId, c.POIHandler)
+	} else {
+		object, err = listener.GetObjectFromTangleBlock(issue.BlockId, client, ctx)
+	}
+	if err != nil {
+		issue.RepairError = fmt.Sprintf("the block is not available on the node, error: %s", err)
+		return
+	}
+
+	attributes, err := listener.GetObjectAttributes(issue.BlockId, client, ctx)
+	if err != nil {
+		issue.RepairError = err.Error()
+		return
+	}
+	attributes.FilterId = issue.FilterId
+
+	err = c.Storage.RepairObject(bucketName, issue.Key, attributes, object, ctx)
+	if err != nil {
+		issue.RepairError = err.Error()
+		return
+	}
+	issue.Repaired = true
+}
+
+// runScrubs scrubs every bucket in turn each ScrubInterval, until the context is canceled.
+func (c *Collector) runScrubs(ctx context.Context) {
+	ticker := time.NewTicker(c.Storage.ScrubInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		buckets, err := c.Storage.ListBuckets(ctx)
+		if err != nil {
+			c.WrappedLogger.LogWarnf("Can't list the buckets to scrub, error: %s", err)
+			continue
+		}
+		for _, bucket := range buckets {
+			bucketName := bucket.Name
+			if c.Jobs.Running(JobScrub, bucketName) {
+				continue
+			}
+			// the scheduled scrubs of a private bucket belong to its owner
+			config, err := c.Storage.GetBucketConfig(bucketName, ctx)
+			if err != nil {
+				c.WrappedLogger.LogWarnf("Can't read the configuration of bucket '%s' to scrub, error: %s", bucketName, err)
+				continue
+			}
+			job := c.Jobs.Start(JobScrub, bucketName, config.Owner, func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+				return c.scrub(bucketName, c.Storage.ScrubRepair, job, ctx)
+			}, ctx)
+
+			// the buckets are scrubbed one at a time not to overload the storage
+			select {
+			case <-ctx.Done():
+				return
+			case <-job.Done():
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+const (
+	StateRunning  = "running"
+	StateDone     = "done"
+	StateFailed   = "failed"
+	StateCanceled = "canceled"
+
+	// maxFinishedJobs is the number of finished jobs whose status is kept
+	maxFinishedJobs = 100
+)
+
+// ErrJobNotFound is returned when no job has the given id.
+var ErrJobNotFound = errors.New("job not found")
+
+// Func is the work of a job, it reports its progress through the job and returns its result.
+// It must return when ctx is canceled.
+type Func func(job *Job, ctx context.Context) (interface{}, error)
+
+// Status describes a job and its progress. Owner is the id of the user who started the job, empty for the jobs of the shared buckets.
+type Status struct {
+	Id         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Target     string      `json:"target,omitempty"`
+	Owner      string      `json:"owner,omitempty"`
+	State      string      `json:"state"`
+	Processed  int         `json:"processed"`
+	Total      int         `json:"total"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// Job is a long running task tracked by a Manager.
+type Job struct {
+	mutex  sync.RWMutex
+	status Status
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// SetTotal sets the number of items the job has to process, 0 if unknown.
+func (j *Job) SetTotal(total int) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Total = total
+}
+
+// Advance records that n more items were processed.
+func (j *Job) Advance(n int) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Processed += n
+}
+
+// SetResult publishes the partial result of a running job, replaced by the returned one when it finishes.
+func (j *Job) SetResult(result interface{}) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Result = result
+}
+
+// Done returns a channel closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+func (j *Job) Status() Status {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.status
+}
+
+func (j *Job) finish(result interface{}, err error, ctx context.Context) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	defer close(j.done)
+
+	now := time.Now()
+	j.status.FinishedAt = &now
+	if result != nil {
+		j.status.Result = result
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		j.status.State = StateCanceled
+	case err != nil:
+		j.status.State = StateFailed
+		j.status.Error = err.Error()
+	default:
+		j.status.State = StateDone
+	}
+}
+
+// Manager runs the jobs and keeps the status of the running and recently finished ones.
+type Manager struct {
+	*logger.WrappedLogger
+	mutex sync.RWMutex
+	jobs  map[string]*Job
+}
+
+func NewManager(log *logger.WrappedLogger) *Manager {
+	return &Manager{
+		WrappedLogger: logger.NewWrappedLogger(log.LoggerNamed("Jobs")),
+		jobs:          make(map[string]*Job),
+	}
+}
+
+// Start runs fn in background as a job of the given kind acting on target on behalf of owner, until it returns or ctx is canceled.
+func (m *Manager) Start(kind string, target string, owner string, fn Func, ctx context.Context) *Job {
+	jobCtx, cancel := context.WithCancel(ctx)
+	job := &Job{
+		status: Status{
+			Id:        newJobId(),
+			Kind:      kind,
+			Target:    target,
+			Owner:     owner,
+			State:     StateRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mutex.Lock()
+	m.jobs[job.status.Id] = job
+	m.pruneFinished()
+	m.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+
+		m.WrappedLogger.LogInfof("Running %s job '%s' on '%s' ...", kind, job.status.Id, target)
+		result, err := fn(job, jobCtx)
+		job.finish(result, err, jobCtx)
+		if err != nil {
+			m.WrappedLogger.LogErrorf("Running %s job '%s' on '%s' ... failed, error: %w", kind, job.status.Id, target, err)
+			return
+		}
+		m.WrappedLogger.LogInfof("Running %s job '%s' on '%s' ... done", kind, job.status.Id, target)
+	}()
+
+	return job
+}
+
+// Get returns the status of the job.
+func (m *Manager) Get(jobId string) (Status, error) {
+	m.mutex.RLock()
+	job, ok := m.jobs[jobId]
+	m.mutex.RUnlock()
+	if !ok {
+		return Status{}, ErrJobNotFound
+	}
+	return job.Status(), nil
+}
+
+// List returns the status of the running and recently finished jobs, the most recent first.
+func (m *Manager) List() []Status {
+	m.mutex.RLock()
+	statuses := make([]Status, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		statuses = append(statuses, job.Status())
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.After(statuses[j].StartedAt) })
+	return statuses
+}
+
+// Running reports whether a job of the given kind is running on target.
+func (m *Manager) Running(kind string, target string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, job := range m.jobs {
+		status := job.Status()
+		if status.Kind == kind && status.Target == target && status.State == StateRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancel stops the job, canceling a finished job is not an error.
+func (m *Manager) Cancel(jobId string) error {
+	m.mutex.RLock()
+	job, ok := m.jobs[jobId]
+	m.mutex.RUnlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.cancel()
+	return nil
+}
+
+// pruneFinished forgets the oldest finished jobs beyond maxFinishedJobs, it must be called with the mutex held.
+func (m *Manager) pruneFinished() {
+	var finished []Status
+	for _, job := range m.jobs {
+		if status := job.Status(); status.State != StateRunning {
+			finished = append(finished, status)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.Before(*finished[j].FinishedAt) })
+	for _, status := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, status.Id)
+	}
+}
+
+func newJobId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))[:16]
+	}
+	return hex.EncodeToString(id)
+}
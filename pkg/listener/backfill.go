@@ -0,0 +1,132 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	inx "github.com/iotaledger/inx/go"
+)
+
+// ErrFilterNotFound is returned when no filter has the given id.
+var ErrFilterNotFound = errors.New("filter not found")
+
+// MilestoneRange selects the milestones of a backfill, either by index or by timestamp. The missing bounds stand for the oldest
+// milestone the node retains and for the last confirmed milestone.
+type MilestoneRange struct {
+	StartIndex uint32     `json:"startIndex,omitempty"`
+	EndIndex   uint32     `json:"endIndex,omitempty"`
+	StartTime  *time.Time `json:"startTime,omitempty"`
+	EndTime    *time.Time `json:"endTime,omitempty"`
+}
+
+// BackfillResult counts the blocks of a milestone checked against a filter by a backfill.
+type BackfillResult struct {
+	Checked int
+	Stored  int
+	Failed  int
+}
+
+func (r MilestoneRange) Validate() error {
+	if (r.StartIndex != 0 || r.EndIndex != 0) && (r.StartTime != nil || r.EndTime != nil) {
+		return errors.New("the milestone range is either given by index or by time")
+	}
+	if r.EndIndex != 0 && r.StartIndex > r.EndIndex {
+		return fmt.Errorf("invalid milestone range, start index %d is after end index %d", r.StartIndex, r.EndIndex)
+	}
+	if r.StartTime != nil && r.EndTime != nil && r.StartTime.After(*r.EndTime) {
+		return fmt.Errorf("invalid milestone range, start time %s is after end time %s", r.StartTime.Format(time.RFC3339), r.EndTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ResolveMilestoneRange returns the first and the last index of the milestones in the range which the node still retains.
+func ResolveMilestoneRange(milestoneRange MilestoneRange, client inx.INXClient, ctx context.Context) (uint32, uint32, error) {
+	if err := milestoneRange.Validate(); err != nil {
+		return 0, 0, err
+	}
+
+	status, err := client.ReadNodeStatus(ctx, &inx.NoParams{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't read node status, error: %w", err)
+	}
+	oldestIndex := status.GetTanglePruningIndex() + 1
+	confirmedIndex := status.GetConfirmedMilestone().GetMilestoneInfo().GetMilestoneIndex()
+	if confirmedIndex < oldestIndex {
+		return 0, 0, errors.New("the node retains no milestone")
+	}
+
+	startIndex, endIndex := oldestIndex, confirmedIndex
+	if milestoneRange.StartIndex > startIndex {
+		startIndex = milestoneRange.StartIndex
+	}
+	if milestoneRange.EndIndex != 0 && milestoneRange.EndIndex < endIndex {
+		endIndex = milestoneRange.EndIndex
+	}
+
+	// the milestone timestamps only grow, the bounds are found by binary search among the retained milestones
+	var searchErr error
+	timestampAt := func(i int) time.Time {
+		timestamp, err := GetMilestoneTimestamp(oldestIndex+uint32(i), client, ctx)
+		if err != nil && searchErr == nil {
+			searchErr = err
+		}
+		return time.Unix(int64(timestamp), 0)
+	}
+	retained := int(confirmedIndex - oldestIndex + 1)
+	if milestoneRange.StartTime != nil {
+		startIndex = oldestIndex + uint32(sort.Search(retained, func(i int) bool {
+			return !timestampAt(i).Before(*milestoneRange.StartTime)
+		}))
+	}
+	if milestoneRange.EndTime != nil {
+		// the index of the first milestone after the end time, minus one
+		endIndex = oldestIndex + uint32(sort.Search(retained, func(i int) bool {
+			return timestampAt(i).After(*milestoneRange.EndTime)
+		})) - 1
+	}
+	if searchErr != nil {
+		return 0, 0, fmt.Errorf("can't read milestone timestamps, error: %w", searchErr)
+	}
+
+	if startIndex > endIndex {
+		return 0, 0, fmt.Errorf("the node retains no milestone in the range, it retains milestones %d to %d", oldestIndex, confirmedIndex)
+	}
+	return startIndex, endIndex, nil
+}
+
+// BackfillMilestone checks the blocks referenced by the milestone against the filter, storing the matching ones in its bucket.
+func (l *Listener) BackfillMilestone(filter Filter, milestoneIndex uint32, client inx.INXClient, ctx context.Context) (BackfillResult, error) {
+	var result BackfillResult
+	err := readMilestoneCone(milestoneIndex, func(blockMetadata *inx.BlockMetadata) {
+		result.Checked++
+		taggedData, block, err := l.fetchBlock(blockMetadata.GetBlockId(), client, ctx)
+		if err != nil {
+			l.WrappedLogger.LogErrorf("Could not process block, error: %w", err)
+			result.Failed++
+			return
+		}
+		stored, err := l.storeIfMatching(filter, taggedData, block, blockMetadata, client, ctx)
+		if err != nil {
+			l.WrappedLogger.LogErrorf("Tagged data error: %w", err)
+			result.Failed++
+			return
+		}
+		if stored {
+			result.Stored++
+		}
+	}, client, ctx)
+	return result, err
+}
+
+// NewAdHocFilter returns a filter which is not listening to the new blocks, to backfill a bucket with the blocks it matches.
+func NewAdHocFilter(tag string, publicKey string, bucketName string, withPOI bool, keyLayout string) (Filter, error) {
+	filter, err := NewFilter(tag, publicKey, bucketName, "", withPOI, keyLayout, 0)
+	if err != nil {
+		return Filter{}, err
+	}
+	filter.setId()
+	return filter, nil
+}
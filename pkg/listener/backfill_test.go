@@ -0,0 +1,267 @@
+package listener
+
+import (
+	"collector/pkg/storage"
+	"context"
+	"encoding/hex"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+	inx "github.com/iotaledger/inx/go"
+	iotago "github.com/iotaledger/iota.go/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testMilestoneTimestamp returns the timestamp of the milestones served by testNode, every ten seconds.
+func testMilestoneTimestamp(index uint32) uint32 {
+	return 1680000000 + index*10
+}
+
+// testStream is a stream of referenced blocks, failing with err once its blocks are received.
+// A stream without error blocks until it is canceled.
+type testStream struct {
+	blocks []*inx.BlockMetadata
+	err    error
+}
+
+// testNode is an INX client serving the milestones and blocks of a tangle kept in memory, the calls it doesn't serve panic.
+type testNode struct {
+	inx.INXClient
+	pruningIndex   uint32
+	confirmedIndex uint32
+	blocks         map[iotago.BlockID]*iotago.Block
+	cones          map[uint32][]*inx.BlockMetadata
+
+	mutex sync.Mutex
+	// streams are served in turn by the calls of ListenToReferencedBlocks, the last one again once they are all served
+	streams []testStream
+	listens int
+}
+
+func newTestNode(pruningIndex uint32, confirmedIndex uint32) *testNode {
+	return &testNode{
+		pruningIndex:   pruningIndex,
+		confirmedIndex: confirmedIndex,
+		blocks:         make(map[iotago.BlockID]*iotago.Block),
+		cones:          make(map[uint32][]*inx.BlockMetadata),
+	}
+}
+
+// addBlock adds a block with the tagged data to the cone of the milestone, the block is only referenced if stored is false,
+// as a block the node can't read. It returns the metadata of the block.
+func (n *testNode) addBlock(t *testing.T, milestoneIndex uint32, tag string, data string, stored bool) *inx.BlockMetadata {
+	t.Helper()
+	block := &iotago.Block{
+		ProtocolVersion: 2,
+		Parents:         iotago.BlockIDs{{byte(milestoneIndex)}},
+		Payload:         &iotago.TaggedData{Tag: []byte(tag), Data: []byte(data)},
+	}
+	blockId, err := block.ID()
+	if err != nil {
+		t.Fatalf("can't compute block id, error: %s", err)
+	}
+	if stored {
+		n.blocks[blockId] = block
+	}
+	metadata := &inx.BlockMetadata{BlockId: inx.NewBlockId(blockId), ReferencedByMilestoneIndex: milestoneIndex}
+	n.cones[milestoneIndex] = append(n.cones[milestoneIndex], metadata)
+	return metadata
+}
+
+func (n *testNode) ReadNodeStatus(ctx context.Context, in *inx.NoParams, opts ...grpc.CallOption) (*inx.NodeStatus, error) {
+	return &inx.NodeStatus{
+		TanglePruningIndex: n.pruningIndex,
+		ConfirmedMilestone: &inx.Milestone{MilestoneInfo: &inx.MilestoneInfo{MilestoneIndex: n.confirmedIndex}},
+	}, nil
+}
+
+func (n *testNode) ReadMilestone(ctx context.Context, in *inx.MilestoneRequest, opts ...grpc.CallOption) (*inx.Milestone, error) {
+	index := in.GetMilestoneIndex()
+	if index <= n.pruningIndex || index > n.confirmedIndex {
+		return nil, status.Errorf(codes.NotFound, "milestone %d not found", index)
+	}
+	return &inx.Milestone{MilestoneInfo: &inx.MilestoneInfo{MilestoneIndex: index, MilestoneTimestamp: testMilestoneTimestamp(index)}}, nil
+}
+
+func (n *testNode) ReadMilestoneConeMetadata(ctx context.Context, in *inx.MilestoneRequest, opts ...grpc.CallOption) (inx.INX_ReadMilestoneConeMetadataClient, error) {
+	return &testConeStream{blocks: n.cones[in.GetMilestoneIndex()]}, nil
+}
+
+func (n *testNode) ReadBlock(ctx context.Context, in *inx.BlockId, opts ...grpc.CallOption) (*inx.RawBlock, error) {
+	block, ok := n.blocks[in.Unwrap()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "block %s not found", hex.EncodeToString(in.GetId()))
+	}
+	return inx.WrapBlock(block)
+}
+
+func (n *testNode) ListenToReferencedBlocks(ctx context.Context, in *inx.NoParams, opts ...grpc.CallOption) (inx.INX_ListenToReferencedBlocksClient, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	stream := n.streams[len(n.streams)-1]
+	if n.listens < len(n.streams) {
+		stream = n.streams[n.listens]
+	}
+	n.listens++
+	return &testBlockStream{testStream: stream, ctx: ctx}, nil
+}
+
+func (n *testNode) listenCount() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.listens
+}
+
+type testConeStream struct {
+	grpc.ClientStream
+	blocks []*inx.BlockMetadata
+}
+
+func (s *testConeStream) Recv() (*inx.BlockMetadata, error) {
+	if len(s.blocks) == 0 {
+		return nil, io.EOF
+	}
+	block := s.blocks[0]
+	s.blocks = s.blocks[1:]
+	return block, nil
+}
+
+type testBlockStream struct {
+	grpc.ClientStream
+	testStream
+	ctx context.Context
+}
+
+func (s *testBlockStream) Recv() (*inx.BlockMetadata, error) {
+	if len(s.blocks) > 0 {
+		block := s.blocks[0]
+		s.blocks = s.blocks[1:]
+		return block, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+// newTestListener returns a listener storing the blocks in the default bucket of a memory storage, whose pool is not running.
+func newTestListener(t *testing.T, params Parameters) *Listener {
+	t.Helper()
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	s := storage.NewStorageWithBackend(storage.NewMemoryBackend(), storage.Parameters{DefaultBucketName: "default"}, log)
+	if err := s.CreateBucket("default", context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := LoadCheckpoint("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Listener{
+		WrappedLogger: log,
+		Filters:       NewRegistry(),
+		Storage:       s,
+		Checkpoint:    checkpoint,
+		state:         newListenerState(),
+		pool:          newWorkerPool(params),
+	}
+}
+
+func TestResolveMilestoneRange(t *testing.T) {
+	node := newTestNode(9, 20)
+	timeOf := func(index uint32) *time.Time {
+		timestamp := time.Unix(int64(testMilestoneTimestamp(index)), 0)
+		return &timestamp
+	}
+	between := func(index uint32) *time.Time {
+		timestamp := timeOf(index).Add(5 * time.Second)
+		return &timestamp
+	}
+	tests := []struct {
+		name           string
+		milestoneRange MilestoneRange
+		wantStart      uint32
+		wantEnd        uint32
+		wantErr        bool
+	}{
+		{name: "every retained milestone", milestoneRange: MilestoneRange{}, wantStart: 10, wantEnd: 20},
+		{name: "index range", milestoneRange: MilestoneRange{StartIndex: 12, EndIndex: 15}, wantStart: 12, wantEnd: 15},
+		{name: "index range partly pruned", milestoneRange: MilestoneRange{StartIndex: 5, EndIndex: 15}, wantStart: 10, wantEnd: 15},
+		{name: "index range after the confirmed milestone", milestoneRange: MilestoneRange{StartIndex: 15, EndIndex: 30}, wantStart: 15, wantEnd: 20},
+		{name: "time range", milestoneRange: MilestoneRange{StartTime: timeOf(12), EndTime: between(14)}, wantStart: 12, wantEnd: 14},
+		{name: "time range between milestones", milestoneRange: MilestoneRange{StartTime: between(11), EndTime: timeOf(13)}, wantStart: 12, wantEnd: 13},
+		{name: "open time range", milestoneRange: MilestoneRange{StartTime: between(17)}, wantStart: 18, wantEnd: 20},
+		{name: "pruned index range", milestoneRange: MilestoneRange{StartIndex: 2, EndIndex: 8}, wantErr: true},
+		{name: "future index range", milestoneRange: MilestoneRange{StartIndex: 25}, wantErr: true},
+		{name: "time range between two milestones", milestoneRange: MilestoneRange{StartTime: between(12), EndTime: between(12)}, wantErr: true},
+		{name: "reversed index range", milestoneRange: MilestoneRange{StartIndex: 15, EndIndex: 12}, wantErr: true},
+		{name: "reversed time range", milestoneRange: MilestoneRange{StartTime: timeOf(15), EndTime: timeOf(12)}, wantErr: true},
+		{name: "index and time range", milestoneRange: MilestoneRange{StartIndex: 12, EndTime: timeOf(15)}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startIndex, endIndex, err := ResolveMilestoneRange(test.milestoneRange, node, context.Background())
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got milestones %d to %d", startIndex, endIndex)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if startIndex != test.wantStart || endIndex != test.wantEnd {
+				t.Errorf("milestones are %d to %d, want %d to %d", startIndex, endIndex, test.wantStart, test.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBackfillMilestone(t *testing.T) {
+	ctx := context.Background()
+	node := newTestNode(9, 20)
+	matching := node.addBlock(t, 12, "sensors", "temperature=21", true)
+	node.addBlock(t, 12, "other", "temperature=22", true)
+	node.addBlock(t, 12, "sensors", "temperature=23", false)
+	node.addBlock(t, 13, "sensors", "temperature=24", true)
+
+	l := newTestListener(t, Parameters{})
+	filter, err := NewAdHocFilter("sensors", "", "default", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an ad-hoc filter is not listening, the blocks are only stored by the backfill
+	if l.Filters.Snapshot().Len() != 0 {
+		t.Fatal("the ad-hoc filter is listening")
+	}
+
+	result, err := l.BackfillMilestone(filter, 12, node, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (BackfillResult{Checked: 3, Stored: 1, Failed: 1}) {
+		t.Errorf("result is %+v, want 3 blocks checked, 1 stored and 1 failed", result)
+	}
+
+	blockId := hex.EncodeToString(matching.GetBlockId().GetId())
+	objects, err := l.Storage.ListObjects("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != blockId {
+		t.Fatalf("objects are %v, want only '%s'", objects, blockId)
+	}
+	info, err := l.Storage.Backend().StatObject("default", blockId, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Metadata["milestone-index"] != "12" || info.Metadata["filter-id"] != filter.Id {
+		t.Errorf("metadata is %v, want milestone 12 and filter '%s'", info.Metadata, filter.Id)
+	}
+
+}
@@ -0,0 +1,188 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// checkpointFile is the content of the checkpoint file.
+type checkpointFile struct {
+	MilestoneIndex uint32 `json:"milestoneIndex"`
+}
+
+// Checkpoint keeps track of the last milestone whose referenced blocks were all processed, so that the milestones referenced
+// while the collector was stopped can be walked on startup. An empty path keeps the checkpoint in memory only.
+type Checkpoint struct {
+	path  string
+	mutex sync.Mutex
+	// saved is the last milestone index whose blocks were all processed
+	saved uint32
+	// sealed is the last milestone index whose blocks were all received
+	sealed uint32
+	// latest is the highest milestone index whose blocks are being received
+	latest uint32
+	// pending counts the blocks of each milestone which are still being processed
+	pending map[uint32]int
+	// walkNext is the first milestone of the running catch-up whose blocks were not all processed, 0 when no catch-up is running,
+	// and walkEnd the last milestone of the catch-up
+	walkNext uint32
+	walkEnd  uint32
+}
+
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{path: path, pending: make(map[uint32]int)}
+	if path == "" {
+		return checkpoint, nil
+	}
+
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read checkpoint file, error: %w", err)
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		return nil, fmt.Errorf("can't parse checkpoint file, error: %w", err)
+	}
+
+	checkpoint.saved = file.MilestoneIndex
+	checkpoint.sealed = file.MilestoneIndex
+	checkpoint.latest = file.MilestoneIndex
+	return checkpoint, nil
+}
+
+// Index returns the last milestone index whose blocks were all processed, 0 when none was.
+func (c *Checkpoint) Index() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.saved
+}
+
+// Received returns the last milestone index whose blocks were all received, and walked if a catch-up is running.
+func (c *Checkpoint) Received() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.walkNext != 0 && c.walkNext-1 < c.sealed {
+		return c.walkNext - 1
+	}
+	return c.sealed
+}
+
+// Seed starts the checkpoint from the given milestone when no milestone was processed yet, so that the milestones referenced
+// between the startup and the first block received are walked too.
+func (c *Checkpoint) Seed(milestoneIndex uint32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.saved != 0 {
+		return nil
+	}
+	c.saved = milestoneIndex
+	c.sealed = milestoneIndex
+	c.latest = milestoneIndex
+	return c.save(milestoneIndex)
+}
+
+// StartWalk records that the milestones from startIndex to endIndex are being walked while the new blocks are received,
+// so that the checkpoint doesn't advance past them until they are walked.
+func (c *Checkpoint) StartWalk(startIndex uint32, endIndex uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if startIndex > endIndex {
+		c.walkNext, c.walkEnd = 0, 0
+		return
+	}
+	c.walkNext, c.walkEnd = startIndex, endIndex
+}
+
+// Latest returns the highest milestone index whose blocks were received.
+func (c *Checkpoint) Latest() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.latest
+}
+
+// Begin records that a block referenced by the milestone is being processed. The blocks are received milestone after milestone,
+// so the previous milestones have no more blocks to come.
+func (c *Checkpoint) Begin(milestoneIndex uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if milestoneIndex > c.latest {
+		c.sealed = c.latest
+		c.latest = milestoneIndex
+	}
+	c.pending[milestoneIndex]++
+}
+
+// End records that a block referenced by the milestone was processed, and saves the checkpoint if a milestone is now complete.
+func (c *Checkpoint) End(milestoneIndex uint32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pending[milestoneIndex]--
+	if c.pending[milestoneIndex] <= 0 {
+		delete(c.pending, milestoneIndex)
+	}
+	return c.advance()
+}
+
+// Complete records that all the blocks referenced by the milestone were processed.
+func (c *Checkpoint) Complete(milestoneIndex uint32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if milestoneIndex > c.latest {
+		c.latest = milestoneIndex
+	}
+	if milestoneIndex > c.sealed {
+		c.sealed = milestoneIndex
+	}
+	if c.walkNext != 0 && milestoneIndex >= c.walkNext {
+		c.walkNext = milestoneIndex + 1
+		if c.walkNext > c.walkEnd {
+			c.walkNext, c.walkEnd = 0, 0
+		}
+	}
+	return c.advance()
+}
+
+// advance saves the last milestone whose blocks were all received and processed, if it is after the saved one.
+func (c *Checkpoint) advance() error {
+	processed := c.sealed
+	for milestoneIndex := range c.pending {
+		if milestoneIndex <= processed {
+			processed = milestoneIndex - 1
+		}
+	}
+	if c.walkNext != 0 && c.walkNext-1 < processed {
+		processed = c.walkNext - 1
+	}
+	if processed <= c.saved {
+		return nil
+	}
+	c.saved = processed
+	return c.save(processed)
+}
+
+// save writes the milestone index in the checkpoint file, if any.
+func (c *Checkpoint) save(milestoneIndex uint32) error {
+	if c.path == "" {
+		return nil
+	}
+
+	fileBytes, err := json.Marshal(checkpointFile{MilestoneIndex: milestoneIndex})
+	if err != nil {
+		return err
+	}
+	// the checkpoint is replaced at once, so that a crash can't leave it truncated
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, fileBytes, 0o600); err != nil {
+		return fmt.Errorf("can't write checkpoint file, error: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("can't write checkpoint file, error: %w", err)
+	}
+	return nil
+}
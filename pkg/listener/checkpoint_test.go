@@ -0,0 +1,198 @@
+package listener
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkpointStep is a call on the checkpoint, endIndex is only used by walk.
+type checkpointStep struct {
+	op       string
+	index    uint32
+	endIndex uint32
+}
+
+func TestCheckpointAdvance(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []checkpointStep
+		// wantIndex and wantReceived are the saved and received milestone indexes after the steps
+		wantIndex    uint32
+		wantReceived uint32
+	}{
+		{
+			name:         "no milestone processed",
+			steps:        nil,
+			wantIndex:    0,
+			wantReceived: 0,
+		},
+		{
+			name:         "seed",
+			steps:        []checkpointStep{{op: "seed", index: 10}},
+			wantIndex:    10,
+			wantReceived: 10,
+		},
+		{
+			name:         "seed doesn't move a saved checkpoint",
+			steps:        []checkpointStep{{op: "seed", index: 10}, {op: "seed", index: 20}},
+			wantIndex:    10,
+			wantReceived: 10,
+		},
+		{
+			name: "milestone still receiving blocks",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "begin", index: 11}, {op: "end", index: 11},
+			},
+			wantIndex:    10,
+			wantReceived: 10,
+		},
+		{
+			name: "milestone sealed by the next one",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "begin", index: 11}, {op: "begin", index: 11}, {op: "end", index: 11}, {op: "end", index: 11},
+				{op: "begin", index: 12},
+			},
+			wantIndex:    10,
+			wantReceived: 11,
+		},
+		{
+			name: "sealed milestone saved once its blocks are processed",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "begin", index: 11}, {op: "begin", index: 11},
+				{op: "begin", index: 12}, {op: "end", index: 11}, {op: "end", index: 12}, {op: "end", index: 11},
+			},
+			wantIndex:    11,
+			wantReceived: 11,
+		},
+		{
+			name: "blocks processed out of order",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "begin", index: 11}, {op: "begin", index: 12}, {op: "begin", index: 13},
+				{op: "end", index: 12},
+			},
+			wantIndex:    10,
+			wantReceived: 12,
+		},
+		{
+			name: "catch-up holds the checkpoint",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "walk", index: 11, endIndex: 13},
+				{op: "begin", index: 14}, {op: "end", index: 14}, {op: "begin", index: 15},
+				{op: "complete", index: 11},
+			},
+			wantIndex:    11,
+			wantReceived: 11,
+		},
+		{
+			name: "completed catch-up releases the checkpoint",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "walk", index: 11, endIndex: 13},
+				{op: "begin", index: 14}, {op: "end", index: 14}, {op: "begin", index: 15},
+				{op: "complete", index: 11}, {op: "complete", index: 12}, {op: "complete", index: 13},
+			},
+			wantIndex:    14,
+			wantReceived: 14,
+		},
+		{
+			name: "pruned milestones skipped by the catch-up",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "walk", index: 11, endIndex: 13},
+				{op: "begin", index: 14}, {op: "end", index: 14}, {op: "begin", index: 15},
+				{op: "walk", index: 13, endIndex: 13}, {op: "complete", index: 13},
+			},
+			wantIndex:    14,
+			wantReceived: 14,
+		},
+		{
+			name: "catch-up of pruned milestones only",
+			steps: []checkpointStep{
+				{op: "seed", index: 10},
+				{op: "walk", index: 11, endIndex: 13},
+				{op: "begin", index: 14}, {op: "end", index: 14}, {op: "begin", index: 15},
+				{op: "walk", index: 14, endIndex: 13}, {op: "begin", index: 15}, {op: "end", index: 15},
+			},
+			wantIndex:    14,
+			wantReceived: 14,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkpoint, err := LoadCheckpoint("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range test.steps {
+				switch step.op {
+				case "seed":
+					err = checkpoint.Seed(step.index)
+				case "walk":
+					checkpoint.StartWalk(step.index, step.endIndex)
+				case "begin":
+					checkpoint.Begin(step.index)
+				case "end":
+					err = checkpoint.End(step.index)
+				case "complete":
+					err = checkpoint.Complete(step.index)
+				default:
+					t.Fatalf("unknown step '%s'", step.op)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if index := checkpoint.Index(); index != test.wantIndex {
+				t.Errorf("index is %d, want %d", index, test.wantIndex)
+			}
+			if received := checkpoint.Received(); received != test.wantReceived {
+				t.Errorf("received is %d, want %d", received, test.wantReceived)
+			}
+		})
+	}
+}
+
+func TestCheckpointFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index := checkpoint.Index(); index != 0 {
+		t.Fatalf("index without checkpoint file is %d, want 0", index)
+	}
+	if err := checkpoint.Seed(10); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Begin(11)
+	checkpoint.Begin(12)
+	if err := checkpoint.End(11); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index := loaded.Index(); index != 11 {
+		t.Errorf("loaded index is %d, want 11", index)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary checkpoint file left behind, error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Error("expected an error for a corrupt checkpoint file")
+	}
+}
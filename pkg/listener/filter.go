@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"collector/pkg/storage"
 	"crypto"
 	"crypto/ed25519"
 	"crypto/md5"
@@ -19,6 +20,8 @@ type Filter struct {
 	BucketName       string `json:"bucketName,omitempty"`
 	WithPOI          bool   `json:"withPOI,omitempty"`
 	Duration         string `json:"duration,omitempty"`
+	KeyLayout        string `json:"keyLayout,omitempty"`
+	RetentionDays    int    `json:"retentionDays,omitempty"`
 	Expiration       time.Time
 	PublicKeyDecoded crypto.PublicKey
 }
@@ -27,13 +30,24 @@ type StartupFilters struct {
 	Filters []Filter `json:"filters"`
 }
 
-func NewFilter(tag string, publicKey string, bucketName string, duration string, withPOI bool) (Filter, error) {
+func NewFilter(tag string, publicKey string, bucketName string, duration string, withPOI bool, keyLayout string, retentionDays int) (Filter, error) {
 	filter := Filter{
-		Tag:        tag,
-		PublicKey:  publicKey,
-		BucketName: bucketName,
-		WithPOI:    withPOI,
-		Duration:   duration,
+		Tag:           tag,
+		PublicKey:     publicKey,
+		BucketName:    bucketName,
+		WithPOI:       withPOI,
+		Duration:      duration,
+		KeyLayout:     keyLayout,
+		RetentionDays: retentionDays,
+	}
+
+	err := storage.ValidateKeyLayout(filter.KeyLayout)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	if filter.RetentionDays < 0 {
+		return Filter{}, fmt.Errorf("invalid retention days %d", filter.RetentionDays)
 	}
 
 	if filter.PublicKey != "" {
@@ -100,6 +114,10 @@ func UnmarshalStartupFilters(filtersString string) ([]Filter, error) {
 		if err != nil {
 			return filters.Filters, err
 		}
+		err = storage.ValidateKeyLayout(filter.KeyLayout)
+		if err != nil {
+			return filters.Filters, err
+		}
 
 	}
 
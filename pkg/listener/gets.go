@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"sync"
 
 	"github.com/iotaledger/hive.go/serializer/v2"
 	inx "github.com/iotaledger/inx/go"
@@ -75,3 +76,93 @@ func GetObjectFromTangleBlock(blockId string, client inx.INXClient, ctx context.
 
 	return object, nil
 }
+
+// milestoneTimestampCacheSize is the number of milestones whose timestamp is kept, enough for the milestones processed at the same time.
+const milestoneTimestampCacheSize = 1024
+
+// milestoneTimestamps caches the timestamps of the milestones read from the node, which never change,
+// so that the node is asked once for all the blocks referenced by a milestone.
+var milestoneTimestamps = newTimestampCache(milestoneTimestampCacheSize)
+
+// timestampCache keeps the timestamps of the last milestones read, dropping the oldest read when it is full.
+type timestampCache struct {
+	mutex      sync.Mutex
+	size       int
+	timestamps map[uint32]uint32
+	order      []uint32
+}
+
+func newTimestampCache(size int) *timestampCache {
+	return &timestampCache{size: size, timestamps: make(map[uint32]uint32, size)}
+}
+
+func (c *timestampCache) get(index uint32) (uint32, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timestamp, ok := c.timestamps[index]
+	return timestamp, ok
+}
+
+func (c *timestampCache) put(index uint32, timestamp uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.timestamps[index]; ok {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.timestamps, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.timestamps[index] = timestamp
+	c.order = append(c.order, index)
+}
+
+// GetMilestoneTimestamp returns the timestamp of the milestone with the given index.
+func GetMilestoneTimestamp(index uint32, client inx.INXClient, ctx context.Context) (uint32, error) {
+	if timestamp, ok := milestoneTimestamps.get(index); ok {
+		return timestamp, nil
+	}
+	milestone, err := client.ReadMilestone(ctx, &inx.MilestoneRequest{MilestoneIndex: index})
+	if err != nil {
+		return 0, err
+	}
+	timestamp := milestone.GetMilestoneInfo().GetMilestoneTimestamp()
+	milestoneTimestamps.put(index, timestamp)
+	return timestamp, nil
+}
+
+// GetObjectAttributes returns the attributes of the block used to build the key of its object.
+func GetObjectAttributes(blockId string, client inx.INXClient, ctx context.Context) (storage.ObjectAttributes, error) {
+	var blockID inx.BlockId
+	var err error
+	blockID.Id, err = hex.DecodeString(blockId)
+	if err != nil {
+		return storage.ObjectAttributes{BlockId: blockId}, err
+	}
+
+	metadata, err := client.ReadBlockMetadata(ctx, &blockID)
+	if err != nil {
+		return storage.ObjectAttributes{BlockId: blockId}, err
+	}
+	attributes, err := GetObjectAttributesFromMetadata(metadata, client, ctx)
+	attributes.BlockId = blockId
+	return attributes, err
+}
+
+// GetObjectAttributesFromMetadata returns the attributes of the block used to build the key of its object, from the metadata already read.
+func GetObjectAttributesFromMetadata(metadata *inx.BlockMetadata, client inx.INXClient, ctx context.Context) (storage.ObjectAttributes, error) {
+	attributes := storage.ObjectAttributes{
+		BlockId:        hex.EncodeToString(metadata.GetBlockId().GetId()),
+		MilestoneIndex: metadata.GetReferencedByMilestoneIndex(),
+	}
+
+	// blocks not yet referenced have no milestone
+	if attributes.MilestoneIndex != 0 {
+		var err error
+		attributes.MilestoneTimestamp, err = GetMilestoneTimestamp(attributes.MilestoneIndex, client, ctx)
+		if err != nil {
+			return attributes, err
+		}
+	}
+	return attributes, nil
+}
@@ -6,8 +6,12 @@ import (
 	"context"
 	"crypto"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/iotaledger/datapayloads.go"
 	"github.com/iotaledger/hive.go/core/logger"
@@ -17,13 +21,19 @@ import (
 
 type Listener struct {
 	*logger.WrappedLogger
-	Filters        map[string]Filter
-	Storage        storage.Storage
+	Filters        *Registry
+	Storage        *storage.Storage
 	POIHandler     poi.POIHandler
 	StartupFilters []Filter
+	Checkpoint     *Checkpoint
+
+	state               *listenerState
+	pool                *workerPool
+	reconnectMinBackoff time.Duration
+	reconnectMaxBackoff time.Duration
 }
 
-func NewListener(params Parameters, storage storage.Storage, poiHandler poi.POIHandler, log *logger.WrappedLogger) (Listener, error) {
+func NewListener(params Parameters, storage *storage.Storage, poiHandler poi.POIHandler, log *logger.WrappedLogger) (Listener, error) {
 	var filters []Filter
 	var err error
 
@@ -34,54 +44,295 @@ func NewListener(params Parameters, storage storage.Storage, poiHandler poi.POIH
 		}
 	}
 
+	checkpoint, err := LoadCheckpoint(params.CheckpointFile)
+	if err != nil {
+		return Listener{}, err
+	}
+
 	listener := Listener{
 		WrappedLogger:  logger.NewWrappedLogger(log.LoggerNamed("Listener")),
-		Filters:        make(map[string]Filter),
+		Filters:        NewRegistry(),
 		Storage:        storage,
 		POIHandler:     poiHandler,
 		StartupFilters: filters,
+		Checkpoint:     checkpoint,
+
+		state:               newListenerState(),
+		pool:                newWorkerPool(params),
+		reconnectMinBackoff: params.Reconnect.MinBackoff,
+		reconnectMaxBackoff: params.Reconnect.MaxBackoff,
 	}
 	return listener, err
 }
 
 func (l *Listener) Run(client inx.INXClient, ctx context.Context) error {
+	l.pool.run(l.processTask, ctx)
+
+	// the milestones referenced while the collector was stopped are walked alongside the new ones
+	fromIndex := l.Checkpoint.Index()
+	backoff := l.reconnectMinBackoff
+	for {
+		received, err := l.listen(fromIndex, client, ctx)
+		if ctx.Err() != nil {
+			l.state.set(StateStopped, nil)
+			return nil
+		}
+		if !isTransientError(err) {
+			l.state.set(StateFailed, err)
+			return err
+		}
+
+		if received {
+			backoff = l.reconnectMinBackoff
+		}
+		l.state.set(StateReconnecting, err)
+		l.WrappedLogger.LogWarnf("Listening to referenced blocks ... failed, listening again in %s, error: %s", backoff, err)
+		select {
+		case <-ctx.Done():
+			l.state.set(StateStopped, nil)
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > l.reconnectMaxBackoff {
+			backoff = l.reconnectMaxBackoff
+		}
+
+		// the milestones referenced while the stream was down are walked, from the last one whose blocks were only partly received
+		if receivedIndex := l.Checkpoint.Received(); receivedIndex > fromIndex {
+			fromIndex = receivedIndex
+		}
+	}
+}
+
+// listen processes the referenced blocks streamed by the node until the stream fails, walking the milestones referenced after fromIndex
+// alongside them, and reports whether any block was received.
+func (l *Listener) listen(fromIndex uint32, client inx.INXClient, ctx context.Context) (bool, error) {
+	if fromIndex == 0 {
+		var err error
+		if fromIndex, err = l.seedCheckpoint(client, ctx); err != nil {
+			return false, err
+		}
+	}
+
+	// the stream is cancelled when the catch-up fails, so that both are started again
+	streamCtx, cancel := context.WithCancel(ctx)
+	walkDone := make(chan struct{})
+	var walkErr error
+	walking := false
+	defer func() {
+		cancel()
+		if walking {
+			<-walkDone
+		}
+	}()
+
 	// Listen to all referenced blocks
-	stream, err := client.ListenToReferencedBlocks(ctx, &inx.NoParams{})
+	stream, err := client.ListenToReferencedBlocks(streamCtx, &inx.NoParams{})
 	if err != nil {
-		return err
+		return false, err
 	}
+	l.state.set(StateConnected, nil)
 
+	received := false
 	for {
 		newBlock, err := stream.Recv()
 		if err != nil {
-			l.WrappedLogger.LogErrorf("Could not receive block, error: %w", err)
+			if walking {
+				select {
+				case <-walkDone:
+					if walkErr != nil {
+						return received, walkErr
+					}
+				default:
+				}
+			}
+			return received, err
+		}
+		received = true
+		l.state.blockReceived()
+
+		milestoneIndex := newBlock.GetReferencedByMilestoneIndex()
+		// the blocks of the milestones already processed are skipped
+		if milestoneIndex <= fromIndex {
 			continue
 		}
-		// we do something only if we have filters
-		if len(l.Filters) == 0 {
+		// the milestones referenced between fromIndex and the first block received are walked while the new blocks are processed
+		if fromIndex != 0 && milestoneIndex > fromIndex+1 {
+			startIndex, endIndex := fromIndex+1, milestoneIndex-1
+			walking = true
+			l.Checkpoint.StartWalk(startIndex, endIndex)
+			go func() {
+				err := l.catchUp(startIndex, endIndex, client, streamCtx)
+				walkErr = err
+				close(walkDone)
+				if err != nil {
+					cancel()
+				}
+			}()
+		}
+		fromIndex = 0
+
+		if err := l.queueBlock(newBlock, client, streamCtx); err != nil {
+			return received, err
+		}
+	}
+}
+
+// queueBlock records the block in the checkpoint and queues it for the workers, the stream is not read until there is room in the queue.
+// A block which can't be queued is recorded as processed, so that the checkpoint doesn't wait for it forever: its milestone is the latest
+// one received and is not sealed, so it is walked again when listening again.
+func (l *Listener) queueBlock(blockMetadata *inx.BlockMetadata, client inx.INXClient, ctx context.Context) error {
+	milestoneIndex := blockMetadata.GetReferencedByMilestoneIndex()
+	l.Checkpoint.Begin(milestoneIndex)
+	// we do something only if we have filters
+	filters := l.Filters.Snapshot()
+	if filters.Len() == 0 {
+		l.endBlock(milestoneIndex)
+		return nil
+	}
+	err := l.pool.submit(blockTask{blockMetadata: blockMetadata, filters: filters, client: client}, ctx)
+	if err != nil {
+		l.endBlock(milestoneIndex)
+		return err
+	}
+	return nil
+}
+
+// processTask fetches the queued block from the node and checks it against the filters.
+func (l *Listener) processTask(task blockTask, ctx context.Context) {
+	defer l.endBlock(task.blockMetadata.GetReferencedByMilestoneIndex())
+	taggedData, block, err := l.fetchBlock(task.blockMetadata.GetBlockId(), task.client, ctx)
+	if err != nil {
+		l.WrappedLogger.LogErrorf("Could not process block, error: %w", err)
+		return
+	}
+	l.processBlock(task.filters, taggedData, block, task.blockMetadata, task.client, ctx)
+}
+
+// fetchBlock reads the block and its tagged data from the node, within the concurrency limit of the fetch stage.
+func (l *Listener) fetchBlock(blockId *inx.BlockId, client inx.INXClient, ctx context.Context) (iotago.TaggedData, *iotago.Block, error) {
+	var taggedData iotago.TaggedData
+	var block *iotago.Block
+	err := l.pool.stage(StageFetch, func() error {
+		var err error
+		taggedData, block, err = GetTaggedDataFromId(blockId, client, ctx)
+		return err
+	}, ctx)
+	return taggedData, block, err
+}
+
+// processBlock checks the block against the filters listening to its tag, storing it for each filter it matches.
+func (l *Listener) processBlock(filters *FilterSnapshot, taggedData iotago.TaggedData, block *iotago.Block, blockMetadata *inx.BlockMetadata, client inx.INXClient, ctx context.Context) {
+	for _, filter := range filters.ForTag(string(taggedData.Tag)) {
+		err := l.checkAndStore(taggedData, filter, block, blockMetadata, client, ctx)
+		if err != nil {
+			l.WrappedLogger.LogErrorf("Tagged data error: %w", err)
 			continue
 		}
-		// get tagged data
-		blockId := newBlock.GetBlockId()
-		taggedData, block, err := GetTaggedDataFromId(blockId, client, ctx)
+	}
+}
+
+// endBlock records in the checkpoint that a block referenced by the milestone was processed.
+func (l *Listener) endBlock(milestoneIndex uint32) {
+	if err := l.Checkpoint.End(milestoneIndex); err != nil {
+		l.WrappedLogger.LogWarnf("Can't save checkpoint, error: %w", err)
+	}
+}
+
+// seedCheckpoint starts the checkpoint from the milestone confirmed by the node when there is no milestone to resume from,
+// and returns the milestone index to resume from.
+func (l *Listener) seedCheckpoint(client inx.INXClient, ctx context.Context) (uint32, error) {
+	status, err := client.ReadNodeStatus(ctx, &inx.NoParams{})
+	if err != nil {
+		return 0, fmt.Errorf("can't read node status, error: %w", err)
+	}
+	confirmedIndex := status.GetConfirmedMilestone().GetMilestoneInfo().GetMilestoneIndex()
+	if err := l.Checkpoint.Seed(confirmedIndex); err != nil {
+		l.WrappedLogger.LogWarnf("Can't save checkpoint, error: %w", err)
+	}
+	return l.Checkpoint.Index(), nil
+}
+
+// catchUp walks the cones of the milestones from startIndex to endIndex, as far back as the node didn't prune them.
+func (l *Listener) catchUp(startIndex uint32, endIndex uint32, client inx.INXClient, ctx context.Context) error {
+	status, err := client.ReadNodeStatus(ctx, &inx.NoParams{})
+	if err != nil {
+		return fmt.Errorf("can't read node status, error: %w", err)
+	}
+	if pruningIndex := status.GetTanglePruningIndex(); startIndex <= pruningIndex {
+		l.WrappedLogger.LogWarnf("Milestones %d to %d were pruned by the node, their blocks are not checked against the filters", startIndex, pruningIndex)
+		startIndex = pruningIndex + 1
+		l.Checkpoint.StartWalk(startIndex, endIndex)
+	}
+	if startIndex > endIndex {
+		return nil
+	}
+
+	l.state.set(StateCatchingUp, nil)
+	if err := l.walkMilestones(startIndex, endIndex, client, ctx); err != nil {
+		return err
+	}
+	l.state.set(StateConnected, nil)
+	return nil
+}
+
+// walkMilestones checks the blocks referenced by the milestones against the filters, milestone after milestone.
+func (l *Listener) walkMilestones(startIndex uint32, endIndex uint32, client inx.INXClient, ctx context.Context) error {
+	l.WrappedLogger.LogInfof("Walking milestones %d to %d ...", startIndex, endIndex)
+	for milestoneIndex := startIndex; milestoneIndex <= endIndex; milestoneIndex++ {
+		err := l.walkMilestoneCone(milestoneIndex, client, ctx)
+		if err != nil {
+			l.WrappedLogger.LogErrorf("Walking milestones %d to %d ... failed, error: %w", startIndex, endIndex, err)
+			return err
+		}
+	}
+	l.WrappedLogger.LogInfof("Walking milestones %d to %d ... done", startIndex, endIndex)
+	return nil
+}
+
+// walkMilestoneCone checks the blocks referenced by the milestone against the filters.
+func (l *Listener) walkMilestoneCone(milestoneIndex uint32, client inx.INXClient, ctx context.Context) error {
+	err := readMilestoneCone(milestoneIndex, func(blockMetadata *inx.BlockMetadata) {
+		filters := l.Filters.Snapshot()
+		if filters.Len() == 0 {
+			return
+		}
+		taggedData, block, err := l.fetchBlock(blockMetadata.GetBlockId(), client, ctx)
 		if err != nil {
 			l.WrappedLogger.LogErrorf("Could not process block, error: %w", err)
-			continue
+			return
 		}
-		// starts a routine to manage the tagged payload and keeps listening
-		go func(filters map[string]Filter, taggedData iotago.TaggedData, block iotago.Block, blockId inx.BlockId, c context.Context) {
-			for filterId := range filters {
-				err := l.checkAndStore(taggedData, filterId, &block, blockId, ctx)
-				if err != nil {
-					l.WrappedLogger.LogErrorf("Tagged data error: %w", err)
-					continue
-				}
-			}
-		}(l.Filters, taggedData, *block, *blockId, ctx)
+		l.processBlock(filters, taggedData, block, blockMetadata, client, ctx)
+	}, client, ctx)
+	if err != nil {
+		return err
+	}
+	if err := l.Checkpoint.Complete(milestoneIndex); err != nil {
+		l.WrappedLogger.LogWarnf("Can't save checkpoint, error: %w", err)
+	}
+	return nil
+}
+
+// readMilestoneCone calls fn with the metadata of each block referenced by the milestone.
+func readMilestoneCone(milestoneIndex uint32, fn func(blockMetadata *inx.BlockMetadata), client inx.INXClient, ctx context.Context) error {
+	stream, err := client.ReadMilestoneConeMetadata(ctx, &inx.MilestoneRequest{MilestoneIndex: milestoneIndex})
+	if err != nil {
+		return fmt.Errorf("can't read cone of milestone %d, error: %w", milestoneIndex, err)
+	}
+	for {
+		blockMetadata, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("can't read cone of milestone %d, error: %w", milestoneIndex, err)
+		}
+		fn(blockMetadata)
 	}
 }
 
-func (l *Listener) AddFilter(filter Filter) (string, error) {
+func (l *Listener) AddFilter(filter Filter, ctx context.Context) (string, error) {
 	// sets filter expiration
 	if filter.Duration != "" {
 		err := filter.setExpiration()
@@ -99,14 +350,21 @@ func (l *Listener) AddFilter(filter Filter) (string, error) {
 	}
 
 	filter.setId()
-	for _, f := range l.Filters {
-		if f.Id == filter.Id {
-			err := fmt.Errorf("Filter id '%s' already exists", filter.Id)
-			return "", err
+	if err := l.Filters.Add(filter); err != nil {
+		return "", err
+	}
+
+	// the objects stored by the filter expire through a lifecycle rule matching their filter-id tag,
+	// set once the filter is added so that a concurrent add of the same filter never touches it
+	if filter.RetentionDays > 0 {
+		rule := storage.NewFilterLifecycleRule(filter.Id, filter.RetentionDays)
+		_, err := l.Storage.ReconcileBucketLifecycle(filter.BucketName, []storage.LifecycleRule{rule}, ctx)
+		if err != nil {
+			l.Filters.Remove(filter.Id)
+			return "", fmt.Errorf("can't set retention of filter '%s', error: %w", filter.Id, err)
 		}
 	}
 
-	l.Filters[filter.Id] = filter
 	if filter.PublicKeyDecoded == nil {
 		l.WrappedLogger.LogInfof("Filter '%s' added, listening on tag: '%s'", filter.Id, filter.Tag)
 	} else {
@@ -115,13 +373,44 @@ func (l *Listener) AddFilter(filter Filter) (string, error) {
 	return filter.Id, nil
 }
 
-func (l *Listener) RemoveFilter(filterId string) error {
-	tag := l.Filters[filterId].Tag
-	delete(l.Filters, filterId)
-	l.WrappedLogger.LogInfof("Filter '%s' added, is no longer listening on tag: '%s'", filterId, tag)
+func (l *Listener) RemoveFilter(filterId string, ctx context.Context) error {
+	filter, ok := l.Filters.Remove(filterId)
+	if !ok {
+		return ErrFilterNotFound
+	}
+	l.WrappedLogger.LogInfof("Filter '%s' removed, is no longer listening on tag: '%s'", filterId, filter.Tag)
+	l.removeRetention(filter, ctx)
 	return nil
 }
 
+// removeRetention removes the lifecycle rule expiring the objects stored by the filter, if it has one.
+func (l *Listener) removeRetention(filter Filter, ctx context.Context) {
+	if filter.RetentionDays == 0 {
+		return
+	}
+	rule := storage.NewFilterLifecycleRule(filter.Id, 0)
+	_, err := l.Storage.ReconcileBucketLifecycle(filter.BucketName, []storage.LifecycleRule{rule}, ctx)
+	if err != nil {
+		l.WrappedLogger.LogWarnf("Can't remove retention of filter '%s', error: %s", filter.Id, err)
+	}
+}
+
+// GetFilter returns the filter with the given id.
+func (l *Listener) GetFilter(filterId string) (Filter, bool) {
+	return l.Filters.Snapshot().Get(filterId)
+}
+
+// FiltersForBucket returns the ids of the filters storing their blocks in the bucket.
+func (l *Listener) FiltersForBucket(bucketName string) []string {
+	filterIds := []string{}
+	for _, filter := range l.Filters.Snapshot().All() {
+		if filter.BucketName == bucketName {
+			filterIds = append(filterIds, filter.Id)
+		}
+	}
+	return filterIds
+}
+
 func (l *Listener) LoadStartupFilters(ctx context.Context) error {
 	for _, filter := range l.StartupFilters {
 		// use default bucket if none
@@ -140,67 +429,134 @@ func (l *Listener) LoadStartupFilters(ctx context.Context) error {
 				return err
 			}
 		}
-		l.AddFilter(filter)
+		if _, err := l.AddFilter(filter, ctx); err != nil {
+			l.WrappedLogger.LogErrorf("Can't deploy startup filter on tag '%s' : %w", filter.Tag, err)
+		}
 	}
+	l.removeStaleRetentions(ctx)
 	return nil
 }
 
-func (l *Listener) checkFilterExpired(filterId string) bool {
-	filter := l.Filters[filterId]
+// removeStaleRetentions removes the lifecycle rules of the filters which no longer exist, such as the filters of a previous run
+// whose ids changed with their expiration, so that the rules don't pile up in the buckets.
+func (l *Listener) removeStaleRetentions(ctx context.Context) {
+	buckets, err := l.Storage.ListBuckets(ctx)
+	if err != nil {
+		l.WrappedLogger.LogWarnf("Can't list the buckets to remove the retentions of former filters, error: %s", err)
+		return
+	}
+	for _, bucket := range buckets {
+		rules, err := l.Storage.GetBucketLifecycle(bucket.Name, ctx)
+		if err != nil {
+			l.WrappedLogger.LogWarnf("Can't read lifecycle of bucket '%s', error: %s", bucket.Name, err)
+			continue
+		}
+		// the rule of a filter is set once the filter is added, so the filters added meanwhile are in the snapshot
+		filters := l.Filters.Snapshot()
+		stale := []storage.LifecycleRule{}
+		for _, rule := range rules {
+			if !strings.HasPrefix(rule.Id, storage.FilterLifecycleRulePrefix) {
+				continue
+			}
+			filterId := strings.TrimPrefix(rule.Id, storage.FilterLifecycleRulePrefix)
+			if _, ok := filters.Get(filterId); !ok {
+				stale = append(stale, storage.NewFilterLifecycleRule(filterId, 0))
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		if _, err := l.Storage.ReconcileBucketLifecycle(bucket.Name, stale, ctx); err != nil {
+			l.WrappedLogger.LogWarnf("Can't remove the retentions of former filters from bucket '%s', error: %s", bucket.Name, err)
+		}
+	}
+}
+
+func (l *Listener) checkFilterExpired(filter Filter, ctx context.Context) bool {
 	filterExpired := filter.IsExpired()
 	if filterExpired {
-		l.RemoveFilter(filterId)
+		// the workers holding an older snapshot may find the filter expired too, only the first one removes it
+		if _, ok := l.Filters.Remove(filter.Id); ok {
+			l.WrappedLogger.LogInfof("Filter '%s' expired, with tag: '%s'", filter.Id, filter.Tag)
+			l.removeRetention(filter, ctx)
+		}
 	}
 	return filterExpired
 }
 
-func (l *Listener) checkAndStore(taggedData iotago.TaggedData, filterId string, block *iotago.Block, blockId inx.BlockId, ctx context.Context) error {
-	var err error
-	filter := l.Filters[filterId]
-	if string(taggedData.Tag) == filter.Tag {
-		if filter.Duration != "" {
-			// checks if the filter expired, if it is, skips and removes the filter
-			if l.checkFilterExpired(filterId) {
-				l.WrappedLogger.LogInfof("Filter '%s' expired, with tag: '%s'", filter.Id, filter.Tag)
-				return nil
-			}
+func (l *Listener) checkAndStore(taggedData iotago.TaggedData, filter Filter, block *iotago.Block, blockMetadata *inx.BlockMetadata, client inx.INXClient, ctx context.Context) error {
+	if string(taggedData.Tag) == filter.Tag && filter.Duration != "" {
+		// checks if the filter expired, if it is, skips and removes the filter
+		if l.checkFilterExpired(filter, ctx) {
+			return nil
 		}
+	}
+	_, err := l.storeIfMatching(filter, taggedData, block, blockMetadata, client, ctx)
+	return err
+}
 
-		// checks if the filter has a specified public key, if it does it verifies the data
-		if filter.PublicKeyDecoded != nil {
+// storeIfMatching stores the block in the bucket of the filter if its tagged data matches the tag and the public key of the filter,
+// and reports whether it was stored.
+func (l *Listener) storeIfMatching(filter Filter, taggedData iotago.TaggedData, block *iotago.Block, blockMetadata *inx.BlockMetadata, client inx.INXClient, ctx context.Context) (bool, error) {
+	var err error
+	if string(taggedData.Tag) != filter.Tag {
+		return false, nil
+	}
 
-			// check if this payload is a signed payload compliant to the filter specification
-			signedPayload, err := getSubscribedSignedPayload(taggedData, filter.PublicKeyDecoded)
-			if err != nil {
-				l.WrappedLogger.LogInfof("Discarding unsubscribed payload")
-				return nil
-			}
+	// checks if the filter has a specified public key, if it does it verifies the data
+	if filter.PublicKeyDecoded != nil {
 
-			// verifies signature
-			err = signedPayload.VerifySignature()
-			if err != nil {
-				l.WrappedLogger.LogWarnf("Discarding a subscribed payload with invalid signature")
-				return nil
-			}
+		// check if this payload is a signed payload compliant to the filter specification
+		signedPayload, err := getSubscribedSignedPayload(taggedData, filter.PublicKeyDecoded)
+		if err != nil {
+			l.WrappedLogger.LogInfof("Discarding unsubscribed payload")
+			return false, nil
+		}
+
+		// verifies signature
+		err = signedPayload.VerifySignature()
+		if err != nil {
+			l.WrappedLogger.LogWarnf("Discarding a subscribed payload with invalid signature")
+			return false, nil
 		}
+	}
 
-		blockIdStr := hex.EncodeToString(blockId.GetId())
-		var object storage.Object
-		if filter.WithPOI {
+	blockIdStr := hex.EncodeToString(blockMetadata.GetBlockId().GetId())
+	var object storage.Object
+	if filter.WithPOI {
+		err = l.pool.stage(StagePOI, func() error {
 			object, err = GetObjectFromTanglePOI(blockIdStr, l.POIHandler)
-			if err != nil {
-				return err
-			}
-		} else {
-			object.Block = block
+			return err
+		}, ctx)
+		if err != nil {
+			return false, err
 		}
-		err = l.Storage.UploadObject(blockIdStr, filter.BucketName, object, ctx)
+	} else {
+		object.Block = block
+	}
+
+	attributes := storage.ObjectAttributes{
+		BlockId:        blockIdStr,
+		Tag:            taggedData.Tag,
+		FilterId:       filter.Id,
+		MilestoneIndex: blockMetadata.GetReferencedByMilestoneIndex(),
+		KeyLayout:      filter.KeyLayout,
+	}
+	// the proof of inclusion already carries the milestone timestamp
+	if object.Milestone == nil {
+		attributes.MilestoneTimestamp, err = GetMilestoneTimestamp(attributes.MilestoneIndex, client, ctx)
 		if err != nil {
-			err = fmt.Errorf("can't upload the block '%s', error: %w", blockIdStr, err)
-			return err
+			l.WrappedLogger.LogWarnf("Can't read milestone %d, storing block '%s' with the current time, error: %w", attributes.MilestoneIndex, blockIdStr, err)
 		}
 	}
-	return nil
+	err = l.pool.stage(StageUpload, func() error {
+		return l.Storage.StoreObject(attributes, filter.BucketName, object, ctx)
+	}, ctx)
+	if err != nil {
+		err = fmt.Errorf("can't upload the block '%s', error: %w", blockIdStr, err)
+		return false, err
+	}
+	return true, nil
 }
 
 func getSubscribedSignedPayload(taggedData iotago.TaggedData, expectedPublicKey crypto.PublicKey) (*datapayloads.SignedDataContainer, error) {
@@ -0,0 +1,171 @@
+package listener
+
+import (
+	"collector/pkg/storage"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+	inx "github.com/iotaledger/inx/go"
+)
+
+func TestFilterRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		retentionDays int
+		// wantRule reports whether the filter has a lifecycle rule while it is added
+		wantRule bool
+	}{
+		{name: "no retention", retentionDays: 0, wantRule: false},
+		{name: "retention", retentionDays: 7, wantRule: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := logger.NewWrappedLogger(logger.NewNopLogger())
+			s := storage.NewStorageWithBackend(storage.NewMemoryBackend(), storage.Parameters{DefaultBucketName: "default"}, log)
+			if err := s.CreateBucket("default", ctx); err != nil {
+				t.Fatal(err)
+			}
+			l := &Listener{WrappedLogger: log, Filters: NewRegistry(), Storage: s}
+
+			filter, err := NewFilter("sensors", "", "default", "", false, "", test.retentionDays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filterId, err := l.AddFilter(filter, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasRule := hasFilterRule(t, s, filterId); hasRule != test.wantRule {
+				t.Errorf("filter has a lifecycle rule: %t, want %t", hasRule, test.wantRule)
+			}
+
+			// adding the same filter again fails and leaves its rule in place
+			if _, err := l.AddFilter(filter, ctx); err == nil {
+				t.Error("expected an error adding the filter twice")
+			}
+			if hasRule := hasFilterRule(t, s, filterId); hasRule != test.wantRule {
+				t.Errorf("filter has a lifecycle rule after the second add: %t, want %t", hasRule, test.wantRule)
+			}
+
+			if err := l.RemoveFilter(filterId, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if hasFilterRule(t, s, filterId) {
+				t.Error("the lifecycle rule of the removed filter is left")
+			}
+			if err := l.RemoveFilter(filterId, ctx); !errors.Is(err, ErrFilterNotFound) {
+				t.Errorf("error is %v removing the filter twice, want %v", err, ErrFilterNotFound)
+			}
+		})
+	}
+}
+
+func hasFilterRule(t *testing.T, s *storage.Storage, filterId string) bool {
+	t.Helper()
+	rules, err := s.GetBucketLifecycle("default", context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range rules {
+		if rule.Id == storage.FilterLifecycleRulePrefix+filterId {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadStartupFiltersRemovesStaleRetentions(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	s := storage.NewStorageWithBackend(storage.NewMemoryBackend(), storage.Parameters{DefaultBucketName: "default"}, log)
+	if err := s.CreateBucket("default", ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the rule of a filter of a previous run, whose id changed with its expiration, and a rule set by the users
+	userRule := storage.LifecycleRule{Id: "short-lived", Prefix: "sensors/", Days: 7}
+	rules := []storage.LifecycleRule{storage.NewFilterLifecycleRule("former", 30), userRule}
+	if _, err := s.ReconcileBucketLifecycle("default", rules, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := NewFilter("sensors", "", "", "", false, "", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Listener{WrappedLogger: log, Filters: NewRegistry(), Storage: s, StartupFilters: []Filter{filter}}
+	if err := l.LoadStartupFilters(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := l.Filters.Snapshot().All()
+	if len(filters) != 1 {
+		t.Fatalf("%d filters loaded, want 1", len(filters))
+	}
+	if !hasFilterRule(t, s, filters[0].Id) {
+		t.Error("the lifecycle rule of the startup filter is missing")
+	}
+	if hasFilterRule(t, s, "former") {
+		t.Error("the lifecycle rule of the former filter is left")
+	}
+	got, err := s.GetBucketLifecycle("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Id != userRule.Id {
+		t.Errorf("rules are %v, want the rule of the startup filter and '%s'", got, userRule.Id)
+	}
+}
+
+func TestQueueBlockCanceled(t *testing.T) {
+	checkpoint, err := LoadCheckpoint("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Seed(10); err != nil {
+		t.Fatal(err)
+	}
+	// the pool is not running and has no room, so that the submit waits until the stream context is canceled
+	l := &Listener{
+		WrappedLogger: logger.NewWrappedLogger(logger.NewNopLogger()),
+		Filters:       NewRegistry(),
+		Checkpoint:    checkpoint,
+		pool:          newWorkerPool(Parameters{}),
+	}
+	if err := l.Filters.Add(Filter{Id: "a", Tag: "sensors"}); err != nil {
+		t.Fatal(err)
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = l.queueBlock(&inx.BlockMetadata{ReferencedByMilestoneIndex: 11}, nil, streamCtx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error is %v, want %v", err, context.Canceled)
+	}
+	// the milestone of the block which couldn't be queued is not sealed, it is walked again when listening again
+	if received := checkpoint.Received(); received != 10 {
+		t.Errorf("received is %d, want 10", received)
+	}
+
+	// the checkpoint still advances once the walked milestone is complete and the next ones are processed
+	if err := checkpoint.Complete(11); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Begin(12)
+	if err := checkpoint.End(12); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Begin(13)
+	if err := checkpoint.End(13); err != nil {
+		t.Fatal(err)
+	}
+	if index := checkpoint.Index(); index != 12 {
+		t.Errorf("index is %d, want 12", index)
+	}
+}
@@ -1,7 +1,36 @@
 package listener
 
+import "time"
+
 // ParametersListener contains the definition of the parameters used by the Listener
 type Parameters struct {
 	// Filters is a json string which sets startup filters
 	Filters string `default:"" usage:"startup filters from env or config.json in a string format"`
+	// CheckpointFile defines the file keeping the last processed milestone, empty disables the resume after a restart
+	CheckpointFile string `default:"" usage:"the file keeping the last processed milestone, empty disables the resume after a restart"`
+
+	Reconnect struct {
+		// MinBackoff defines the delay before listening again to the node after the stream failed, doubled at every further failure
+		MinBackoff time.Duration `default:"1s" usage:"the delay before listening again to the node after the stream failed, doubled at every further failure"`
+
+		// MaxBackoff defines the maximum delay between the attempts to listen again to the node
+		MaxBackoff time.Duration `default:"1m" usage:"the maximum delay between the attempts to listen again to the node"`
+	} `name:"reconnect"`
+
+	Pool struct {
+		// Workers defines the number of workers processing the referenced blocks
+		Workers int `default:"16" usage:"the number of workers processing the referenced blocks"`
+
+		// QueueSize defines the number of referenced blocks waiting for a worker, the stream of the node is paused while the queue is full
+		QueueSize int `default:"1000" usage:"the number of referenced blocks waiting for a worker, the stream of the node is paused while the queue is full"`
+
+		// FetchConcurrency defines the maximum number of blocks read from the node at the same time
+		FetchConcurrency int `default:"16" usage:"the maximum number of blocks read from the node at the same time"`
+
+		// POIConcurrency defines the maximum number of proofs of inclusion requested at the same time
+		POIConcurrency int `default:"4" usage:"the maximum number of proofs of inclusion requested at the same time"`
+
+		// UploadConcurrency defines the maximum number of objects stored at the same time
+		UploadConcurrency int `default:"8" usage:"the maximum number of objects stored at the same time"`
+	} `name:"pool"`
 }
@@ -0,0 +1,163 @@
+package listener
+
+import (
+	"context"
+	"sync/atomic"
+
+	inx "github.com/iotaledger/inx/go"
+)
+
+const (
+	// StageFetch reads the referenced blocks from the node.
+	StageFetch = "fetch"
+	// StagePOI requests the proofs of inclusion of the blocks stored with one.
+	StagePOI = "poi"
+	// StageUpload stores the objects of the matching blocks.
+	StageUpload = "upload"
+)
+
+// PoolStats describes the queue of the referenced blocks waiting for a worker and the work in progress.
+type PoolStats struct {
+	Workers int `json:"workers"`
+	// Busy is the number of workers processing a block
+	Busy          int64 `json:"busy"`
+	QueueDepth    int   `json:"queueDepth"`
+	QueueCapacity int   `json:"queueCapacity"`
+	// Stalls counts the blocks for which the stream of the node waited for room in the queue
+	Stalls    int64                 `json:"stalls"`
+	Processed int64                 `json:"processed"`
+	Stages    map[string]StageStats `json:"stages"`
+}
+
+// StageStats describes the work of a stage of the processing of the blocks.
+type StageStats struct {
+	Limit    int   `json:"limit"`
+	InFlight int64 `json:"inFlight"`
+	Total    int64 `json:"total"`
+	Failed   int64 `json:"failed"`
+}
+
+// blockTask is a referenced block waiting for a worker.
+type blockTask struct {
+	blockMetadata *inx.BlockMetadata
+	filters       *FilterSnapshot
+	client        inx.INXClient
+}
+
+// stage limits the number of calls of a stage running at the same time.
+type stage struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+	total    atomic.Int64
+	failed   atomic.Int64
+}
+
+// workerPool processes the referenced blocks with a fixed number of workers, the blocks waiting in a bounded queue.
+type workerPool struct {
+	workers   int
+	queue     chan blockTask
+	stages    map[string]*stage
+	busy      atomic.Int64
+	stalls    atomic.Int64
+	processed atomic.Int64
+}
+
+func newWorkerPool(params Parameters) *workerPool {
+	newStage := func(limit int) *stage {
+		if limit < 1 {
+			limit = 1
+		}
+		return &stage{slots: make(chan struct{}, limit)}
+	}
+	workers := params.Pool.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	return &workerPool{
+		workers: workers,
+		queue:   make(chan blockTask, params.Pool.QueueSize),
+		stages: map[string]*stage{
+			StageFetch:  newStage(params.Pool.FetchConcurrency),
+			StagePOI:    newStage(params.Pool.POIConcurrency),
+			StageUpload: newStage(params.Pool.UploadConcurrency),
+		},
+	}
+}
+
+// submit queues the block, waiting for room in the queue when it is full so that the stream of the node is not read faster
+// than the blocks are processed.
+func (p *workerPool) submit(task blockTask, ctx context.Context) error {
+	select {
+	case p.queue <- task:
+		return nil
+	default:
+	}
+
+	p.stalls.Add(1)
+	select {
+	case p.queue <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run processes the queued blocks with the workers of the pool, until the context is canceled.
+func (p *workerPool) run(process func(task blockTask, ctx context.Context), ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case task := <-p.queue:
+					p.busy.Add(1)
+					process(task, ctx)
+					p.busy.Add(-1)
+					p.processed.Add(1)
+				}
+			}
+		}()
+	}
+}
+
+// stage runs fn once a call of the stage can start, so that no more calls than the limit of the stage run at the same time.
+func (p *workerPool) stage(name string, fn func() error, ctx context.Context) error {
+	s := p.stages[name]
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.slots }()
+
+	s.inFlight.Add(1)
+	err := fn()
+	s.inFlight.Add(-1)
+	s.total.Add(1)
+	if err != nil {
+		s.failed.Add(1)
+	}
+	return err
+}
+
+func (p *workerPool) stats() PoolStats {
+	stats := PoolStats{
+		Workers:       p.workers,
+		Busy:          p.busy.Load(),
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Stalls:        p.stalls.Load(),
+		Processed:     p.processed.Load(),
+		Stages:        make(map[string]StageStats, len(p.stages)),
+	}
+	for name, s := range p.stages {
+		stats.Stages[name] = StageStats{
+			Limit:    cap(s.slots),
+			InFlight: s.inFlight.Load(),
+			Total:    s.total.Load(),
+			Failed:   s.failed.Load(),
+		}
+	}
+	return stats
+}
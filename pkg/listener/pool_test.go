@@ -0,0 +1,144 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	inx "github.com/iotaledger/inx/go"
+)
+
+func newTestPool(workers int, queueSize int, fetchConcurrency int) *workerPool {
+	var params Parameters
+	params.Pool.Workers = workers
+	params.Pool.QueueSize = queueSize
+	params.Pool.FetchConcurrency = fetchConcurrency
+	return newWorkerPool(params)
+}
+
+func TestWorkerPoolStageLimit(t *testing.T) {
+	pool := newTestPool(1, 0, 2)
+	var running, maxRunning atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pool.stage(StageFetch, func() error {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					max := maxRunning.Load()
+					if n <= max || maxRunning.CompareAndSwap(max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				if i%5 == 0 {
+					return errors.New("block not found")
+				}
+				return nil
+			}, context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	if maxRunning.Load() > 2 {
+		t.Errorf("%d calls ran at the same time, want at most 2", maxRunning.Load())
+	}
+	stats := pool.stats().Stages[StageFetch]
+	if stats != (StageStats{Limit: 2, InFlight: 0, Total: 10, Failed: 2}) {
+		t.Errorf("stage stats are %+v, want 10 calls and 2 failed", stats)
+	}
+	// the limits below one are raised to one
+	if limit := pool.stats().Stages[StagePOI].Limit; limit != 1 {
+		t.Errorf("limit of the stage is %d, want 1", limit)
+	}
+}
+
+func TestWorkerPoolStageCanceled(t *testing.T) {
+	pool := newTestPool(1, 0, 1)
+	release := make(chan struct{})
+	go pool.stage(StageFetch, func() error {
+		<-release
+		return nil
+	}, context.Background())
+	defer close(release)
+	waitForTest(t, "the stage to be busy", func() bool { return pool.stats().Stages[StageFetch].InFlight == 1 })
+
+	// a call waiting for a slot gives up when its context is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	called := false
+	err := pool.stage(StageFetch, func() error {
+		called = true
+		return nil
+	}, ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Errorf("error is %v and called is %t, want %v without call", err, called, context.DeadlineExceeded)
+	}
+}
+
+func TestWorkerPoolRun(t *testing.T) {
+	pool := newTestPool(3, 2, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the workers are held until the queue is full
+	release := make(chan struct{})
+	var processed sync.Map
+	pool.run(func(task blockTask, ctx context.Context) {
+		<-release
+		processed.Store(task.blockMetadata.GetReferencedByMilestoneIndex(), true)
+	}, ctx)
+
+	submitted := make(chan error, 1)
+	go func() {
+		for i := uint32(1); i <= 6; i++ {
+			if err := pool.submit(blockTask{blockMetadata: &inx.BlockMetadata{ReferencedByMilestoneIndex: i}}, ctx); err != nil {
+				submitted <- err
+				return
+			}
+		}
+		submitted <- nil
+	}()
+	// the last submit waits for room, the stream of the node would not be read meanwhile
+	waitForTest(t, "the queue to be full", func() bool {
+		stats := pool.stats()
+		return stats.Busy == 3 && stats.QueueDepth == 2 && stats.Stalls >= 1
+	})
+
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+	waitForTest(t, "the blocks to be processed", func() bool { return pool.stats().Processed == 6 })
+	for i := uint32(1); i <= 6; i++ {
+		if _, ok := processed.Load(i); !ok {
+			t.Errorf("block of milestone %d is not processed", i)
+		}
+	}
+	stats := pool.stats()
+	if stats.Workers != 3 || stats.QueueCapacity != 2 || stats.Busy != 0 || stats.QueueDepth != 0 {
+		t.Errorf("pool stats are %+v", stats)
+	}
+}
+
+func TestWorkerPoolSubmitCanceled(t *testing.T) {
+	pool := newTestPool(1, 1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := pool.submit(blockTask{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	// the pool is not running, the full queue is never drained
+	if err := pool.submit(blockTask{}, ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("error is %v, want %v", err, context.Canceled)
+	}
+	if stats := pool.stats(); stats.Stalls != 1 || stats.QueueDepth != 1 {
+		t.Errorf("pool stats are %+v, want 1 stall and 1 queued block", stats)
+	}
+}
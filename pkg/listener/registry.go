@@ -0,0 +1,107 @@
+package listener
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// FilterSnapshot is an immutable set of filters, indexed by tag so that a block is only compared to the filters listening to its tag.
+type FilterSnapshot struct {
+	filters map[string]Filter
+	byTag   map[string][]Filter
+}
+
+// Len returns the number of filters.
+func (s *FilterSnapshot) Len() int {
+	return len(s.filters)
+}
+
+// Get returns the filter with the given id.
+func (s *FilterSnapshot) Get(filterId string) (Filter, bool) {
+	filter, ok := s.filters[filterId]
+	return filter, ok
+}
+
+// ForTag returns the filters listening to the tag, sorted by id. The slice must not be modified.
+func (s *FilterSnapshot) ForTag(tag string) []Filter {
+	return s.byTag[tag]
+}
+
+// All returns the filters sorted by id.
+func (s *FilterSnapshot) All() []Filter {
+	filters := make([]Filter, 0, len(s.filters))
+	for _, filter := range s.filters {
+		filters = append(filters, filter)
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Id < filters[j].Id })
+	return filters
+}
+
+func newFilterSnapshot(filters map[string]Filter) *FilterSnapshot {
+	snapshot := &FilterSnapshot{filters: filters, byTag: make(map[string][]Filter)}
+	for _, filter := range filters {
+		snapshot.byTag[filter.Tag] = append(snapshot.byTag[filter.Tag], filter)
+	}
+	for _, tagFilters := range snapshot.byTag {
+		sort.Slice(tagFilters, func(i, j int) bool { return tagFilters[i].Id < tagFilters[j].Id })
+	}
+	return snapshot
+}
+
+// Registry holds the filters of the listener. The readers get immutable snapshots without locking,
+// while the changes are serialized and each publishes a new snapshot.
+type Registry struct {
+	mutex    sync.Mutex
+	snapshot atomic.Pointer[FilterSnapshot]
+}
+
+func NewRegistry() *Registry {
+	registry := &Registry{}
+	registry.snapshot.Store(newFilterSnapshot(map[string]Filter{}))
+	return registry
+}
+
+// Snapshot returns the current filters, which later changes of the registry don't affect.
+func (r *Registry) Snapshot() *FilterSnapshot {
+	return r.snapshot.Load()
+}
+
+// Add adds the filter, unless a filter with the same id already exists.
+func (r *Registry) Add(filter Filter) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	current := r.snapshot.Load()
+	if _, ok := current.filters[filter.Id]; ok {
+		return fmt.Errorf("Filter id '%s' already exists", filter.Id)
+	}
+
+	filters := make(map[string]Filter, len(current.filters)+1)
+	for filterId, f := range current.filters {
+		filters[filterId] = f
+	}
+	filters[filter.Id] = filter
+	r.snapshot.Store(newFilterSnapshot(filters))
+	return nil
+}
+
+// Remove removes the filter with the given id, and returns it if it existed.
+func (r *Registry) Remove(filterId string) (Filter, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	current := r.snapshot.Load()
+	removed, ok := current.filters[filterId]
+	if !ok {
+		return Filter{}, false
+	}
+
+	filters := make(map[string]Filter, len(current.filters))
+	for id, f := range current.filters {
+		if id != filterId {
+			filters[id] = f
+		}
+	}
+	r.snapshot.Store(newFilterSnapshot(filters))
+	return removed, true
+}
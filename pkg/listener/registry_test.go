@@ -0,0 +1,138 @@
+package listener
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	sensors := Filter{Id: "a", Tag: "sensors"}
+	sensorsSigned := Filter{Id: "b", Tag: "sensors", PublicKey: "key"}
+	weather := Filter{Id: "c", Tag: "weather"}
+
+	type op struct {
+		add    *Filter
+		remove string
+		// wantOk is whether the filter is added or removed
+		wantOk bool
+	}
+	tests := []struct {
+		name    string
+		ops     []op
+		wantAll []string
+		wantTag map[string][]string
+	}{
+		{
+			name:    "empty",
+			wantAll: []string{},
+			wantTag: map[string][]string{"sensors": nil},
+		},
+		{
+			name:    "add",
+			ops:     []op{{add: &weather, wantOk: true}, {add: &sensorsSigned, wantOk: true}, {add: &sensors, wantOk: true}},
+			wantAll: []string{"a", "b", "c"},
+			wantTag: map[string][]string{"sensors": {"a", "b"}, "weather": {"c"}, "traffic": nil},
+		},
+		{
+			name:    "add an existing filter",
+			ops:     []op{{add: &sensors, wantOk: true}, {add: &sensors, wantOk: false}},
+			wantAll: []string{"a"},
+			wantTag: map[string][]string{"sensors": {"a"}},
+		},
+		{
+			name:    "remove",
+			ops:     []op{{add: &sensors, wantOk: true}, {add: &sensorsSigned, wantOk: true}, {remove: "a", wantOk: true}},
+			wantAll: []string{"b"},
+			wantTag: map[string][]string{"sensors": {"b"}},
+		},
+		{
+			name:    "remove the last filter of a tag",
+			ops:     []op{{add: &sensors, wantOk: true}, {add: &weather, wantOk: true}, {remove: "c", wantOk: true}},
+			wantAll: []string{"a"},
+			wantTag: map[string][]string{"sensors": {"a"}, "weather": nil},
+		},
+		{
+			name:    "remove a missing filter",
+			ops:     []op{{add: &sensors, wantOk: true}, {remove: "c", wantOk: false}, {remove: "a", wantOk: true}, {remove: "a", wantOk: false}},
+			wantAll: []string{},
+			wantTag: map[string][]string{"sensors": nil},
+		},
+		{
+			name:    "add again after removing",
+			ops:     []op{{add: &sensors, wantOk: true}, {remove: "a", wantOk: true}, {add: &sensors, wantOk: true}},
+			wantAll: []string{"a"},
+			wantTag: map[string][]string{"sensors": {"a"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := NewRegistry()
+			for _, op := range test.ops {
+				if op.add != nil {
+					if err := registry.Add(*op.add); (err == nil) != op.wantOk {
+						t.Fatalf("adding filter '%s' returned %v, want added: %t", op.add.Id, err, op.wantOk)
+					}
+					continue
+				}
+				removed, ok := registry.Remove(op.remove)
+				if ok != op.wantOk {
+					t.Fatalf("removing filter '%s' returned %t, want %t", op.remove, ok, op.wantOk)
+				}
+				if ok && removed.Id != op.remove {
+					t.Fatalf("removing filter '%s' returned filter '%s'", op.remove, removed.Id)
+				}
+			}
+
+			snapshot := registry.Snapshot()
+			if got := filterIds(snapshot.All()); !reflect.DeepEqual(got, test.wantAll) {
+				t.Errorf("filters are %v, want %v", got, test.wantAll)
+			}
+			if snapshot.Len() != len(test.wantAll) {
+				t.Errorf("length is %d, want %d", snapshot.Len(), len(test.wantAll))
+			}
+			for tag, want := range test.wantTag {
+				var got []string
+				for _, filter := range snapshot.ForTag(tag) {
+					got = append(got, filter.Id)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("filters of tag '%s' are %v, want %v", tag, got, want)
+				}
+			}
+			for _, filterId := range test.wantAll {
+				if _, ok := snapshot.Get(filterId); !ok {
+					t.Errorf("filter '%s' not found", filterId)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistrySnapshotIsolation(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Add(Filter{Id: "a", Tag: "sensors"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := registry.Snapshot()
+
+	if err := registry.Add(Filter{Id: "b", Tag: "sensors"}); err != nil {
+		t.Fatal(err)
+	}
+	registry.Remove("a")
+
+	// the snapshot taken before the changes still holds the filters of that time
+	if got := filterIds(snapshot.ForTag("sensors")); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("filters of the old snapshot are %v, want [a]", got)
+	}
+	if got := filterIds(registry.Snapshot().ForTag("sensors")); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("filters of the new snapshot are %v, want [b]", got)
+	}
+}
+
+func filterIds(filters []Filter) []string {
+	ids := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		ids = append(ids, filter.Id)
+	}
+	return ids
+}
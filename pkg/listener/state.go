@@ -0,0 +1,113 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// StateConnecting is the state of the listener before it first listens to the node.
+	StateConnecting = "connecting"
+	// StateCatchingUp is the state of the listener walking the milestones it missed.
+	StateCatchingUp = "catching-up"
+	// StateConnected is the state of the listener receiving the referenced blocks from the node.
+	StateConnected = "connected"
+	// StateReconnecting is the state of the listener waiting to listen again to the node after a transient error.
+	StateReconnecting = "reconnecting"
+	// StateStopped is the state of the listener once the collector is shutting down.
+	StateStopped = "stopped"
+	// StateFailed is the state of the listener after an error it can't recover from.
+	StateFailed = "failed"
+)
+
+// Status describes the connection of the listener to the node.
+type Status struct {
+	State string `json:"state"`
+	// Since is when the listener entered its state
+	Since time.Time `json:"since"`
+	// Reconnects counts the transient failures of the stream the listener recovered from
+	Reconnects int    `json:"reconnects"`
+	LastError  string `json:"lastError,omitempty"`
+	// LastBlockAt is when the last referenced block was received
+	LastBlockAt *time.Time `json:"lastBlockAt,omitempty"`
+	// LatestMilestoneIndex is the milestone of the last referenced block received
+	LatestMilestoneIndex uint32 `json:"latestMilestoneIndex"`
+	// ProcessedMilestoneIndex is the last milestone whose referenced blocks were all processed
+	ProcessedMilestoneIndex uint32 `json:"processedMilestoneIndex"`
+	// ConfirmedMilestoneIndex is the last milestone confirmed by the node, and Lag the number of milestones not yet processed
+	ConfirmedMilestoneIndex uint32 `json:"confirmedMilestoneIndex"`
+	Lag                     uint32 `json:"lag"`
+	// Pool describes the referenced blocks waiting to be processed and the work in progress
+	Pool PoolStats `json:"pool"`
+}
+
+// listenerState keeps the status of the listener, updated by Run and read by the API.
+type listenerState struct {
+	mutex  sync.RWMutex
+	status Status
+}
+
+func newListenerState() *listenerState {
+	return &listenerState{status: Status{State: StateConnecting, Since: time.Now()}}
+}
+
+func (s *listenerState) set(state string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state == StateReconnecting && s.status.State != StateReconnecting {
+		s.status.Reconnects++
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now()
+	}
+}
+
+func (s *listenerState) blockReceived() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	s.status.LastBlockAt = &now
+}
+
+func (s *listenerState) get() Status {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.status
+}
+
+// Status returns the state of the connection of the listener to the node, and the milestones it processed.
+func (l *Listener) Status() Status {
+	status := l.state.get()
+	status.LatestMilestoneIndex = l.Checkpoint.Latest()
+	status.ProcessedMilestoneIndex = l.Checkpoint.Index()
+	status.Pool = l.pool.stats()
+	return status
+}
+
+// isTransientError reports whether the stream of the node failed on an error which listening again can recover from,
+// such as the node restarting or the connection dropping, as opposed to a request the node will never serve.
+func isTransientError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return false
+	}
+	switch grpcErr.GRPCStatus().Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown, codes.Canceled:
+		return true
+	default:
+		return false
+	}
+}
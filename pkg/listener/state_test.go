@@ -0,0 +1,156 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	inx "github.com/iotaledger/inx/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantTransient bool
+	}{
+		{name: "end of stream", err: io.EOF, wantTransient: true},
+		{name: "deadline", err: context.DeadlineExceeded, wantTransient: true},
+		{name: "node unavailable", err: status.Error(codes.Unavailable, "connection refused"), wantTransient: true},
+		{name: "wrapped node unavailable", err: fmt.Errorf("can't read cone, error: %w", status.Error(codes.Unavailable, "")), wantTransient: true},
+		{name: "stream aborted", err: status.Error(codes.Aborted, ""), wantTransient: true},
+		{name: "request refused", err: status.Error(codes.PermissionDenied, ""), wantTransient: false},
+		{name: "unimplemented", err: status.Error(codes.Unimplemented, ""), wantTransient: false},
+		{name: "other error", err: errors.New("invalid block"), wantTransient: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if transient := isTransientError(test.err); transient != test.wantTransient {
+				t.Errorf("transient is %t, want %t", transient, test.wantTransient)
+			}
+		})
+	}
+}
+
+func TestListenerState(t *testing.T) {
+	state := newListenerState()
+	if status := state.get(); status.State != StateConnecting || status.Reconnects != 0 {
+		t.Fatalf("status is %+v, want connecting", status)
+	}
+
+	// the reconnects are counted once per failure, however long the listener waits
+	state.set(StateReconnecting, errors.New("node restarting"))
+	since := state.get().Since
+	state.set(StateReconnecting, errors.New("node still restarting"))
+	if status := state.get(); !status.Since.Equal(since) || status.LastError != "node still restarting" {
+		t.Errorf("status is %+v, want the state entered at %s", status, since)
+	}
+	state.set(StateConnected, nil)
+	state.set(StateReconnecting, errors.New("connection dropped"))
+	status := state.get()
+	if status.State != StateReconnecting || status.Reconnects != 2 || status.LastError != "connection dropped" {
+		t.Errorf("status is %+v, want 2 reconnects", status)
+	}
+
+	// the last error is kept once connected again
+	state.set(StateConnected, nil)
+	if status := state.get(); status.LastError != "connection dropped" {
+		t.Errorf("last error is '%s', want it kept", status.LastError)
+	}
+}
+
+func TestRunReconnect(t *testing.T) {
+	node := newTestNode(5, 13)
+	walked := node.addBlock(t, 12, "sensors", "temperature=21", true)
+	live := node.addBlock(t, 13, "sensors", "temperature=22", true)
+	unavailable := status.Error(codes.Unavailable, "node restarting")
+	node.streams = []testStream{
+		{err: unavailable},
+		{err: unavailable},
+		// the milestones 11 and 12 referenced while the stream was down are walked
+		{blocks: []*inx.BlockMetadata{live}},
+	}
+
+	l := newTestListener(t, Parameters{})
+	l.reconnectMinBackoff = time.Millisecond
+	l.reconnectMaxBackoff = 2 * time.Millisecond
+	if err := l.Checkpoint.Seed(10); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewFilter("sensors", "", "default", "", false, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AddFilter(filter, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run(node, ctx) }()
+
+	waitForTest(t, "the blocks to be stored", func() bool {
+		objects, err := l.Storage.ListObjects("default", context.Background())
+		return err == nil && len(objects) == 2 && l.Status().ProcessedMilestoneIndex == 12
+	})
+	status := l.Status()
+	if status.State != StateConnected || status.Reconnects != 2 || !strings.Contains(status.LastError, "node restarting") {
+		t.Errorf("status is %+v, want connected after 2 reconnects", status)
+	}
+	if status.LatestMilestoneIndex != 13 || status.LastBlockAt == nil {
+		t.Errorf("status is %+v, want the block of milestone 13 received", status)
+	}
+	for _, metadata := range []*inx.BlockMetadata{walked, live} {
+		if _, _, err := l.Storage.GetObject("default", fmt.Sprintf("%x", metadata.GetBlockId().GetId()), context.Background()); err != nil {
+			t.Errorf("block of milestone %d is not stored, error: %s", metadata.GetReferencedByMilestoneIndex(), err)
+		}
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("error is %v once stopped, want none", err)
+	}
+	if state := l.Status().State; state != StateStopped {
+		t.Errorf("state is %s, want %s", state, StateStopped)
+	}
+}
+
+func TestRunFailed(t *testing.T) {
+	node := newTestNode(5, 10)
+	refused := status.Error(codes.PermissionDenied, "not allowed")
+	node.streams = []testStream{{err: refused}}
+	l := newTestListener(t, Parameters{})
+	l.reconnectMinBackoff = time.Millisecond
+	l.reconnectMaxBackoff = time.Millisecond
+
+	// the stream is not listened again after an error it can't recover from
+	if err := l.Run(node, context.Background()); !errors.Is(err, refused) {
+		t.Fatalf("error is %v, want %v", err, refused)
+	}
+	status := l.Status()
+	if status.State != StateFailed || status.Reconnects != 0 || node.listenCount() != 1 {
+		t.Errorf("status is %+v after %d listens, want failed at once", status, node.listenCount())
+	}
+	// the checkpoint is seeded from the node
+	if status.ProcessedMilestoneIndex != 10 {
+		t.Errorf("processed milestone is %d, want 10", status.ProcessedMilestoneIndex)
+	}
+}
+
+// waitForTest waits until the condition holds, failing the test after a few seconds.
+func waitForTest(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
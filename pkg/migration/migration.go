@@ -0,0 +1,149 @@
+package migration
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// JobMigration is the kind of the jobs migrating a bucket.
+const JobMigration = "migration"
+
+// maxFailures is the number of objects that can't be migrated before the migration is given up.
+const maxFailures = 100
+
+// Options describes a migration, the empty re-encoding options keep the configuration of the target bucket.
+type Options struct {
+	SourceBucket string `json:"sourceBucket" validate:"required"`
+	TargetBucket string `json:"targetBucket" validate:"required"`
+	// Move deletes the objects from the source bucket once they are in the target bucket
+	Move bool `json:"move"`
+	// Format, Compression and KeyLayout are set in the configuration of the target bucket before the objects are copied
+	Format      string `json:"format"`
+	Compression string `json:"compression"`
+	KeyLayout   string `json:"keyLayout"`
+	// StartAfter skips the objects whose key is not after it, to resume an interrupted migration
+	StartAfter string `json:"startAfter"`
+}
+
+// Failure is an object that couldn't be migrated.
+type Failure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Report is the result of a migration.
+type Report struct {
+	SourceBucket string `json:"sourceBucket"`
+	TargetBucket string `json:"targetBucket"`
+	Migrated     int    `json:"migrated"`
+	// Kept is the number of moved objects left in the source bucket because they are locked
+	Kept     int       `json:"kept"`
+	Failures []Failure `json:"failures"`
+	// ResumeAfter is the key after which an interrupted migration can be started again, every object up to it was migrated
+	ResumeAfter string `json:"resumeAfter,omitempty"`
+}
+
+// Configure creates the target bucket if needed and applies the re-encoding options to its configuration.
+func Configure(target *storage.Storage, options Options, ctx context.Context) error {
+	reencoding := storage.BucketConfig{Format: options.Format, Compression: options.Compression, KeyLayout: options.KeyLayout}
+	if err := reencoding.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := target.CheckCreateBucket(options.TargetBucket, ctx); err != nil {
+		return err
+	}
+	if reencoding == (storage.BucketConfig{}) {
+		return nil
+	}
+
+	config, err := target.GetBucketConfig(options.TargetBucket, ctx)
+	if err != nil {
+		return err
+	}
+	if options.Format != "" {
+		config.Format = options.Format
+	}
+	if options.Compression != "" {
+		config.Compression = options.Compression
+	}
+	if options.KeyLayout != "" {
+		config.KeyLayout = options.KeyLayout
+	}
+	return target.SetBucketConfig(options.TargetBucket, config, ctx)
+}
+
+// Run copies, or moves, the objects of the source bucket into the target bucket, which is configured by Configure.
+// The objects are uploaded as new objects of the target bucket, so they follow its format, compression, encryption and key layout,
+// and the objects it already holds are not copied again: a migration can be run again, the objects it already migrated are skipped.
+func Run(source *storage.Storage, target *storage.Storage, options Options, job *jobs.Job, ctx context.Context) (Report, error) {
+	report := Report{SourceBucket: options.SourceBucket, TargetBucket: options.TargetBucket, Failures: []Failure{}}
+
+	objects, err := source.ListObjects(options.SourceBucket, ctx)
+	if err != nil {
+		return report, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if object.Key > options.StartAfter {
+			keys = append(keys, object.Key)
+		}
+	}
+	sort.Strings(keys)
+	job.SetTotal(len(keys))
+
+	failed := func(key string, err error) error {
+		report.Failures = append(report.Failures, Failure{Key: key, Error: err.Error()})
+		if len(report.Failures) >= maxFailures {
+			return fmt.Errorf("%d objects couldn't be migrated, last error: %w", len(report.Failures), err)
+		}
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		job.Advance(1)
+
+		object, attributes, err := source.ReadObject(options.SourceBucket, key, ctx)
+		if err != nil {
+			if err := failed(key, err); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		err = target.UploadObject(attributes, options.TargetBucket, object, ctx)
+		if err != nil {
+			if err := failed(key, err); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		if options.Move {
+			err = source.RemoveObject(options.SourceBucket, key, ctx)
+			if errors.Is(err, storage.ErrObjectLocked) {
+				report.Kept++
+			} else if err != nil {
+				if err := failed(key, err); err != nil {
+					return report, err
+				}
+				continue
+			}
+		}
+
+		report.Migrated++
+		if len(report.Failures) == 0 {
+			report.ResumeAfter = key
+		}
+		job.SetResult(report)
+	}
+
+	return report, nil
+}
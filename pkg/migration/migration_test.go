@@ -0,0 +1,181 @@
+package migration
+
+import (
+	"collector/pkg/jobs"
+	"collector/pkg/storage"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/iotaledger/hive.go/core/logger"
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+func newTestStorage(t *testing.T, bucketNames ...string) *storage.Storage {
+	t.Helper()
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	s := storage.NewStorageWithBackend(storage.NewMemoryBackend(), storage.Parameters{DefaultBucketName: "default"}, log)
+	for _, bucketName := range bucketNames {
+		if err := s.CreateBucket(bucketName, context.Background()); err != nil {
+			t.Fatalf("can't create bucket, error: %s", err)
+		}
+	}
+	return s
+}
+
+// storeTestBlocks stores a tagged block per data in the bucket and returns their ids.
+func storeTestBlocks(t *testing.T, s *storage.Storage, bucketName string, data ...string) []string {
+	t.Helper()
+	blockIds := make([]string, 0, len(data))
+	for _, d := range data {
+		block := &iotago.Block{
+			ProtocolVersion: 2,
+			Parents:         iotago.BlockIDs{{1}},
+			Payload:         &iotago.TaggedData{Tag: []byte("sensors"), Data: []byte(d)},
+		}
+		blockId, err := block.ID()
+		if err != nil {
+			t.Fatalf("can't compute block id, error: %s", err)
+		}
+		attributes := storage.ObjectAttributes{BlockId: hex.EncodeToString(blockId[:]), Tag: []byte("sensors"), MilestoneIndex: 10}
+		if err := s.UploadObject(attributes, bucketName, storage.Object{Block: block}, context.Background()); err != nil {
+			t.Fatalf("can't store block, error: %s", err)
+		}
+		blockIds = append(blockIds, attributes.BlockId)
+	}
+	return blockIds
+}
+
+// runTestMigration runs the migration as a job and returns its report.
+func runTestMigration(t *testing.T, source *storage.Storage, target *storage.Storage, options Options) Report {
+	t.Helper()
+	ctx := context.Background()
+	if err := Configure(target, options, ctx); err != nil {
+		t.Fatal(err)
+	}
+	manager := jobs.NewManager(logger.NewWrappedLogger(logger.NewNopLogger()))
+	job := manager.Start(JobMigration, options.SourceBucket, "", func(job *jobs.Job, ctx context.Context) (interface{}, error) {
+		return Run(source, target, options, job, ctx)
+	}, ctx)
+	<-job.Done()
+
+	status := job.Status()
+	if status.State != jobs.StateDone {
+		t.Fatalf("migration is %s, error: %s", status.State, status.Error)
+	}
+	report, ok := status.Result.(Report)
+	if !ok {
+		t.Fatalf("result is %v, want a report", status.Result)
+	}
+	if status.Processed != status.Total {
+		t.Errorf("%d of %d objects processed", status.Processed, status.Total)
+	}
+	return report
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		move bool
+	}{
+		{name: "copy"},
+		{name: "move", move: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newTestStorage(t, "default")
+			target := newTestStorage(t)
+			blockIds := storeTestBlocks(t, source, "default", "temperature=21", "temperature=22", "temperature=23")
+
+			options := Options{SourceBucket: "default", TargetBucket: "archive", Move: test.move, Compression: storage.CompressionGzip}
+			report := runTestMigration(t, source, target, options)
+			if report.Migrated != len(blockIds) || report.Kept != 0 || len(report.Failures) != 0 {
+				t.Errorf("report is %+v, want %d objects migrated", report, len(blockIds))
+			}
+
+			config, err := target.GetBucketConfig("archive", ctx)
+			if err != nil || config.Compression != storage.CompressionGzip {
+				t.Errorf("compression of the target bucket is '%s' (%v), want '%s'", config.Compression, err, storage.CompressionGzip)
+			}
+			for _, blockId := range blockIds {
+				reader, _, err := target.GetObject("archive", blockId, ctx)
+				if err != nil {
+					t.Fatalf("block '%s' is not migrated, error: %s", blockId, err)
+				}
+				reader.Close()
+			}
+
+			objects, err := source.ListObjects("default", ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantLeft := len(blockIds)
+			if test.move {
+				wantLeft = 0
+			}
+			if len(objects) != wantLeft {
+				t.Errorf("%d objects left in the source bucket, want %d", len(objects), wantLeft)
+			}
+
+			// a migration run again skips the objects already migrated
+			report = runTestMigration(t, source, target, options)
+			if len(report.Failures) != 0 {
+				t.Errorf("failures are %v running the migration again", report.Failures)
+			}
+			if objects, err := target.ListObjects("archive", ctx); err != nil || len(objects) != len(blockIds) {
+				t.Errorf("%d objects in the target bucket (%v), want %d", len(objects), err, len(blockIds))
+			}
+		})
+	}
+}
+
+func TestRunStartAfter(t *testing.T) {
+	source := newTestStorage(t, "default")
+	target := newTestStorage(t)
+	blockIds := storeTestBlocks(t, source, "default", "temperature=21", "temperature=22", "temperature=23")
+
+	first := blockIds[0]
+	for _, blockId := range blockIds[1:] {
+		if blockId < first {
+			first = blockId
+		}
+	}
+	report := runTestMigration(t, source, target, Options{SourceBucket: "default", TargetBucket: "archive", StartAfter: first})
+	if report.Migrated != len(blockIds)-1 {
+		t.Errorf("%d objects migrated, want %d", report.Migrated, len(blockIds)-1)
+	}
+	if _, _, err := target.GetObject("archive", first, context.Background()); err == nil {
+		t.Errorf("block '%s' is migrated, want it skipped", first)
+	}
+}
+
+func TestRunMoveLocked(t *testing.T) {
+	ctx := context.Background()
+	source := newTestStorage(t)
+	if err := source.CreateLockedBucket("worm", storage.Retention{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	blockIds := storeTestBlocks(t, source, "worm", "temperature=21", "temperature=22")
+	if err := source.SetLegalHold("worm", blockIds[0], true, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the locked object is copied, and kept in the source bucket
+	report := runTestMigration(t, source, newTestStorage(t), Options{SourceBucket: "worm", TargetBucket: "archive", Move: true})
+	if report.Migrated != 2 || report.Kept != 1 || len(report.Failures) != 0 {
+		t.Errorf("report is %+v, want 2 objects migrated and 1 kept", report)
+	}
+	if reader, _, err := source.GetObject("worm", blockIds[0], ctx); err != nil {
+		t.Errorf("locked object is gone, error: %s", err)
+	} else {
+		reader.Close()
+	}
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	err := Configure(newTestStorage(t), Options{SourceBucket: "default", TargetBucket: "archive", Compression: "lz4"}, context.Background())
+	if err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+const (
+	// BackendS3 selects an S3-compliant object storage reached through the MinIO client.
+	BackendS3 = "s3"
+)
+
+// Backend is implemented by every object store the Collector can keep its blocks in.
+type Backend interface {
+	// CreateBucket creates a new bucket.
+	CreateBucket(bucketName string, ctx context.Context) error
+	// DeleteBucket removes an empty bucket.
+	DeleteBucket(bucketName string, ctx context.Context) error
+	// BucketExists reports whether the bucket exists.
+	BucketExists(bucketName string, ctx context.Context) (bool, error)
+	// ListBuckets returns the information about every bucket.
+	ListBuckets(ctx context.Context) ([]BucketInfo, error)
+	// SetBucketLifecycle replaces the lifecycle rules of the bucket.
+	SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error
+	// GetBucketLifecycle returns the lifecycle rules of the bucket.
+	GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error)
+	// SetBucketTags replaces the tags of the bucket.
+	SetBucketTags(bucketName string, tags map[string]string, ctx context.Context) error
+	// GetBucketTags returns the tags of the bucket.
+	GetBucketTags(bucketName string, ctx context.Context) (map[string]string, error)
+	// PutObject stores the content of reader under the given object name.
+	PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error
+	// GetObject returns a reader on the content of the object, together with its information, the caller must close it.
+	GetObject(bucketName string, objectName string, ctx context.Context) (io.ReadCloser, ObjectInfo, error)
+	// StatObject returns the information about a stored object.
+	StatObject(bucketName string, objectName string, ctx context.Context) (ObjectInfo, error)
+	// DeleteObject removes the object from the bucket.
+	DeleteObject(bucketName string, objectName string, ctx context.Context) error
+	// ListObjects returns the information about every object stored in the bucket.
+	ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error)
+}
+
+// PutOptions contains the options used when storing an object.
+type PutOptions struct {
+	ContentType string
+	// Metadata is the user metadata stored along with the object, keys are lower case
+	Metadata map[string]string
+	// Tags are the tags of the object
+	Tags map[string]string
+}
+
+// BucketInfo describes a bucket.
+type BucketInfo struct {
+	Name         string    `json:"name"`
+	CreationDate time.Time `json:"creationDate"`
+}
+
+// ObjectInfo describes an object stored in a bucket.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ContentType  string    `json:"contentType,omitempty"`
+	// Metadata is the user metadata stored along with the object, keys are lower case
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Tags are the tags of the object, when the backend returns them along with the object information
+	Tags map[string]string `json:"tags,omitempty"`
+	// Lock is the protection of the object, on the backends supporting object lock
+	Lock ObjectLock `json:"lock"`
+}
+
+// ErrObjectNotFound is returned by a backend when the requested object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrBucketNotFound is returned by a backend when the requested bucket does not exist.
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// ErrBucketNotEmpty is returned when deleting a bucket still holding objects.
+var ErrBucketNotEmpty = errors.New("bucket not empty")
+
+// runner is implemented by the backends that need a background routine to enforce their semantics.
+type runner interface {
+	Run(ctx context.Context)
+}
+
+func newBackend(params Parameters, log *logger.WrappedLogger) (Backend, error) {
+	switch params.Type {
+	case BackendS3, "":
+		if len(params.Replication.Endpoints) > 0 {
+			return newReplicatedMinioBackend(params, log)
+		}
+		return NewMinioBackend(params.Endpoint, params)
+	case BackendFilesystem:
+		return NewFilesystemBackend(params.Filesystem.Path, params.Filesystem.SweepInterval, log)
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type '%s'", params.Type)
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+const (
+	// bucketTagPrefix prefixes the bucket tags holding the configuration used by the Collector.
+	bucketTagPrefix      = "collector-"
+	bucketTagCompression = bucketTagPrefix + "compression"
+	bucketTagEncryption  = bucketTagPrefix + "encryption"
+	bucketTagKeyLayout   = bucketTagPrefix + "keylayout"
+	bucketTagFormat      = bucketTagPrefix + "format"
+	bucketTagOwner       = bucketTagPrefix + "owner"
+
+	// bucketConfigTTL is how long the configuration of a bucket is cached, so that the changes made by the other collectors
+	// sharing the bucket are applied within it
+	bucketConfigTTL = time.Minute
+)
+
+// cachedBucketConfig is the configuration of a bucket read at a given time.
+type cachedBucketConfig struct {
+	config BucketConfig
+	read   time.Time
+}
+
+// bucketConfigTags are the bucket tags replaced when the configuration is set.
+var bucketConfigTags = []string{bucketTagCompression, bucketTagEncryption, bucketTagKeyLayout, bucketTagFormat, bucketTagOwner,
+	bucketTagQuotaBytes, bucketTagQuotaObjects, bucketTagQuotaPolicy, bucketTagQuotaOverflow}
+
+// BucketConfig contains the settings the Collector applies to the objects of a bucket.
+// It is kept in the bucket tags, so that every node sharing the storage applies the same settings.
+type BucketConfig struct {
+	// Compression is the compression applied to the new objects of the bucket
+	Compression string `json:"compression,omitempty"`
+	// Encryption defines whether the new objects of the bucket are encrypted with the bucket's data key
+	Encryption bool `json:"encryption,omitempty"`
+	// KeyLayout is the template of the key of the new objects of the bucket, empty means the default layout
+	KeyLayout string `json:"keyLayout,omitempty"`
+	// Format is the serialization of the new objects of the bucket, empty means JSON
+	Format string `json:"format,omitempty"`
+	// Quota limits the size of the bucket and defines what happens to the uploads when it is full
+	Quota Quota `json:"quota,omitempty"`
+	// Owner is the id of the user owning a private bucket, empty for the shared buckets
+	Owner string `json:"owner,omitempty"`
+}
+
+func (c BucketConfig) Validate() error {
+	if err := validateCompression(c.Compression); err != nil {
+		return err
+	}
+	if err := validateFormat(c.Format); err != nil {
+		return err
+	}
+	if err := c.Quota.Validate(); err != nil {
+		return err
+	}
+	if c.Owner != "" {
+		if err := ValidateUserId(c.Owner); err != nil {
+			return err
+		}
+	}
+	return ValidateKeyLayout(c.KeyLayout)
+}
+
+func bucketConfigFromTags(tags map[string]string) BucketConfig {
+	return BucketConfig{
+		Compression: tags[bucketTagCompression],
+		Encryption:  tags[bucketTagEncryption] == EncryptionAESGCM,
+		KeyLayout:   decodeTagValue(tags[bucketTagKeyLayout]),
+		Format:      tags[bucketTagFormat],
+		Quota:       quotaFromTags(tags),
+		Owner:       tags[bucketTagOwner],
+	}
+}
+
+// applyToTags replaces the configuration tags in tags, leaving the other tags untouched.
+func (c BucketConfig) applyToTags(tags map[string]string) map[string]string {
+	applied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		applied[key] = value
+	}
+	for _, key := range bucketConfigTags {
+		delete(applied, key)
+	}
+
+	if c.Compression != CompressionNone {
+		applied[bucketTagCompression] = c.Compression
+	}
+	if c.Encryption {
+		applied[bucketTagEncryption] = EncryptionAESGCM
+	}
+	if c.KeyLayout != "" && c.KeyLayout != DefaultKeyLayout {
+		applied[bucketTagKeyLayout] = encodeTagValue(c.KeyLayout)
+	}
+	if c.Format != "" && c.Format != FormatJSON {
+		applied[bucketTagFormat] = c.Format
+	}
+	if c.Owner != "" {
+		applied[bucketTagOwner] = c.Owner
+	}
+	c.Quota.applyToTags(applied)
+	return applied
+}
+
+// GetBucketConfig returns the configuration of the bucket.
+func (s *Storage) GetBucketConfig(bucketName string, ctx context.Context) (BucketConfig, error) {
+	s.bucketsMutex.RLock()
+	cached, ok := s.bucketConfigs[bucketName]
+	s.bucketsMutex.RUnlock()
+	if ok && time.Since(cached.read) < bucketConfigTTL {
+		return cached.config, nil
+	}
+
+	tags, err := s.backend.GetBucketTags(bucketName, ctx)
+	if err != nil {
+		return BucketConfig{}, err
+	}
+	config := bucketConfigFromTags(tags)
+
+	s.bucketsMutex.Lock()
+	s.bucketConfigs[bucketName] = cachedBucketConfig{config: config, read: time.Now()}
+	s.bucketsMutex.Unlock()
+
+	return config, nil
+}
+
+// SetBucketConfig replaces the configuration of the bucket.
+func (s *Storage) SetBucketConfig(bucketName string, config BucketConfig, ctx context.Context) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.Encryption && s.masterKeys == nil {
+		return fmt.Errorf("can't enable encryption, no master key file is configured")
+	}
+	if config.Quota.Policy == QuotaPolicyOverflow {
+		if config.Quota.OverflowBucket == bucketName {
+			return fmt.Errorf("bucket '%s' can't overflow into itself", bucketName)
+		}
+		// the objects of a private bucket can't overflow into a bucket of another user
+		err := s.CheckBucketOwner(config.Quota.OverflowBucket, config.Owner, ctx)
+		if err != nil {
+			return fmt.Errorf("overflow bucket '%s': %w", config.Quota.OverflowBucket, err)
+		}
+	}
+
+	s.bucketsMutex.Lock()
+	defer s.bucketsMutex.Unlock()
+
+	tags, err := s.backend.GetBucketTags(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	if config.Encryption {
+		if err := s.addDataKey(bucketName, tags); err != nil {
+			return err
+		}
+	}
+	err = s.backend.SetBucketTags(bucketName, config.applyToTags(tags), ctx)
+	if err != nil {
+		return err
+	}
+
+	s.bucketConfigs[bucketName] = cachedBucketConfig{config: config, read: time.Now()}
+	return nil
+}
+
+// ListBuckets returns the buckets of the storage.
+func (s *Storage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	return s.backend.ListBuckets(ctx)
+}
+
+// GetBucketUsage returns the number of objects stored in the bucket and their total size in bytes.
+func (s *Storage) GetBucketUsage(bucketName string, ctx context.Context) (int, int64, error) {
+	objects, err := s.ListObjects(bucketName, ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	var size int64
+	for _, object := range objects {
+		size += object.Size
+	}
+	return len(objects), size, nil
+}
+
+// DeleteBucket removes a bucket holding no objects, together with the index entries left in it.
+func (s *Storage) DeleteBucket(bucketName string, ctx context.Context) error {
+	s.WrappedLogger.LogInfof("Deleting bucket '%s' ...", bucketName)
+	err := s.deleteBucket(bucketName, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Deleting bucket '%s' ... failed, error: %w", bucketName, err)
+		return err
+	}
+
+	s.bucketsMutex.Lock()
+	delete(s.bucketConfigs, bucketName)
+	delete(s.dataKeys, bucketName)
+	s.bucketsMutex.Unlock()
+
+	s.resetUsage(bucketName)
+
+	if s.catalog != nil {
+		if err := s.catalog.DeleteBucket(bucketName); err != nil {
+			s.WrappedLogger.LogWarnf("Can't remove bucket '%s' from the catalog, error: %s", bucketName, err)
+		}
+	}
+
+	s.WrappedLogger.LogInfof("Deleting bucket '%s' ... done", bucketName)
+	return nil
+}
+
+func (s *Storage) deleteBucket(bucketName string, ctx context.Context) error {
+	objects, err := s.backend.ListObjects(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if !isReservedKey(object.Key) {
+			return ErrBucketNotEmpty
+		}
+	}
+	for _, object := range objects {
+		if err := s.backend.DeleteObject(bucketName, object.Key, ctx); err != nil {
+			return err
+		}
+	}
+	return s.backend.DeleteBucket(bucketName, ctx)
+}
+
+// encodeTagValue encodes a value containing characters not allowed in S3 tags.
+func encodeTagValue(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeTagValue(value string) string {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return ""
+	}
+	return string(decoded)
+}
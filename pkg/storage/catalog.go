@@ -0,0 +1,372 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// CatalogEntry describes an object written by the Collector.
+type CatalogEntry struct {
+	BlockId            string    `json:"blockId"`
+	Bucket             string    `json:"bucket"`
+	Key                string    `json:"key"`
+	Tag                string    `json:"tag,omitempty"`
+	FilterId           string    `json:"filterId,omitempty"`
+	MilestoneIndex     uint32    `json:"milestoneIndex,omitempty"`
+	MilestoneTimestamp uint32    `json:"milestoneTimestamp,omitempty"`
+	POI                bool      `json:"poi"`
+	Size               int64     `json:"size"`
+	Checksum           string    `json:"checksum,omitempty"`
+	LastModified       time.Time `json:"lastModified"`
+}
+
+// Catalog is the local metadata index of the stored objects, kept in an embedded bbolt database
+// with one bbolt bucket per storage bucket, keyed by block id.
+// It lets the objects be listed and queried without scanning the storage, and can be rebuilt from the buckets.
+type Catalog struct {
+	db *bolt.DB
+}
+
+// OpenCatalog opens the catalog kept in the given file, creating it if needed.
+func OpenCatalog(path string) (*Catalog, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open catalog '%s', error: %w", path, err)
+	}
+	return &Catalog{db: db}, nil
+}
+
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// Empty reports whether the catalog holds no bucket, as when it was just created.
+func (c *Catalog) Empty() (bool, error) {
+	empty := true
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, _ *bolt.Bucket) error {
+			empty = false
+			return nil
+		})
+	})
+	return empty, err
+}
+
+// Put records the entry, replacing the previous entry of the block in the same bucket.
+func (c *Catalog) Put(entry CatalogEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(entry.Bucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(entry.BlockId), value)
+	})
+}
+
+// Get returns the entry of the block, ErrObjectNotFound if there is none.
+func (c *Catalog) Get(bucketName string, blockId string) (CatalogEntry, error) {
+	var entry CatalogEntry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return ErrObjectNotFound
+		}
+		value := bucket.Get([]byte(blockId))
+		if value == nil {
+			return ErrObjectNotFound
+		}
+		return json.Unmarshal(value, &entry)
+	})
+	return entry, err
+}
+
+// Delete removes the entry of the block, deleting a missing entry is not an error.
+func (c *Catalog) Delete(bucketName string, blockId string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(blockId))
+	})
+}
+
+// ForEach calls fn for every entry of the bucket, in block id order, until fn returns an error.
+func (c *Catalog) ForEach(bucketName string, fn func(CatalogEntry) error) error {
+	return c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_ []byte, value []byte) error {
+			var entry CatalogEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			return fn(entry)
+		})
+	})
+}
+
+// Buckets returns the buckets with entries in the catalog.
+func (c *Catalog) Buckets() ([]string, error) {
+	var buckets []string
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			buckets = append(buckets, string(name))
+			return nil
+		})
+	})
+	return buckets, err
+}
+
+// DeleteBucket removes every entry of the bucket.
+func (c *Catalog) DeleteBucket(bucketName string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucketName))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// replaceBucket records the entries found in the bucket, and removes the entries of the objects no longer in it,
+// except the ones modified after since, which were written while the bucket was being listed.
+func (c *Catalog) replaceBucket(bucketName string, entries []CatalogEntry, since time.Time) error {
+	found := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		found[entry.BlockId] = struct{}{}
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+
+		var stale [][]byte
+		err = bucket.ForEach(func(key []byte, value []byte) error {
+			if _, ok := found[string(key)]; ok {
+				return nil
+			}
+			var entry CatalogEntry
+			if err := json.Unmarshal(value, &entry); err != nil || entry.LastModified.Before(since) {
+				stale = append(stale, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range stale {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		for _, entry := range entries {
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(entry.BlockId), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Catalog returns the local metadata index of the stored objects, nil if it is disabled.
+func (s *Storage) Catalog() *Catalog {
+	return s.catalog
+}
+
+// catalogPut records an uploaded object in the catalog, the upload doesn't fail if the catalog can't be updated.
+func (s *Storage) catalogPut(entry CatalogEntry) {
+	if s.catalog == nil {
+		return
+	}
+	if err := s.catalog.Put(entry); err != nil {
+		s.WrappedLogger.LogWarnf("Can't record object '%s' of bucket '%s' in the catalog, error: %s", entry.Key, entry.Bucket, err)
+	}
+}
+
+// catalogDelete removes a deleted object from the catalog.
+func (s *Storage) catalogDelete(bucketName string, blockId string) {
+	if s.catalog == nil {
+		return
+	}
+	if err := s.catalog.Delete(bucketName, blockId); err != nil {
+		s.WrappedLogger.LogWarnf("Can't remove block '%s' of bucket '%s' from the catalog, error: %s", blockId, bucketName, err)
+	}
+}
+
+// RebuildCatalog rebuilds the catalog from the objects found in the buckets, it returns the number of objects recorded.
+// The entries of the objects uploaded while a bucket is being listed are kept.
+func (s *Storage) RebuildCatalog(ctx context.Context) (int, error) {
+	if s.catalog == nil {
+		return 0, fmt.Errorf("the catalog is disabled")
+	}
+
+	s.WrappedLogger.LogInfo("Rebuilding catalog ...")
+	count, err := s.rebuildCatalog(ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Rebuilding catalog ... failed, error: %w", err)
+		return count, err
+	}
+
+	s.WrappedLogger.LogInfof("Rebuilding catalog ... done, %d objects recorded", count)
+	return count, nil
+}
+
+func (s *Storage) rebuildCatalog(ctx context.Context) (int, error) {
+	buckets, err := s.backend.ListBuckets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	existing := make(map[string]struct{}, len(buckets))
+	for _, bucket := range buckets {
+		existing[bucket.Name] = struct{}{}
+		recorded, err := s.rebuildCatalogBucket(bucket.Name, ctx)
+		if err != nil {
+			return count, err
+		}
+		count += recorded
+	}
+
+	// the buckets deleted from the storage are dropped
+	cataloged, err := s.catalog.Buckets()
+	if err != nil {
+		return count, err
+	}
+	for _, bucketName := range cataloged {
+		if _, ok := existing[bucketName]; !ok {
+			if err := s.catalog.DeleteBucket(bucketName); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+// metadataLister is implemented by the backends able to return the metadata of the objects along with their listing.
+type metadataLister interface {
+	ListObjectsWithMetadata(bucketName string, ctx context.Context) ([]ObjectInfo, error)
+}
+
+func (s *Storage) rebuildCatalogBucket(bucketName string, ctx context.Context) (int, error) {
+	since := time.Now()
+	var objects []ObjectInfo
+	var err error
+	if lister, ok := s.backend.(metadataLister); ok {
+		objects, err = lister.ListObjectsWithMetadata(bucketName, ctx)
+	} else {
+		objects, err = s.backend.ListObjects(bucketName, ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	indexed, err := s.indexedObjects(bucketName, objects, ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make([]CatalogEntry, 0, len(objects))
+	for _, object := range objects {
+		if isReservedKey(object.Key) {
+			continue
+		}
+		// the objects are only read one by one when the backend didn't list their metadata
+		info := object
+		if info.Metadata == nil {
+			info, err = s.backend.StatObject(bucketName, object.Key, ctx)
+			if errors.Is(err, ErrObjectNotFound) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		entries = append(entries, catalogEntryFromInfo(bucketName, s.storedBlockId(object.Key, info, indexed), info))
+	}
+
+	return len(entries), s.catalog.replaceBucket(bucketName, entries, since)
+}
+
+// newCatalogEntry returns the entry of an object being stored.
+func newCatalogEntry(bucketName string, objectName string, attributes ObjectAttributes, object Object, size int64, hash string) CatalogEntry {
+	return CatalogEntry{
+		BlockId:            attributes.BlockId,
+		Bucket:             bucketName,
+		Key:                objectName,
+		Tag:                hex.EncodeToString(attributes.Tag),
+		FilterId:           attributes.FilterId,
+		MilestoneIndex:     attributes.MilestoneIndex,
+		MilestoneTimestamp: attributes.MilestoneTimestamp,
+		POI:                object.Proof != nil,
+		Size:               size,
+		Checksum:           hash,
+		LastModified:       time.Now(),
+	}
+}
+
+// tags returns the object tags of the entry which the lifecycle rules can match.
+func (e CatalogEntry) tags() map[string]string {
+	tags := map[string]string{ObjectTagPOI: strconv.FormatBool(e.POI)}
+	if e.Tag != "" {
+		tags[ObjectTagTag] = e.Tag
+	}
+	if e.FilterId != "" {
+		tags[ObjectTagFilterId] = e.FilterId
+	}
+	if e.MilestoneIndex != 0 {
+		tags[ObjectTagMilestoneIndex] = strconv.FormatUint(uint64(e.MilestoneIndex), 10)
+	}
+	return tags
+}
+
+// catalogEntryFromInfo returns the entry of a stored object from its metadata.
+func catalogEntryFromInfo(bucketName string, blockId string, info ObjectInfo) CatalogEntry {
+	entry := CatalogEntry{
+		BlockId:      blockId,
+		Bucket:       bucketName,
+		Key:          info.Key,
+		Tag:          info.Metadata[metadataTag],
+		FilterId:     info.Metadata[metadataFilterId],
+		Size:         info.Size,
+		Checksum:     storedChecksum(info.Metadata),
+		LastModified: info.LastModified,
+	}
+	entry.POI, _ = strconv.ParseBool(info.Metadata[metadataPOI])
+	if milestoneIndex, err := strconv.ParseUint(info.Metadata[metadataMilestoneIndex], 10, 32); err == nil {
+		entry.MilestoneIndex = uint32(milestoneIndex)
+	}
+	if milestoneTimestamp, err := time.Parse(time.RFC3339, info.Metadata[metadataMilestoneTimestamp]); err == nil {
+		entry.MilestoneTimestamp = uint32(milestoneTimestamp.Unix())
+	}
+	return entry
+}
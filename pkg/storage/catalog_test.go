@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCatalog returns a catalog kept in a temporary directory, closed at the end of the test.
+func newTestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	catalog, err := OpenCatalog(filepath.Join(t.TempDir(), "catalog", "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { catalog.Close() })
+	return catalog
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := newTestCatalog(t)
+	if empty, err := catalog.Empty(); err != nil || !empty {
+		t.Fatalf("new catalog is empty: %t (%v), want true", empty, err)
+	}
+
+	entries := []CatalogEntry{
+		{BlockId: "0xb", Bucket: "default", Key: "0xb", Size: 10},
+		{BlockId: "0xa", Bucket: "default", Key: "sensors/0xa", Tag: "73656e736f7273", POI: true, Size: 20},
+		{BlockId: "0xa", Bucket: "archive", Key: "0xa", Size: 30},
+	}
+	for _, entry := range entries {
+		if err := catalog.Put(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if entry, err := catalog.Get("default", "0xa"); err != nil || entry.Key != "sensors/0xa" || !entry.POI {
+		t.Errorf("entry is %+v (%v), want the one of sensors/0xa", entry, err)
+	}
+	if _, err := catalog.Get("default", "0xc"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for a missing entry, want %v", err, ErrObjectNotFound)
+	}
+	if _, err := catalog.Get("missing", "0xa"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for a missing bucket, want %v", err, ErrObjectNotFound)
+	}
+
+	var blockIds []string
+	err := catalog.ForEach("default", func(entry CatalogEntry) error {
+		blockIds = append(blockIds, entry.BlockId)
+		return nil
+	})
+	if err != nil || len(blockIds) != 2 || blockIds[0] != "0xa" || blockIds[1] != "0xb" {
+		t.Errorf("listed blocks are %v (%v), want 0xa and 0xb in order", blockIds, err)
+	}
+
+	if err := catalog.Delete("default", "0xa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Get("default", "0xa"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for a deleted entry, want %v", err, ErrObjectNotFound)
+	}
+	if err := catalog.Delete("missing", "0xa"); err != nil {
+		t.Errorf("error is %v deleting from a missing bucket, want none", err)
+	}
+
+	if err := catalog.DeleteBucket("archive"); err != nil {
+		t.Fatal(err)
+	}
+	if buckets, err := catalog.Buckets(); err != nil || len(buckets) != 1 || buckets[0] != "default" {
+		t.Errorf("buckets are %v (%v), want [default]", buckets, err)
+	}
+}
+
+func TestCatalogUploadDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	s.catalog = newTestCatalog(t)
+	if err := s.SetBucketConfig("default", BucketConfig{KeyLayout: "{tag}/{blockId}"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	object := withTestProof(t, newTestObject(t, "sensors", "temperature=21"))
+	attributes := newTestAttributes(t, object, 42)
+	attributes.FilterId = "f1"
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := s.Catalog().Get("default", attributes.BlockId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Key != "73656e736f7273/"+attributes.BlockId || entry.Tag != "73656e736f7273" || entry.FilterId != "f1" {
+		t.Errorf("entry is %+v", entry)
+	}
+	if !entry.POI || entry.MilestoneIndex != 42 || entry.Size == 0 || entry.Checksum == "" {
+		t.Errorf("entry is %+v", entry)
+	}
+
+	if err := s.DeleteObject("default", attributes.BlockId, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Catalog().Get("default", attributes.BlockId); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for the entry of a deleted object, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestRebuildCatalog(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	if err := s.SetBucketConfig("default", BucketConfig{KeyLayout: "{tag}/{blockId}"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the objects are stored before the catalog is enabled
+	var uploaded []ObjectAttributes
+	for _, data := range []string{"temperature=21", "temperature=22"} {
+		object := newTestObject(t, "sensors", data)
+		attributes := newTestAttributes(t, object, 42)
+		attributes.FilterId = "f1"
+		if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+			t.Fatal(err)
+		}
+		uploaded = append(uploaded, attributes)
+	}
+
+	catalog := newTestCatalog(t)
+	stale := []CatalogEntry{
+		// an object deleted while the catalog was disabled
+		{BlockId: "0xdeleted", Bucket: "default", Key: "0xdeleted", LastModified: time.Now().Add(-time.Hour)},
+		// a bucket deleted while the catalog was disabled
+		{BlockId: "0xa", Bucket: "deleted", Key: "0xa", LastModified: time.Now().Add(-time.Hour)},
+	}
+	for _, entry := range stale {
+		if err := catalog.Put(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.catalog = catalog
+
+	count, err := s.RebuildCatalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(uploaded) {
+		t.Errorf("%d objects recorded, want %d", count, len(uploaded))
+	}
+	for _, attributes := range uploaded {
+		entry, err := catalog.Get("default", attributes.BlockId)
+		if err != nil {
+			t.Fatalf("object '%s' is not recorded, error: %s", attributes.BlockId, err)
+		}
+		if entry.Key != "73656e736f7273/"+attributes.BlockId || entry.FilterId != "f1" || entry.MilestoneIndex != 42 {
+			t.Errorf("entry is %+v", entry)
+		}
+	}
+	if _, err := catalog.Get("default", "0xdeleted"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for the entry of a deleted object, want %v", err, ErrObjectNotFound)
+	}
+	if buckets, err := catalog.Buckets(); err != nil || len(buckets) != 1 || buckets[0] != "default" {
+		t.Errorf("buckets are %v (%v), want [default]", buckets, err)
+	}
+}
+
+func TestRebuildCatalogDisabled(t *testing.T) {
+	s := newTestStorage(t, Parameters{})
+	if _, err := s.RebuildCatalog(context.Background()); err == nil {
+		t.Error("expected an error rebuilding a disabled catalog")
+	}
+}
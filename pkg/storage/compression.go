@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionNone stores the objects as they are.
+	CompressionNone = ""
+	// CompressionGzip compresses the objects with gzip.
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the objects with zstd.
+	CompressionZstd = "zstd"
+)
+
+func validateCompression(compression string) error {
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("unknown compression '%s', supported are '%s' and '%s'", compression, CompressionGzip, CompressionZstd)
+	}
+}
+
+func compress(data []byte, compression string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		writer, err = gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	case CompressionZstd:
+		writer, err = zstd.NewWriter(&buffer, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	default:
+		err = validateCompression(compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompressReader returns a reader on the decompressed content of reader, closing it closes reader as well.
+func decompressReader(reader io.ReadCloser, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return reader, nil
+	case CompressionGzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return &chainedReadCloser{Reader: gzipReader, closers: []io.Closer{gzipReader, reader}}, nil
+	case CompressionZstd:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return &chainedReadCloser{Reader: zstdReader, closers: []io.Closer{zstdReader.IOReadCloser(), reader}}, nil
+	default:
+		return nil, validateCompression(compression)
+	}
+}
+
+// chainedReadCloser reads from a decoding reader and closes it together with the readers it wraps.
+type chainedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (c *chainedReadCloser) Close() error {
+	var err error
+	for _, closer := range c.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
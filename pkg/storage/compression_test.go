@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"block":{"payload":{"tag":"0x73656e736f7273","data":"0x74656d70657261747572653d3231"}}}`), 32)
+
+	tests := []struct {
+		name        string
+		compression string
+		smaller     bool
+	}{
+		{name: "none", compression: CompressionNone},
+		{name: "gzip", compression: CompressionGzip, smaller: true},
+		{name: "zstd", compression: CompressionZstd, smaller: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compressed, err := compress(data, test.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.smaller && len(compressed) >= len(data) {
+				t.Errorf("compressed to %d bytes, not smaller than %d", len(compressed), len(data))
+			}
+
+			reader, err := decompressReader(io.NopCloser(bytes.NewReader(compressed)), test.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decompressed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := reader.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Error("decompressed content differs from the original")
+			}
+		})
+	}
+}
+
+func TestCompressionInvalid(t *testing.T) {
+	if _, err := compress([]byte("data"), "lz4"); err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+	if _, err := decompressReader(io.NopCloser(bytes.NewReader(nil)), "lz4"); err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+	if _, err := decompressReader(io.NopCloser(bytes.NewReader([]byte("not gzip"))), CompressionGzip); err == nil {
+		t.Error("expected an error for a content which is not gzip")
+	}
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// uploadLockStripes is the number of locks serializing the uploads of the same block.
+const uploadLockStripes = 64
+
+// uploadLocks serializes the uploads of the same block to the same bucket,
+// so that concurrent filters can't interleave the check of the stored object and the upload.
+type uploadLocks [uploadLockStripes]sync.Mutex
+
+func (l *uploadLocks) lock(bucketName string, blockId string) func() {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(bucketName))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(blockId))
+	mutex := &l[hasher.Sum32()%uploadLockStripes]
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// contentChecksum returns the checksum of the serialized object: its hash, or its keyed checksum in an encrypted bucket,
+// as the hash of the plaintext would tell which known blocks the bucket holds.
+func (s *Storage) contentChecksum(bucketName string, config BucketConfig, data []byte, ctx context.Context) (string, error) {
+	if config.Encryption {
+		return s.keyedChecksum(bucketName, data, ctx)
+	}
+	return contentHash(data), nil
+}
+
+// checksumMetadata returns the metadata recording the checksum of the objects of a bucket.
+func checksumMetadata(encrypted bool) string {
+	if encrypted {
+		return metadataContentHMAC
+	}
+	return metadataContentSHA256
+}
+
+// storedChecksum returns the checksum recorded in the metadata of an object, whichever its kind.
+func storedChecksum(metadata map[string]string) string {
+	if checksum, ok := metadata[metadataContentHMAC]; ok {
+		return checksum
+	}
+	return metadata[metadataContentSHA256]
+}
+
+// storedObject returns the name and the information of the object already holding the block, if any.
+func (s *Storage) storedObject(bucketName string, blockId string, ctx context.Context) (string, *ObjectInfo, error) {
+	objectName, err := s.ResolveObjectName(bucketName, blockId, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		// the index points to a missing object
+		if objectName != blockId+s.objectExtension {
+			s.dropIndex(bucketName, blockId, ctx)
+		}
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return objectName, &info, nil
+}
+
+// skipUpload reports why the upload of an object with the given content hash can be skipped, empty if it can't:
+// an identical object is already stored, or the stored object has a proof of inclusion and the new one has none.
+func skipUpload(stored *ObjectInfo, hash string, withPOI bool) string {
+	if stored == nil {
+		return ""
+	}
+	if storedChecksum(stored.Metadata) == hash {
+		return "an identical object is already stored"
+	}
+	if storedPOI, _ := strconv.ParseBool(stored.Metadata[metadataPOI]); storedPOI && !withPOI {
+		return "the stored object has a proof of inclusion"
+	}
+	return ""
+}
+
+// filterRetentionDays returns the days after which the rule of the filter expires its objects, 0 if they don't expire,
+// as for the objects stored without filter.
+func filterRetentionDays(rules []LifecycleRule, filterId string) int {
+	if filterId == "" {
+		return 0
+	}
+	for _, rule := range rules {
+		if rule.Id == FilterLifecycleRulePrefix+filterId {
+			return rule.Days
+		}
+	}
+	return 0
+}
+
+// retainingFilter returns which of the filter which stored the block and of the filter storing it again keeps it the longest
+// in the bucket, the stored one if they keep it as long.
+func (s *Storage) retainingFilter(bucketName string, storedFilterId string, filterId string, ctx context.Context) (string, error) {
+	if filterId == storedFilterId {
+		return storedFilterId, nil
+	}
+	rules, err := s.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return "", err
+	}
+	storedDays := filterRetentionDays(rules, storedFilterId)
+	if storedDays == 0 {
+		return storedFilterId, nil
+	}
+	if days := filterRetentionDays(rules, filterId); days == 0 || days > storedDays {
+		return filterId, nil
+	}
+	return storedFilterId, nil
+}
+
+// retainObject hands the object holding a block stored by several filters over to the filter keeping it the longest:
+// the stored content is written again with the filter id of that filter, so that only its lifecycle rule matches the object.
+// A locked object can't be written again and is left as it is.
+func (s *Storage) retainObject(bucketName string, objectName string, stored *ObjectInfo, attributes ObjectAttributes, ctx context.Context) error {
+	if stored.Lock.IsLocked() {
+		s.WrappedLogger.LogWarnf("Can't hand locked object '%s' of bucket '%s' over to filter '%s'", objectName, bucketName, attributes.FilterId)
+		return nil
+	}
+
+	s.WrappedLogger.LogInfof("Handing object '%s' of bucket '%s' over to filter '%s' ...", objectName, bucketName, attributes.FilterId)
+	reader, info, err := s.backend.GetObject(bucketName, objectName, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Handing object '%s' of bucket '%s' over to filter '%s' ... failed, error: %w", objectName, bucketName, attributes.FilterId, err)
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Handing object '%s' of bucket '%s' over to filter '%s' ... failed, error: %w", objectName, bucketName, attributes.FilterId, err)
+		return err
+	}
+
+	metadata := make(map[string]string, len(info.Metadata))
+	for key, value := range info.Metadata {
+		metadata[key] = value
+	}
+	if attributes.FilterId == "" {
+		delete(metadata, metadataFilterId)
+	} else {
+		metadata[metadataFilterId] = attributes.FilterId
+	}
+	opts := PutOptions{ContentType: info.ContentType, Metadata: metadata, Tags: objectTags(metadata)}
+	err = s.backend.PutObject(bucketName, objectName, bytes.NewReader(data), int64(len(data)), opts, ctx)
+	if err == nil && objectName != attributes.BlockId+s.objectExtension {
+		// the index entry expires together with the object
+		err = s.writeIndex(bucketName, attributes.BlockId, objectName, opts.Tags, ctx)
+	}
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Handing object '%s' of bucket '%s' over to filter '%s' ... failed, error: %w", objectName, bucketName, attributes.FilterId, err)
+		return err
+	}
+
+	info.Metadata = metadata
+	info.Size = int64(len(data))
+	info.LastModified = time.Now()
+	s.catalogPut(catalogEntryFromInfo(bucketName, attributes.BlockId, info))
+
+	s.WrappedLogger.LogInfof("Handing object '%s' of bucket '%s' over to filter '%s' ... done", objectName, bucketName, attributes.FilterId)
+	return nil
+}
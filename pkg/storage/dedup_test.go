@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// countingBackend counts the objects written to the memory backend.
+type countingBackend struct {
+	*MemoryBackend
+	puts int
+}
+
+func (b *countingBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	if !isReservedKey(objectName) {
+		b.puts++
+	}
+	return b.MemoryBackend.PutObject(bucketName, objectName, reader, size, opts, ctx)
+}
+
+func TestSkipUpload(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  *ObjectInfo
+		hash    string
+		withPOI bool
+		skip    bool
+	}{
+		{name: "nothing stored", stored: nil, hash: "a", skip: false},
+		{name: "identical object", stored: storedInfo("a", false), hash: "a", skip: true},
+		{name: "identical object with proof", stored: storedInfo("a", true), hash: "a", withPOI: true, skip: true},
+		{name: "different object", stored: storedInfo("a", false), hash: "b", skip: false},
+		{name: "proof is not downgraded", stored: storedInfo("a", true), hash: "b", withPOI: false, skip: true},
+		{name: "proof is added", stored: storedInfo("a", false), hash: "b", withPOI: true, skip: false},
+		{name: "proof is replaced", stored: storedInfo("a", true), hash: "b", withPOI: true, skip: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := skipUpload(test.stored, test.hash, test.withPOI)
+			if skip := reason != ""; skip != test.skip {
+				t.Errorf("skip is %t (%q), want %t", skip, reason, test.skip)
+			}
+		})
+	}
+}
+
+func TestUploadDedup(t *testing.T) {
+	type upload struct {
+		withPOI   bool
+		keyLayout string
+	}
+	tests := []struct {
+		name      string
+		uploads   []upload
+		wantPuts  int
+		wantPOI   bool
+		wantCount int
+	}{
+		{name: "identical upload is skipped", uploads: []upload{{}, {}}, wantPuts: 1, wantCount: 1},
+		{name: "upload without proof keeps the proof", uploads: []upload{{withPOI: true}, {}}, wantPuts: 1, wantPOI: true, wantCount: 1},
+		{name: "upload with proof replaces the block", uploads: []upload{{}, {withPOI: true}}, wantPuts: 2, wantPOI: true, wantCount: 1},
+		{name: "identical upload under another layout is skipped", uploads: []upload{{}, {keyLayout: "{tag}/{blockId}"}}, wantPuts: 1, wantCount: 1},
+		{name: "upload with proof under another layout moves the block", uploads: []upload{{}, {withPOI: true, keyLayout: "{tag}/{blockId}"}}, wantPuts: 2, wantPOI: true, wantCount: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := &countingBackend{MemoryBackend: NewMemoryBackend()}
+			s := newTestStorageWithBackend(t, backend, Parameters{})
+
+			block := newTestObject(t, "sensors", "temperature=21")
+			var blockId string
+			for _, upload := range test.uploads {
+				object := block
+				if upload.withPOI {
+					object = withTestProof(t, block)
+				}
+				attributes := newTestAttributes(t, object, 42)
+				attributes.KeyLayout = upload.keyLayout
+				blockId = attributes.BlockId
+				if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if backend.puts != test.wantPuts {
+				t.Errorf("%d objects written, want %d", backend.puts, test.wantPuts)
+			}
+			objects, err := s.ListObjects("default", ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != test.wantCount {
+				t.Errorf("%d objects stored, want %d", len(objects), test.wantCount)
+			}
+
+			reader, format, err := s.GetObject("default", blockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			object, err := DecodeObject(reader, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if withPOI := object.Proof != nil; withPOI != test.wantPOI {
+				t.Errorf("stored object has a proof: %t, want %t", withPOI, test.wantPOI)
+			}
+		})
+	}
+}
+
+func storedInfo(hash string, withPOI bool) *ObjectInfo {
+	poi := "false"
+	if withPOI {
+		poi = "true"
+	}
+	return &ObjectInfo{Metadata: map[string]string{metadataContentSHA256: hash, metadataPOI: poi}}
+}
+
+func TestSharedBlockRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		filterIds []string
+		keyLayout string
+		want      string
+	}{
+		{name: "longer retention takes the block over", filterIds: []string{"short", "long"}, want: "long"},
+		{name: "shorter retention leaves the block", filterIds: []string{"long", "short"}, want: "long"},
+		{name: "filter without retention takes the block over", filterIds: []string{"short", "forever"}, want: "forever"},
+		{name: "filter with retention leaves the block", filterIds: []string{"forever", "short"}, want: "forever"},
+		{name: "indexed block is taken over", filterIds: []string{"short", "long"}, keyLayout: "{tag}/{blockId}", want: "long"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorage(t, Parameters{})
+			rules := []LifecycleRule{NewFilterLifecycleRule("short", 7), NewFilterLifecycleRule("long", 30)}
+			if _, err := s.ReconcileBucketLifecycle("default", rules, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			object := newTestObject(t, "sensors", "temperature=21")
+			attributes := newTestAttributes(t, object, 42)
+			attributes.KeyLayout = test.keyLayout
+			for _, filterId := range test.filterIds {
+				attributes.FilterId = filterId
+				if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			objectName, err := s.ResolveObjectName("default", attributes.BlockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := s.backend.StatObject("default", objectName, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filterId := info.Metadata[metadataFilterId]; filterId != test.want {
+				t.Errorf("object is stored for filter '%s', want '%s'", filterId, test.want)
+			}
+			if filterId := info.Tags[ObjectTagFilterId]; filterId != test.want {
+				t.Errorf("object is tagged with filter '%s', want '%s'", filterId, test.want)
+			}
+			if test.keyLayout != "" {
+				index, err := s.backend.StatObject("default", indexKey(attributes.BlockId), ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if filterId := index.Tags[ObjectTagFilterId]; filterId != test.want {
+					t.Errorf("index is tagged with filter '%s', want '%s'", filterId, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// EncryptionAESGCM is the encryption applied to the objects of the buckets with encryption enabled.
+	EncryptionAESGCM = "aes-256-gcm"
+
+	// bucketTagDataKey holds the data key of the bucket, wrapped by a master key, as "<masterKeyId>:<base64 wrapped key>"
+	bucketTagDataKey = bucketTagPrefix + "datakey"
+
+	dataKeySize = 32
+
+	// checksumKeyLabel derives the key of the checksums of a bucket from its data key, so that the data key only seals the objects
+	checksumKeyLabel = "collector content checksum"
+)
+
+// MasterKeyFile is the content of the file holding the master keys.
+// Rotating the master key means adding a new key, making it the current one and re-wrapping the data keys,
+// the previous keys can be removed from the file once no data key is wrapped by them anymore.
+type MasterKeyFile struct {
+	// CurrentKeyId is the id of the master key wrapping the new data keys
+	CurrentKeyId string `json:"currentKeyId"`
+	// Keys maps the master key ids to the hex encoded 32 bytes keys
+	Keys map[string]string `json:"keys"`
+}
+
+// MasterKeys wraps and unwraps the per-bucket data keys.
+type MasterKeys struct {
+	path      string
+	mutex     sync.RWMutex
+	currentId string
+	keys      map[string]cipher.AEAD
+}
+
+func NewMasterKeys(path string) (*MasterKeys, error) {
+	masterKeys := &MasterKeys{path: path}
+	if err := masterKeys.Reload(); err != nil {
+		return nil, err
+	}
+	return masterKeys, nil
+}
+
+// Reload reads the master keys from the file again.
+func (m *MasterKeys) Reload() error {
+	fileBytes, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("can't read master key file, error: %w", err)
+	}
+	var file MasterKeyFile
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		return fmt.Errorf("can't parse master key file, error: %w", err)
+	}
+
+	keys := make(map[string]cipher.AEAD, len(file.Keys))
+	for id, keyHex := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("invalid master key id '%s'", id)
+		}
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return fmt.Errorf("can't decode master key '%s', error: %w", id, err)
+		}
+		if len(key) != dataKeySize {
+			return fmt.Errorf("invalid length for master key '%s', got %d, wanted %d", id, len(key), dataKeySize)
+		}
+		keys[id], err = newAEAD(key)
+		if err != nil {
+			return err
+		}
+	}
+	if _, ok := keys[file.CurrentKeyId]; !ok {
+		return fmt.Errorf("current master key '%s' is not defined", file.CurrentKeyId)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.currentId = file.CurrentKeyId
+	m.keys = keys
+	return nil
+}
+
+// CurrentKeyId returns the id of the master key wrapping the new data keys.
+func (m *MasterKeys) CurrentKeyId() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.currentId
+}
+
+// wrap seals the data key with the current master key.
+func (m *MasterKeys) wrap(dataKey []byte) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	wrapped, err := seal(m.keys[m.currentId], dataKey)
+	if err != nil {
+		return "", err
+	}
+	return m.currentId + ":" + base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrap opens a data key wrapped by any of the master keys, it also returns the id of that master key.
+func (m *MasterKeys) unwrap(wrappedKey string) ([]byte, string, error) {
+	id, encoded, found := strings.Cut(wrappedKey, ":")
+	if !found {
+		return nil, "", fmt.Errorf("malformed wrapped data key")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed wrapped data key, error: %w", err)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	aead, ok := m.keys[id]
+	if !ok {
+		return nil, "", fmt.Errorf("master key '%s' is not defined", id)
+	}
+	dataKey, err := open(aead, wrapped)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't unwrap data key with master key '%s', error: %w", id, err)
+	}
+	return dataKey, id, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext, the random nonce is prepended to the ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+}
+
+// bucketKeys are the keys of an encrypted bucket, derived from its data key.
+type bucketKeys struct {
+	// aead seals the objects
+	aead cipher.AEAD
+	// checksumKey keys the checksums of the objects, so that they don't reveal the blocks the bucket holds
+	checksumKey []byte
+}
+
+// dataKey returns the cipher sealing the objects of the bucket.
+func (s *Storage) dataKey(bucketName string, ctx context.Context) (cipher.AEAD, error) {
+	keys, err := s.bucketKeys(bucketName, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys.aead, nil
+}
+
+// keyedChecksum returns the hex encoded HMAC-SHA256 of the serialized object, keyed for the encrypted bucket.
+func (s *Storage) keyedChecksum(bucketName string, data []byte, ctx context.Context) (string, error) {
+	keys, err := s.bucketKeys(bucketName, ctx)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, keys.checksumKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// bucketKeys returns the keys of the encrypted bucket. The data key is only created with the configuration of the bucket,
+// so that the collectors sharing the bucket never race to create it while uploading.
+func (s *Storage) bucketKeys(bucketName string, ctx context.Context) (*bucketKeys, error) {
+	if s.masterKeys == nil {
+		return nil, fmt.Errorf("bucket '%s' is encrypted, but no master key file is configured", bucketName)
+	}
+
+	s.bucketsMutex.Lock()
+	defer s.bucketsMutex.Unlock()
+
+	if keys, ok := s.dataKeys[bucketName]; ok {
+		return keys, nil
+	}
+
+	tags, err := s.backend.GetBucketTags(bucketName, ctx)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, ok := tags[bucketTagDataKey]
+	if !ok {
+		return nil, fmt.Errorf("bucket '%s' has no data key", bucketName)
+	}
+	dataKey, _, err := s.masterKeys.unwrap(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write([]byte(checksumKeyLabel))
+	keys := &bucketKeys{aead: aead, checksumKey: mac.Sum(nil)}
+	s.dataKeys[bucketName] = keys
+	return keys, nil
+}
+
+// addDataKey adds a new wrapped data key to the tags of the bucket, unless it already has one.
+func (s *Storage) addDataKey(bucketName string, tags map[string]string) error {
+	if _, ok := tags[bucketTagDataKey]; ok {
+		return nil
+	}
+
+	s.WrappedLogger.LogInfof("Creating data key for bucket '%s' ...", bucketName)
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	wrappedKey, err := s.masterKeys.wrap(dataKey)
+	if err != nil {
+		return err
+	}
+	tags[bucketTagDataKey] = wrappedKey
+	s.WrappedLogger.LogInfof("Creating data key for bucket '%s' ... done", bucketName)
+	return nil
+}
+
+// EncryptionEnabled reports whether a master key file is configured.
+func (s *Storage) EncryptionEnabled() bool {
+	return s.masterKeys != nil
+}
+
+// RotateMasterKey reloads the master key file and re-wraps with the current master key
+// the data keys of every bucket that are wrapped by a previous one. It returns the number of re-wrapped keys.
+func (s *Storage) RotateMasterKey(ctx context.Context) (int, error) {
+	if s.masterKeys == nil {
+		return 0, fmt.Errorf("no master key file is configured")
+	}
+	if err := s.masterKeys.Reload(); err != nil {
+		return 0, err
+	}
+
+	buckets, err := s.backend.ListBuckets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.bucketsMutex.Lock()
+	defer s.bucketsMutex.Unlock()
+
+	currentId := s.masterKeys.CurrentKeyId()
+	rewrapped := 0
+	for _, bucket := range buckets {
+		tags, err := s.backend.GetBucketTags(bucket.Name, ctx)
+		if err != nil {
+			return rewrapped, err
+		}
+		wrappedKey, ok := tags[bucketTagDataKey]
+		if !ok {
+			continue
+		}
+		dataKey, id, err := s.masterKeys.unwrap(wrappedKey)
+		if err != nil {
+			return rewrapped, fmt.Errorf("bucket '%s': %w", bucket.Name, err)
+		}
+		if id == currentId {
+			continue
+		}
+
+		s.WrappedLogger.LogInfof("Re-wrapping data key of bucket '%s' with master key '%s' ...", bucket.Name, currentId)
+		tags[bucketTagDataKey], err = s.masterKeys.wrap(dataKey)
+		if err != nil {
+			return rewrapped, err
+		}
+		if err := s.backend.SetBucketTags(bucket.Name, tags, ctx); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+		s.WrappedLogger.LogInfof("Re-wrapping data key of bucket '%s' with master key '%s' ... done", bucket.Name, currentId)
+	}
+
+	return rewrapped, nil
+}
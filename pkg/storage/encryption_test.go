@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeMasterKeys writes a master key file holding a key derived from each id, the first one being the current key.
+func writeMasterKeys(t *testing.T, path string, ids ...string) {
+	t.Helper()
+	file := MasterKeyFile{CurrentKeyId: ids[0], Keys: make(map[string]string)}
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		file.Keys[id] = hex.EncodeToString(key[:])
+	}
+	fileBytes, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, fileBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMasterKeysWrapUnwrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeMasterKeys(t, path, "k1", "k2")
+	masterKeys, err := NewMasterKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	wrapped, err := masterKeys.wrap(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wrapped, "k1:") {
+		t.Errorf("data key wrapped as '%s', want it wrapped by 'k1'", wrapped)
+	}
+	unwrapped, id, err := masterKeys.unwrap(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "k1" || !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("unwrapped data key %x with '%s', want %x with 'k1'", unwrapped, id, dataKey)
+	}
+
+	_, encoded, _ := strings.Cut(wrapped, ":")
+	tests := []struct {
+		name    string
+		wrapped string
+	}{
+		{name: "no master key id", wrapped: encoded},
+		{name: "unknown master key", wrapped: "k3:" + encoded},
+		{name: "other master key", wrapped: "k2:" + encoded},
+		{name: "invalid base64", wrapped: "k1:!"},
+		{name: "too short", wrapped: "k1:AAAA"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := masterKeys.unwrap(test.wrapped); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMasterKeysInvalidFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "not json", content: "keys"},
+		{name: "unknown current key", content: `{"currentKeyId": "k2", "keys": {"k1": "` + strings.Repeat("01", dataKeySize) + `"}}`},
+		{name: "short key", content: `{"currentKeyId": "k1", "keys": {"k1": "0102"}}`},
+		{name: "not hex", content: `{"currentKeyId": "k1", "keys": {"k1": "` + strings.Repeat("zz", dataKeySize) + `"}}`},
+		{name: "id with colon", content: `{"currentKeyId": "k:1", "keys": {"k:1": "` + strings.Repeat("01", dataKeySize) + `"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewMasterKeys(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestStoredObjectCompressionEncryption(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		compression string
+		encryption  bool
+		format      string
+	}{
+		{name: "plain"},
+		{name: "gzip", compression: CompressionGzip},
+		{name: "zstd", compression: CompressionZstd},
+		{name: "encrypted", encryption: true},
+		{name: "gzip encrypted", compression: CompressionGzip, encryption: true},
+		{name: "zstd encrypted binary", compression: CompressionZstd, encryption: true, format: FormatBinary},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			writeMasterKeys(t, path, "k1")
+			backend := &countingBackend{MemoryBackend: NewMemoryBackend()}
+			s := newTestStorageWithBackend(t, backend, Parameters{})
+			var err error
+			if s.masterKeys, err = NewMasterKeys(path); err != nil {
+				t.Fatal(err)
+			}
+			config := BucketConfig{Compression: test.compression, Encryption: test.encryption, Format: test.format}
+			if err := s.SetBucketConfig("default", config, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			object := newTestObject(t, "sensors", "temperature=21")
+			attributes := newTestAttributes(t, object, 42)
+			if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			raw, info, err := s.backend.GetObject("default", attributes.BlockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rawBytes, err := io.ReadAll(raw)
+			raw.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Metadata[metadataEncoding] != test.compression {
+				t.Errorf("stored with encoding '%s', want '%s'", info.Metadata[metadataEncoding], test.compression)
+			}
+			if encrypted := info.Metadata[metadataEncryption] == EncryptionAESGCM; encrypted != test.encryption {
+				t.Errorf("stored encrypted: %t, want %t", encrypted, test.encryption)
+			}
+			if test.compression != CompressionNone || test.encryption {
+				if bytes.Contains(rawBytes, []byte(hex.EncodeToString([]byte("temperature=21")))) {
+					t.Error("stored content holds the data in clear")
+				}
+			}
+			// the hash of the content would tell which known blocks an encrypted bucket holds, its checksum is keyed instead
+			_, hashed := info.Metadata[metadataContentSHA256]
+			_, keyed := info.Metadata[metadataContentHMAC]
+			if hashed == test.encryption || keyed != test.encryption {
+				t.Errorf("stored with a hash: %t and a keyed checksum: %t, want a keyed checksum only if encrypted", hashed, keyed)
+			}
+
+			// the checksum still identifies the object for the dedup and the scrub
+			if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if backend.puts != 1 {
+				t.Errorf("%d objects written, want the identical upload to be skipped", backend.puts)
+			}
+			issue, err := s.ScrubObject("default", ScrubTarget{Key: attributes.BlockId, BlockId: attributes.BlockId}, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if issue != nil {
+				t.Errorf("scrub found %s object: %s", issue.Problem, issue.Detail)
+			}
+
+			reader, format, err := s.GetObject("default", attributes.BlockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			decoded, err := DecodeObject(reader, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Block.MustID() != object.Block.MustID() {
+				t.Error("the stored block differs from the uploaded one")
+			}
+		})
+	}
+}
+
+func TestEncryptedBucketRequiresDataKey(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+
+	// without master keys, an encrypted bucket can be neither configured nor written
+	if err := s.SetBucketConfig("default", BucketConfig{Encryption: true}, ctx); err == nil {
+		t.Error("expected an error configuring an encrypted bucket without master keys")
+	}
+	s.bucketConfigs["default"] = cachedBucketConfig{config: BucketConfig{Encryption: true}, read: time.Now()}
+	object := newTestObject(t, "sensors", "temperature=21")
+	if err := s.UploadObject(newTestAttributes(t, object, 42), "default", object, ctx); err == nil {
+		t.Error("expected an error uploading to an encrypted bucket without master keys")
+	}
+}
+
+func TestRotateMasterKey(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeMasterKeys(t, path, "k1")
+	s := newTestStorage(t, Parameters{})
+	var err error
+	if s.masterKeys, err = NewMasterKeys(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBucketConfig("default", BucketConfig{Encryption: true}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new master key becomes the current one, the data keys are re-wrapped once
+	writeMasterKeys(t, path, "k2", "k1")
+	for _, want := range []int{1, 0} {
+		rewrapped, err := s.RotateMasterKey(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rewrapped != want {
+			t.Errorf("%d data keys re-wrapped, want %d", rewrapped, want)
+		}
+	}
+
+	// a collector only knowing the new master key reads the objects
+	writeMasterKeys(t, path, "k2")
+	other := NewStorageWithBackend(s.backend, Parameters{}, s.WrappedLogger)
+	if other.masterKeys, err = NewMasterKeys(path); err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := other.GetObject("default", attributes.BlockId, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+}
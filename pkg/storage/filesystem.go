@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+const (
+	// BackendFilesystem selects a backend keeping buckets as directories and objects as files on local disk.
+	BackendFilesystem = "filesystem"
+
+	filesystemMetaDir    = ".meta"
+	filesystemBucketsDir = ".buckets"
+)
+
+// FilesystemBackend stores the buckets as directories and the objects as files on local disk.
+type FilesystemBackend struct {
+	*logger.WrappedLogger
+	path          string
+	sweepInterval time.Duration
+	// mutex guards the bucket configuration files
+	mutex sync.Mutex
+}
+
+type filesystemBucketConfig struct {
+	// ExpirationDays is only read from the configurations written before the lifecycle rules, as a bucket-wide rule
+	ExpirationDays int               `json:"expirationDays,omitempty"`
+	Lifecycle      []LifecycleRule   `json:"lifecycle,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type filesystemObjectMeta struct {
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+func NewFilesystemBackend(path string, sweepInterval time.Duration, log *logger.WrappedLogger) (*FilesystemBackend, error) {
+	for _, dir := range []string{path, filepath.Join(path, filesystemMetaDir), filepath.Join(path, filesystemBucketsDir)} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FilesystemBackend{
+		WrappedLogger: logger.NewWrappedLogger(log.LoggerNamed("Filesystem")),
+		path:          path,
+		sweepInterval: sweepInterval,
+	}, nil
+}
+
+func (f *FilesystemBackend) CreateBucket(bucketName string, ctx context.Context) error {
+	bucketPath, err := f.bucketPath(bucketName)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(bucketPath, 0o700); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("bucket '%s' already exists", bucketName)
+		}
+		return err
+	}
+	return f.writeBucketConfig(bucketName, filesystemBucketConfig{})
+}
+
+func (f *FilesystemBackend) DeleteBucket(bucketName string, ctx context.Context) error {
+	objects, err := f.ListObjects(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	if len(objects) != 0 {
+		return ErrBucketNotEmpty
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// the bucket can still hold empty directories left by the deleted objects
+	bucketPath, _ := f.bucketPath(bucketName)
+	if err := os.RemoveAll(bucketPath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(f.path, filesystemMetaDir, bucketName)); err != nil {
+		return err
+	}
+	if err := os.Remove(f.bucketConfigPath(bucketName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FilesystemBackend) BucketExists(bucketName string, ctx context.Context) (bool, error) {
+	bucketPath, err := f.bucketPath(bucketName)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(bucketPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (f *FilesystemBackend) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []BucketInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, BucketInfo{Name: entry.Name(), CreationDate: info.ModTime()})
+	}
+	return buckets, nil
+}
+
+func (f *FilesystemBackend) SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	config, err := f.readBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Lifecycle = copyRules(rules)
+	return f.writeBucketConfig(bucketName, config)
+}
+
+func (f *FilesystemBackend) GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	config, err := f.readBucketConfig(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return config.Lifecycle, nil
+}
+
+func (f *FilesystemBackend) SetBucketTags(bucketName string, tags map[string]string, ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	config, err := f.readBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Tags = tags
+	return f.writeBucketConfig(bucketName, config)
+}
+
+func (f *FilesystemBackend) GetBucketTags(bucketName string, ctx context.Context) (map[string]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	config, err := f.readBucketConfig(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if config.Tags == nil {
+		return map[string]string{}, nil
+	}
+	return config.Tags, nil
+}
+
+func (f *FilesystemBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	objectPath, err := f.objectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if exists, err := f.BucketExists(bucketName, ctx); err != nil {
+		return err
+	} else if !exists {
+		return ErrBucketNotFound
+	}
+
+	metaBytes, err := json.Marshal(filesystemObjectMeta{ContentType: opts.ContentType, Metadata: opts.Metadata, Tags: opts.Tags})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(f.metaPath(bucketName, objectName), bytes.NewReader(metaBytes)); err != nil {
+		return err
+	}
+	return writeFileAtomic(objectPath, reader)
+}
+
+func (f *FilesystemBackend) GetObject(bucketName string, objectName string, ctx context.Context) (io.ReadCloser, ObjectInfo, error) {
+	objectPath, err := f.objectPath(bucketName, objectName)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	file, err := os.Open(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return file, f.objectInfo(bucketName, objectName, info), nil
+}
+
+func (f *FilesystemBackend) StatObject(bucketName string, objectName string, ctx context.Context) (ObjectInfo, error) {
+	objectPath, err := f.objectPath(bucketName, objectName)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return f.objectInfo(bucketName, objectName, info), nil
+}
+
+func (f *FilesystemBackend) DeleteObject(bucketName string, objectName string, ctx context.Context) error {
+	objectPath, err := f.objectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	// deleting a missing object is not an error, as for S3
+	if err := os.Remove(objectPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(f.metaPath(bucketName, objectName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FilesystemBackend) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	bucketPath, err := f.bucketPath(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	err = filepath.WalkDir(bucketPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == bucketPath {
+				return ErrBucketNotFound
+			}
+			return err
+		}
+		if entry.IsDir() || isTempFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objectName, err := filepath.Rel(bucketPath, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, f.objectInfo(bucketName, filepath.ToSlash(objectName), info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// Run deletes the objects older than the expiration days of their bucket every sweep interval.
+func (f *FilesystemBackend) Run(ctx context.Context) {
+	if f.sweepInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(f.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		f.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *FilesystemBackend) sweep(ctx context.Context) {
+	buckets, err := f.ListBuckets(ctx)
+	if err != nil {
+		f.WrappedLogger.LogErrorf("Sweeping expired objects ... failed, error: %w", err)
+		return
+	}
+
+	for _, bucket := range buckets {
+		bucketName := bucket.Name
+
+		rules, err := f.GetBucketLifecycle(bucketName, ctx)
+		if err != nil {
+			f.WrappedLogger.LogErrorf("Sweeping bucket '%s' ... failed, error: %w", bucketName, err)
+			continue
+		}
+		if len(rules) == 0 {
+			continue
+		}
+
+		objects, err := f.ListObjects(bucketName, ctx)
+		if err != nil {
+			f.WrappedLogger.LogErrorf("Sweeping bucket '%s' ... failed, error: %w", bucketName, err)
+			continue
+		}
+
+		removed := 0
+		for _, object := range objects {
+			if !isObjectExpired(rules, object) {
+				continue
+			}
+			if err := f.DeleteObject(bucketName, object.Key, ctx); err != nil {
+				f.WrappedLogger.LogErrorf("Deleting expired object '%s' from bucket '%s' ... failed, error: %w", object.Key, bucketName, err)
+				continue
+			}
+			removed++
+		}
+		if removed > 0 {
+			f.WrappedLogger.LogInfof("Deleted %d expired objects from bucket '%s'", removed, bucketName)
+		}
+	}
+}
+
+func (f *FilesystemBackend) objectInfo(bucketName string, objectName string, info fs.FileInfo) ObjectInfo {
+	objectInfo := ObjectInfo{
+		Key:          objectName,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+
+	metaBytes, err := os.ReadFile(f.metaPath(bucketName, objectName))
+	if err != nil {
+		return objectInfo
+	}
+	var meta filesystemObjectMeta
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return objectInfo
+	}
+	objectInfo.ContentType = meta.ContentType
+	objectInfo.Metadata = meta.Metadata
+	objectInfo.Tags = meta.Tags
+	return objectInfo
+}
+
+func (f *FilesystemBackend) readBucketConfig(bucketName string) (filesystemBucketConfig, error) {
+	var config filesystemBucketConfig
+	if _, err := f.bucketPath(bucketName); err != nil {
+		return config, err
+	}
+
+	configBytes, err := os.ReadFile(f.bucketConfigPath(bucketName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, ErrBucketNotFound
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		return config, err
+	}
+	// the expiration days of the older configurations become a bucket-wide rule
+	if config.ExpirationDays != 0 {
+		if len(config.Lifecycle) == 0 {
+			config.Lifecycle = []LifecycleRule{{Id: BucketLifecycleRuleId, Days: config.ExpirationDays}}
+		}
+		config.ExpirationDays = 0
+	}
+	return config, nil
+}
+
+func (f *FilesystemBackend) writeBucketConfig(bucketName string, config filesystemBucketConfig) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.bucketConfigPath(bucketName), bytes.NewReader(configBytes))
+}
+
+func (f *FilesystemBackend) bucketPath(bucketName string) (string, error) {
+	if bucketName == "" || strings.HasPrefix(bucketName, ".") || strings.ContainsAny(bucketName, `/\`) {
+		return "", fmt.Errorf("invalid bucket name '%s'", bucketName)
+	}
+	return filepath.Join(f.path, bucketName), nil
+}
+
+func (f *FilesystemBackend) bucketConfigPath(bucketName string) string {
+	return filepath.Join(f.path, filesystemBucketsDir, bucketName+".json")
+}
+
+func (f *FilesystemBackend) objectPath(bucketName string, objectName string) (string, error) {
+	bucketPath, err := f.bucketPath(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if objectName == "" || isTempFile(objectName) {
+		return "", fmt.Errorf("invalid object name '%s'", objectName)
+	}
+	for _, element := range strings.Split(objectName, "/") {
+		if element == "" || element == "." || element == ".." {
+			return "", fmt.Errorf("invalid object name '%s'", objectName)
+		}
+	}
+	return filepath.Join(bucketPath, filepath.FromSlash(objectName)), nil
+}
+
+func (f *FilesystemBackend) metaPath(bucketName string, objectName string) string {
+	return filepath.Join(f.path, filesystemMetaDir, bucketName, filepath.FromSlash(objectName)+".json")
+}
+
+const tempFileSuffix = ".tmp"
+
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, tempFileSuffix)
+}
+
+// writeFileAtomic writes the content of reader to a temporary file and renames it to path once complete,
+// so that readers never see partially written files.
+func writeFileAtomic(path string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+tempFileSuffix)
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	_, err = io.Copy(file, reader)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
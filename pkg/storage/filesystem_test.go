@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+// newTestFilesystemBackend returns a filesystem backend in a temporary directory, with the archive bucket created.
+func newTestFilesystemBackend(t *testing.T) *FilesystemBackend {
+	t.Helper()
+	f, err := NewFilesystemBackend(t.TempDir(), 0, logger.NewWrappedLogger(logger.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.CreateBucket("archive", context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFilesystemBackendBuckets(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFilesystemBackend(t)
+
+	if err := f.CreateBucket("archive", ctx); err == nil {
+		t.Error("expected an error creating the bucket twice")
+	}
+	for _, bucketName := range []string{"", ".meta", "a/b"} {
+		if err := f.CreateBucket(bucketName, ctx); err == nil {
+			t.Errorf("expected an error creating the bucket '%s'", bucketName)
+		}
+	}
+	buckets, err := f.ListBuckets(ctx)
+	if err != nil || len(buckets) != 1 || buckets[0].Name != "archive" {
+		t.Errorf("buckets are %v (%v), want [archive]", buckets, err)
+	}
+
+	tags := map[string]string{"collector-format": FormatBinary}
+	if err := f.SetBucketTags("archive", tags, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := f.GetBucketTags("archive", ctx); err != nil || stored["collector-format"] != FormatBinary {
+		t.Errorf("bucket tags are %v (%v)", stored, err)
+	}
+	if _, err := f.GetBucketTags("missing", ctx); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("error is %v reading the tags of a missing bucket, want %v", err, ErrBucketNotFound)
+	}
+
+	if err := f.PutObject("archive", "2023/object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DeleteBucket("archive", ctx); !errors.Is(err, ErrBucketNotEmpty) {
+		t.Errorf("error is %v deleting a bucket with objects, want %v", err, ErrBucketNotEmpty)
+	}
+	if err := f.DeleteObject("archive", "2023/object", ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the directory left by the object doesn't keep the bucket from being deleted
+	if err := f.DeleteBucket("archive", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := f.BucketExists("archive", ctx); err != nil || exists {
+		t.Errorf("deleted bucket exists: %t (%v), want false", exists, err)
+	}
+}
+
+func TestFilesystemBackendObjects(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFilesystemBackend(t)
+
+	opts := PutOptions{ContentType: "application/json", Metadata: map[string]string{"poi": "true"}, Tags: map[string]string{"tag": "sensors"}}
+	if err := f.PutObject("archive", "sensors/2023/object", bytes.NewReader([]byte("data")), 4, opts, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, info, err := f.GetObject("archive", "sensors/2023/object", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "data" || info.Size != 4 || info.ContentType != opts.ContentType {
+		t.Errorf("object is %q of %d bytes and type '%s'", data, info.Size, info.ContentType)
+	}
+	if info.Metadata["poi"] != "true" || info.Tags["tag"] != "sensors" {
+		t.Errorf("object metadata is %v and tags are %v", info.Metadata, info.Tags)
+	}
+
+	// the temporary files of an interrupted upload are not objects
+	if err := os.WriteFile(filepath.Join(f.path, "archive", "partial"+tempFileSuffix), []byte("da"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := f.ListObjects("archive", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != "sensors/2023/object" || objects[0].Tags["tag"] != "sensors" {
+		t.Errorf("objects are %v, want sensors/2023/object", objects)
+	}
+
+	for _, objectName := range []string{"", "../archive/object", "sensors//object", "partial" + tempFileSuffix} {
+		if err := f.PutObject("archive", objectName, bytes.NewReader(nil), 0, PutOptions{}, ctx); err == nil {
+			t.Errorf("expected an error putting the object '%s'", objectName)
+		}
+	}
+	if err := f.PutObject("missing", "object", bytes.NewReader(nil), 0, PutOptions{}, ctx); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("error is %v putting an object in a missing bucket, want %v", err, ErrBucketNotFound)
+	}
+	if _, err := f.StatObject("archive", "other", ctx); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v for a missing object, want %v", err, ErrObjectNotFound)
+	}
+
+	if err := f.DeleteObject("archive", "sensors/2023/object", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.GetObject("archive", "sensors/2023/object", ctx); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v getting a deleted object, want %v", err, ErrObjectNotFound)
+	}
+	if err := f.DeleteObject("archive", "sensors/2023/object", ctx); err != nil {
+		t.Errorf("error is %v deleting a missing object, want none", err)
+	}
+}
+
+func TestFilesystemBackendSweep(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []LifecycleRule
+		tags       map[string]string
+		age        time.Duration
+		wantExpiry bool
+	}{
+		{name: "no rules", age: 48 * time.Hour, wantExpiry: false},
+		{name: "bucket rule", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, age: 48 * time.Hour, wantExpiry: true},
+		{name: "recent object", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, age: time.Hour, wantExpiry: false},
+		{name: "filter rule matching the tags", rules: []LifecycleRule{NewFilterLifecycleRule("f1", 1)}, tags: map[string]string{ObjectTagFilterId: "f1"}, age: 48 * time.Hour, wantExpiry: true},
+		{name: "filter rule of another filter", rules: []LifecycleRule{NewFilterLifecycleRule("f1", 1)}, tags: map[string]string{ObjectTagFilterId: "f2"}, age: 48 * time.Hour, wantExpiry: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newTestFilesystemBackend(t)
+			if err := f.SetBucketLifecycle("archive", test.rules, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.PutObject("archive", "object", bytes.NewReader([]byte("data")), 4, PutOptions{Tags: test.tags}, ctx); err != nil {
+				t.Fatal(err)
+			}
+			// the object is aged instead of waiting for it
+			modified := time.Now().Add(-test.age)
+			if err := os.Chtimes(filepath.Join(f.path, "archive", "object"), modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			f.sweep(ctx)
+			_, err := f.StatObject("archive", "object", ctx)
+			if expired := errors.Is(err, ErrObjectNotFound); expired != test.wantExpiry {
+				t.Errorf("object expired: %t (%v), want %t", expired, err, test.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestFilesystemBackendLegacyExpiration(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFilesystemBackend(t)
+	// the configurations written before the lifecycle rules only hold the expiration days
+	if err := os.WriteFile(f.bucketConfigPath("archive"), []byte(`{"expirationDays":7}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := f.GetBucketLifecycle("archive", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Id != BucketLifecycleRuleId || rules[0].Days != 7 {
+		t.Errorf("rules are %v, want a bucket rule of 7 days", rules)
+	}
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/iotaledger/hive.go/serializer/v2"
+	iotago "github.com/iotaledger/iota.go/v3"
+	"github.com/iotaledger/iota.go/v3/merklehasher"
+)
+
+const (
+	// FormatJSON stores the objects as the JSON produced by Object.GetByteReader.
+	FormatJSON = "json"
+	// FormatBinary stores the block and the milestone in the IOTA binary serialization.
+	FormatBinary = "binary"
+
+	// binaryFormatVersion is the first byte of the objects stored in the binary format
+	binaryFormatVersion byte = 1
+)
+
+func validateFormat(format string) error {
+	switch format {
+	case "", FormatJSON, FormatBinary:
+		return nil
+	default:
+		return fmt.Errorf("unknown format '%s', supported are '%s' and '%s'", format, FormatJSON, FormatBinary)
+	}
+}
+
+// encodeObject serializes the object in the given format.
+func encodeObject(object Object, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(object)
+	case FormatBinary:
+		return object.MarshalBinary()
+	default:
+		return nil, validateFormat(format)
+	}
+}
+
+// DecodeObject reads an object serialized in the given format, as returned by Storage.GetObject.
+func DecodeObject(reader io.Reader, format string) (Object, error) {
+	switch format {
+	case "", FormatJSON:
+		return NewObject(reader)
+	case FormatBinary:
+		var object Object
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return object, err
+		}
+		err = object.UnmarshalBinary(data)
+		return object, err
+	default:
+		return Object{}, validateFormat(format)
+	}
+}
+
+// MarshalBinary serializes the object as a version byte followed by the length prefixed block, milestone and proof.
+// The block and the milestone use the IOTA binary serialization, so the block id can be recomputed from the stored bytes,
+// the proof has no binary serialization and is kept as JSON. A missing milestone or proof has length 0.
+func (o *Object) MarshalBinary() ([]byte, error) {
+	if o.Block == nil {
+		return nil, fmt.Errorf("object has no block")
+	}
+	blockBytes, err := o.Block.Serialize(serializer.DeSeriModeNoValidation, nil)
+	if err != nil {
+		return nil, err
+	}
+	var milestoneBytes []byte
+	if o.Milestone != nil {
+		milestoneBytes, err = o.Milestone.Serialize(serializer.DeSeriModeNoValidation, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var proofBytes []byte
+	if o.Proof != nil {
+		proofBytes, err = json.Marshal(o.Proof)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte(binaryFormatVersion)
+	for _, section := range [][]byte{blockBytes, milestoneBytes, proofBytes} {
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(section)))
+		buffer.Write(length[:])
+		buffer.Write(section)
+	}
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalBinary reads an object serialized by MarshalBinary.
+func (o *Object) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryFormatVersion {
+		return fmt.Errorf("unknown binary object version")
+	}
+	data = data[1:]
+
+	sections := make([][]byte, 3)
+	for i := range sections {
+		if len(data) < 4 {
+			return fmt.Errorf("truncated binary object")
+		}
+		length := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < length {
+			return fmt.Errorf("truncated binary object")
+		}
+		sections[i] = data[:length]
+		data = data[length:]
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("%d trailing bytes in binary object", len(data))
+	}
+
+	block := new(iotago.Block)
+	if _, err := block.Deserialize(sections[0], serializer.DeSeriModeNoValidation, nil); err != nil {
+		return fmt.Errorf("can't deserialize block, error: %w", err)
+	}
+	var milestone *iotago.Milestone
+	if len(sections[1]) > 0 {
+		milestone = new(iotago.Milestone)
+		if _, err := milestone.Deserialize(sections[1], serializer.DeSeriModeNoValidation, nil); err != nil {
+			return fmt.Errorf("can't deserialize milestone, error: %w", err)
+		}
+	}
+	var proof *merklehasher.Proof
+	if len(sections[2]) > 0 {
+		proof = new(merklehasher.Proof)
+		if err := json.Unmarshal(sections[2], proof); err != nil {
+			return fmt.Errorf("can't deserialize proof, error: %w", err)
+		}
+	}
+
+	o.Block, o.Milestone, o.Proof = block, milestone, proof
+	return nil
+}
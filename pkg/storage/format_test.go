@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/iotaledger/hive.go/serializer/v2"
+	iotago "github.com/iotaledger/iota.go/v3"
+	"github.com/iotaledger/iota.go/v3/merklehasher"
+)
+
+func TestBinaryFormatRoundTrip(t *testing.T) {
+	object := withTestProof(t, newTestObject(t, "sensors", "temperature=21"))
+	blockId := object.Block.MustID()
+
+	tests := []struct {
+		name   string
+		object Object
+	}{
+		{name: "block", object: Object{Block: object.Block}},
+		{name: "block and milestone", object: Object{Block: object.Block, Milestone: object.Milestone}},
+		{name: "proof of inclusion", object: object},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, format := range []string{FormatJSON, FormatBinary} {
+				data, err := encodeObject(test.object, format)
+				if err != nil {
+					t.Fatalf("%s: can't encode object, error: %s", format, err)
+				}
+				decoded, err := DecodeObject(bytes.NewReader(data), format)
+				if err != nil {
+					t.Fatalf("%s: can't decode object, error: %s", format, err)
+				}
+
+				decodedId, err := decoded.Block.ID()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decodedId != blockId {
+					t.Errorf("%s: block id is %x, want %x", format, decodedId, blockId)
+				}
+				if !bytes.Equal(serialize(t, decoded.Milestone), serialize(t, test.object.Milestone)) {
+					t.Errorf("%s: milestone differs after the round trip", format)
+				}
+				if !bytes.Equal(marshal(t, decoded.Proof), marshal(t, test.object.Proof)) {
+					t.Errorf("%s: proof differs after the round trip", format)
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryFormatInvalid(t *testing.T) {
+	object := newTestObject(t, "sensors", "temperature=21")
+	data, err := object.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "unknown version", data: append([]byte{binaryFormatVersion + 1}, data[1:]...)},
+		{name: "truncated length", data: data[:3]},
+		{name: "truncated section", data: data[:len(data)-1]},
+		{name: "trailing bytes", data: append(append([]byte{}, data...), 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var decoded Object
+			if err := decoded.UnmarshalBinary(test.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := (&Object{}).MarshalBinary(); err == nil {
+		t.Error("expected an error for an object without block")
+	}
+	if _, err := encodeObject(object, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestStoredObjectFormat(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "default", format: "", want: FormatJSON},
+		{name: "json", format: FormatJSON, want: FormatJSON},
+		{name: "binary", format: FormatBinary, want: FormatBinary},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestStorage(t, Parameters{})
+			if err := s.SetBucketConfig("default", BucketConfig{Format: test.format}, ctx); err != nil {
+				t.Fatal(err)
+			}
+			object := newTestObject(t, "sensors", "temperature=21")
+			attributes := newTestAttributes(t, object, 42)
+			if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, format, err := s.GetObject("default", attributes.BlockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if format != test.want {
+				t.Errorf("format is '%s', want '%s'", format, test.want)
+			}
+			decoded, err := DecodeObject(reader, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Block.MustID() != object.Block.MustID() {
+				t.Error("the stored block differs from the uploaded one")
+			}
+		})
+	}
+}
+
+func serialize(t *testing.T, milestone *iotago.Milestone) []byte {
+	t.Helper()
+	if milestone == nil {
+		return nil
+	}
+	data, err := milestone.Serialize(serializer.DeSeriModeNoValidation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func marshal(t *testing.T, proof *merklehasher.Proof) []byte {
+	t.Helper()
+	if proof == nil {
+		return nil
+	}
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+const (
+	// DefaultKeyLayout stores the objects at the root of the bucket, named after their block id.
+	DefaultKeyLayout = "{blockId}"
+
+	// indexPrefix prefixes the objects pointing from a block id to the key of its object,
+	// they are written only for the objects not stored under the default key layout.
+	indexPrefix = ".index/"
+
+	untaggedPlaceholder = "untagged"
+)
+
+var keyLayoutPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ObjectAttributes describes the block stored in an object, they are used to build the key of the object.
+type ObjectAttributes struct {
+	BlockId            string
+	Tag                []byte
+	FilterId           string
+	MilestoneIndex     uint32
+	MilestoneTimestamp uint32
+	// KeyLayout overrides the key layout of the bucket when not empty
+	KeyLayout string
+}
+
+// ValidateKeyLayout checks that the layout only uses known placeholders and that it contains the block id,
+// so that no two blocks can share the same key.
+func ValidateKeyLayout(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	if !strings.Contains(layout, "{blockId}") {
+		return fmt.Errorf("key layout '%s' must contain '{blockId}'", layout)
+	}
+	if strings.HasPrefix(layout, "/") || strings.HasPrefix(layout, indexPrefix) {
+		return fmt.Errorf("key layout '%s' can't start with '/' or '%s'", layout, indexPrefix)
+	}
+	for _, placeholder := range keyLayoutPlaceholder.FindAllString(layout, -1) {
+		if _, ok := keyLayoutValues[placeholder]; !ok {
+			return fmt.Errorf("unknown placeholder '%s' in key layout '%s'", placeholder, layout)
+		}
+	}
+	for _, element := range strings.Split(layout, "/") {
+		if element == "" || element == "." || element == ".." {
+			return fmt.Errorf("invalid path element '%s' in key layout '%s'", element, layout)
+		}
+	}
+	return nil
+}
+
+var keyLayoutValues = map[string]func(attributes ObjectAttributes) string{
+	"{blockId}": func(a ObjectAttributes) string { return a.BlockId },
+	"{tag}": func(a ObjectAttributes) string {
+		if len(a.Tag) == 0 {
+			return untaggedPlaceholder
+		}
+		return hex.EncodeToString(a.Tag)
+	},
+	"{filterId}": func(a ObjectAttributes) string {
+		if a.FilterId == "" {
+			return "api"
+		}
+		return a.FilterId
+	},
+	"{milestoneIndex}": func(a ObjectAttributes) string { return strconv.FormatUint(uint64(a.MilestoneIndex), 10) },
+	"{yyyy}":           func(a ObjectAttributes) string { return a.time().Format("2006") },
+	"{mm}":             func(a ObjectAttributes) string { return a.time().Format("01") },
+	"{dd}":             func(a ObjectAttributes) string { return a.time().Format("02") },
+}
+
+// time returns the time of the milestone referencing the block, or the current time if it is unknown.
+func (a ObjectAttributes) time() time.Time {
+	if a.MilestoneTimestamp == 0 {
+		return time.Now().UTC()
+	}
+	return time.Unix(int64(a.MilestoneTimestamp), 0).UTC()
+}
+
+// renderKey builds the object name by replacing the placeholders of the layout with the attributes.
+func renderKey(layout string, attributes ObjectAttributes) string {
+	if layout == "" {
+		layout = DefaultKeyLayout
+	}
+	return keyLayoutPlaceholder.ReplaceAllStringFunc(layout, func(placeholder string) string {
+		return keyLayoutValues[placeholder](attributes)
+	})
+}
+
+// completeAttributes fills the attributes that can be recovered from the object itself.
+func completeAttributes(attributes ObjectAttributes, object Object) ObjectAttributes {
+	if attributes.Tag == nil && object.Block != nil {
+		if taggedData, ok := object.Block.Payload.(*iotago.TaggedData); ok {
+			attributes.Tag = taggedData.Tag
+		}
+	}
+	if object.Milestone != nil {
+		if attributes.MilestoneIndex == 0 {
+			attributes.MilestoneIndex = object.Milestone.Index
+		}
+		if attributes.MilestoneTimestamp == 0 {
+			attributes.MilestoneTimestamp = object.Milestone.Timestamp
+		}
+	}
+	return attributes
+}
+
+func indexKey(blockId string) string {
+	return indexPrefix + blockId
+}
+
+func isReservedKey(objectName string) bool {
+	return strings.HasPrefix(objectName, indexPrefix)
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateKeyLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		wantErr bool
+	}{
+		{name: "default layout", layout: ""},
+		{name: "every placeholder", layout: "{tag}/{filterId}/{yyyy}/{mm}/{dd}/{milestoneIndex}-{blockId}"},
+		{name: "missing block id", layout: "{tag}/{milestoneIndex}", wantErr: true},
+		{name: "unknown placeholder", layout: "{network}/{blockId}", wantErr: true},
+		{name: "absolute key", layout: "/{blockId}", wantErr: true},
+		{name: "index prefix", layout: indexPrefix + "{blockId}", wantErr: true},
+		{name: "empty path element", layout: "{tag}//{blockId}", wantErr: true},
+		{name: "parent path element", layout: "../{blockId}", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateKeyLayout(test.layout); (err != nil) != test.wantErr {
+				t.Errorf("error is %v, want an error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderKey(t *testing.T) {
+	attributes := ObjectAttributes{
+		BlockId:            "0xab",
+		Tag:                []byte("sensors"),
+		FilterId:           "f1",
+		MilestoneIndex:     42,
+		MilestoneTimestamp: 1680000000,
+	}
+	tests := []struct {
+		name       string
+		layout     string
+		attributes ObjectAttributes
+		want       string
+	}{
+		{name: "default layout", layout: "", attributes: attributes, want: "0xab"},
+		{name: "tag and date", layout: "{tag}/{yyyy}/{mm}/{dd}/{blockId}", attributes: attributes, want: "73656e736f7273/2023/03/28/0xab"},
+		{name: "filter and milestone", layout: "{filterId}/{milestoneIndex}-{blockId}", attributes: attributes, want: "f1/42-0xab"},
+		{name: "untagged block stored through the api", layout: "{tag}/{filterId}/{blockId}", attributes: ObjectAttributes{BlockId: "0xab"}, want: "untagged/api/0xab"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if key := renderKey(test.layout, test.attributes); key != test.want {
+				t.Errorf("key is '%s', want '%s'", key, test.want)
+			}
+		})
+	}
+}
+
+func TestUploadObjectKeyLayout(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	if err := s.SetBucketConfig("default", BucketConfig{KeyLayout: "{tag}/{milestoneIndex}/{blockId}"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+	wantName := "73656e736f7273/42/" + attributes.BlockId
+	if objectName, err := s.ResolveObjectName("default", attributes.BlockId, ctx); err != nil || objectName != wantName {
+		t.Errorf("object name is '%s' (%v), want '%s'", objectName, err, wantName)
+	}
+	// the index entry is not listed as an object of the bucket
+	objects, err := s.ListObjects("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != wantName {
+		t.Errorf("objects are %v, want only '%s'", objects, wantName)
+	}
+
+	// the block is found from its id through the index
+	reader, _, err := s.GetObject("default", attributes.BlockId, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeObject(reader, FormatJSON); err != nil {
+		t.Errorf("can't decode object, error: %s", err)
+	}
+
+	// back to the default layout, the block is moved to its id and its index entry is dropped
+	if err := s.SetBucketConfig("default", BucketConfig{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UploadObject(attributes, "default", withTestProof(t, object), ctx); err != nil {
+		t.Fatal(err)
+	}
+	objects, err = s.Backend().ListObjects("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != attributes.BlockId {
+		t.Errorf("objects are %v, want only '%s'", objects, attributes.BlockId)
+	}
+
+	if err := s.DeleteObject("default", attributes.BlockId, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.GetObject("default", attributes.BlockId, ctx); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v getting a deleted object, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestDeleteObjectKeyLayout(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	if err := s.SetBucketConfig("default", BucketConfig{KeyLayout: "{tag}/{blockId}"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteObject("default", attributes.BlockId, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the index entry is deleted together with the object
+	objects, err := s.Backend().ListObjects("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("objects are %v, want none", objects)
+	}
+}
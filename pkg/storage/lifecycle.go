@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// BucketLifecycleRuleId is the id of the rule expiring every object of the bucket.
+	BucketLifecycleRuleId = "expire-bucket"
+	// FilterLifecycleRulePrefix prefixes the id of the rules expiring the objects stored by a filter.
+	FilterLifecycleRulePrefix = "expire-filter-"
+
+	// maxLifecycleRules is the maximum number of rules of a bucket allowed by S3
+	maxLifecycleRules = 1000
+)
+
+// LifecycleRule expires the objects of a bucket matching both its key prefix and its tags,
+// a rule without prefix and tags applies to every object. When several rules match an object, the shortest one applies.
+type LifecycleRule struct {
+	Id     string            `json:"id" validate:"required"`
+	Prefix string            `json:"prefix,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Days   int               `json:"days" validate:"min=1"`
+}
+
+// NewFilterLifecycleRule returns the rule expiring the objects stored by the filter after the given days.
+func NewFilterLifecycleRule(filterId string, days int) LifecycleRule {
+	return LifecycleRule{
+		Id:   FilterLifecycleRulePrefix + filterId,
+		Tags: map[string]string{ObjectTagFilterId: filterId},
+		Days: days,
+	}
+}
+
+func (r LifecycleRule) Validate() error {
+	if r.Id == "" || len(r.Id) > 255 {
+		return fmt.Errorf("invalid lifecycle rule id '%s'", r.Id)
+	}
+	if r.Days <= 0 {
+		return fmt.Errorf("invalid expiration days %d for lifecycle rule '%s'", r.Days, r.Id)
+	}
+	for key := range r.Tags {
+		if key == "" {
+			return fmt.Errorf("empty tag key in lifecycle rule '%s'", r.Id)
+		}
+	}
+	return nil
+}
+
+// ValidateLifecycleRules checks the rules and that their ids are unique.
+func ValidateLifecycleRules(rules []LifecycleRule) error {
+	if len(rules) > maxLifecycleRules {
+		return fmt.Errorf("too many lifecycle rules, got %d, maximum is %d", len(rules), maxLifecycleRules)
+	}
+	ids := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if _, ok := ids[rule.Id]; ok {
+			return fmt.Errorf("duplicated lifecycle rule id '%s'", rule.Id)
+		}
+		ids[rule.Id] = struct{}{}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the object.
+func (r LifecycleRule) matches(objectName string, tags map[string]string) bool {
+	if !strings.HasPrefix(objectName, r.Prefix) {
+		return false
+	}
+	for key, value := range r.Tags {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r LifecycleRule) equal(other LifecycleRule) bool {
+	if r.Id != other.Id || r.Prefix != other.Prefix || r.Days != other.Days || len(r.Tags) != len(other.Tags) {
+		return false
+	}
+	for key, value := range r.Tags {
+		if otherValue, ok := other.Tags[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// isObjectExpired reports whether the object outlived the shortest of the rules matching it,
+// it is used by the backends enforcing the lifecycle themselves.
+func isObjectExpired(rules []LifecycleRule, info ObjectInfo) bool {
+	days := 0
+	for _, rule := range rules {
+		if rule.matches(info.Key, info.Tags) && (days == 0 || rule.Days < days) {
+			days = rule.Days
+		}
+	}
+	// days = 0 means that the object has no expiration
+	if days == 0 {
+		return false
+	}
+	return time.Since(info.LastModified) > time.Duration(days)*24*time.Hour
+}
+
+func sortLifecycleRules(rules []LifecycleRule) {
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Id < rules[j].Id })
+}
+
+// GetBucketLifecycle returns the lifecycle rules of the bucket.
+func (s *Storage) GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error) {
+	rules, err := s.backend.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortLifecycleRules(rules)
+	return rules, nil
+}
+
+// SetBucketLifecycle replaces the lifecycle rules of the bucket.
+func (s *Storage) SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	if err := ValidateLifecycleRules(rules); err != nil {
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Setting %d lifecycle rules for bucket '%s' ...", len(rules), bucketName)
+	err := s.backend.SetBucketLifecycle(bucketName, rules, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Setting %d lifecycle rules for bucket '%s' ... failed, error: %w", len(rules), bucketName, err)
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Setting %d lifecycle rules for bucket '%s' ... done", len(rules), bucketName)
+	return nil
+}
+
+// SetBucketUserLifecycle replaces the lifecycle rules of the bucket set by its users, keeping the rules of the filters storing in it:
+// they are owned by the filters, so the rules with their reserved ids are only accepted unchanged, as returned by GetBucketLifecycle.
+func (s *Storage) SetBucketUserLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+
+	actual, err := s.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	filterRules := make(map[string]LifecycleRule)
+	for _, rule := range actual {
+		if strings.HasPrefix(rule.Id, FilterLifecycleRulePrefix) {
+			filterRules[rule.Id] = rule
+		}
+	}
+
+	merged := make([]LifecycleRule, 0, len(rules)+len(filterRules))
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Id, FilterLifecycleRulePrefix) {
+			merged = append(merged, rule)
+			continue
+		}
+		if filterRule, ok := filterRules[rule.Id]; !ok || !rule.equal(filterRule) {
+			return fmt.Errorf("lifecycle rule id '%s' is reserved to the filters, the rules of the filters can't be changed", rule.Id)
+		}
+	}
+	for _, rule := range actual {
+		if _, ok := filterRules[rule.Id]; ok {
+			merged = append(merged, rule)
+		}
+	}
+	return s.SetBucketLifecycle(bucketName, merged, ctx)
+}
+
+// ReconcileBucketLifecycle makes the rules of the bucket with the ids of the desired rules match them,
+// a desired rule with 0 days is removed. The other rules of the bucket are left untouched,
+// and the lifecycle is only written if it differs. It reports whether the lifecycle changed.
+func (s *Storage) ReconcileBucketLifecycle(bucketName string, desired []LifecycleRule, ctx context.Context) (bool, error) {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+
+	actual, err := s.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	reconciled := make([]LifecycleRule, 0, len(actual)+len(desired))
+	desiredById := make(map[string]LifecycleRule, len(desired))
+	for _, rule := range desired {
+		desiredById[rule.Id] = rule
+	}
+
+	changed := false
+	for _, rule := range actual {
+		wanted, ok := desiredById[rule.Id]
+		if !ok {
+			reconciled = append(reconciled, rule)
+			continue
+		}
+		delete(desiredById, rule.Id)
+		if wanted.Days == 0 {
+			s.WrappedLogger.LogInfof("Removing lifecycle rule '%s' of bucket '%s'", rule.Id, bucketName)
+			changed = true
+			continue
+		}
+		if !rule.equal(wanted) {
+			s.WrappedLogger.LogInfof("Lifecycle rule '%s' of bucket '%s' expires objects after %d days, updating it to %d days", rule.Id, bucketName, rule.Days, wanted.Days)
+			changed = true
+		}
+		reconciled = append(reconciled, wanted)
+	}
+	for _, rule := range desired {
+		if _, missing := desiredById[rule.Id]; missing && rule.Days != 0 {
+			reconciled = append(reconciled, rule)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	return true, s.SetBucketLifecycle(bucketName, reconciled, ctx)
+}
+
+// SetBucketExpirationDays sets the number of days after which every object of the bucket expires,
+// days = 0 means that the bucket has no expiration. The other lifecycle rules of the bucket are kept.
+func (s *Storage) SetBucketExpirationDays(bucketName string, days int, ctx context.Context) error {
+	if days < 0 {
+		return fmt.Errorf("invalid expiration days %d", days)
+	}
+	_, err := s.ReconcileBucketLifecycle(bucketName, []LifecycleRule{{Id: BucketLifecycleRuleId, Days: days}}, ctx)
+	return err
+}
+
+// GetBucketExpirationDays returns the number of days after which every object of the bucket expires, 0 means no expiration.
+func (s *Storage) GetBucketExpirationDays(bucketName string, ctx context.Context) (int, error) {
+	rules, err := s.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Failed retrieving lifecycle for bucket '%s', error: %w", bucketName, err)
+		return 0, err
+	}
+	for _, rule := range rules {
+		if rule.Id == BucketLifecycleRuleId {
+			return rule.Days, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsObjectExpired(t *testing.T) {
+	rules := []LifecycleRule{
+		{Id: "sensors", Prefix: "sensors/", Days: 7},
+		{Id: "alerts", Tags: map[string]string{"tag": "alerts", "level": "high"}, Days: 1},
+		{Id: "scoped", Prefix: "archive/", Tags: map[string]string{"tag": "alerts"}, Days: 30},
+	}
+	tests := []struct {
+		name       string
+		key        string
+		tags       map[string]string
+		age        time.Duration
+		wantExpiry bool
+	}{
+		{name: "prefix matching", key: "sensors/a", age: 8 * 24 * time.Hour, wantExpiry: true},
+		{name: "prefix matching recent object", key: "sensors/a", age: 6 * 24 * time.Hour, wantExpiry: false},
+		{name: "no rule matching", key: "other/a", age: 365 * 24 * time.Hour, wantExpiry: false},
+		{name: "every tag matching", key: "a", tags: map[string]string{"tag": "alerts", "level": "high"}, age: 2 * 24 * time.Hour, wantExpiry: true},
+		{name: "only some tags matching", key: "a", tags: map[string]string{"tag": "alerts"}, age: 2 * 24 * time.Hour, wantExpiry: false},
+		{name: "prefix and tags matching", key: "archive/a", tags: map[string]string{"tag": "alerts"}, age: 31 * 24 * time.Hour, wantExpiry: true},
+		{name: "prefix without the tags", key: "archive/a", age: 31 * 24 * time.Hour, wantExpiry: false},
+		{name: "shortest matching rule applies", key: "archive/a", tags: map[string]string{"tag": "alerts", "level": "high"}, age: 2 * 24 * time.Hour, wantExpiry: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := ObjectInfo{Key: test.key, Tags: test.tags, LastModified: time.Now().Add(-test.age)}
+			if expired := isObjectExpired(rules, info); expired != test.wantExpiry {
+				t.Errorf("object expired: %t, want %t", expired, test.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestValidateLifecycleRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []LifecycleRule
+		wantErr bool
+	}{
+		{name: "no rules"},
+		{name: "scoped rules", rules: []LifecycleRule{{Id: "a", Prefix: "sensors/", Days: 7}, {Id: "b", Tags: map[string]string{"tag": "alerts"}, Days: 1}}},
+		{name: "missing id", rules: []LifecycleRule{{Days: 7}}, wantErr: true},
+		{name: "no days", rules: []LifecycleRule{{Id: "a"}}, wantErr: true},
+		{name: "empty tag key", rules: []LifecycleRule{{Id: "a", Tags: map[string]string{"": "alerts"}, Days: 1}}, wantErr: true},
+		{name: "duplicated id", rules: []LifecycleRule{{Id: "a", Days: 1}, {Id: "a", Prefix: "sensors/", Days: 7}}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateLifecycleRules(test.rules); (err != nil) != test.wantErr {
+				t.Errorf("error is %v, want an error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileBucketLifecycle(t *testing.T) {
+	s := newTestStorage(t, Parameters{})
+	ctx := context.Background()
+	userRule := LifecycleRule{Id: "short-lived", Prefix: "sensors/", Days: 7}
+	if err := s.SetBucketLifecycle("default", []LifecycleRule{userRule}, ctx); err != nil {
+		t.Fatalf("can't set lifecycle, error: %s", err)
+	}
+
+	steps := []struct {
+		name        string
+		desired     []LifecycleRule
+		wantChanged bool
+		want        []LifecycleRule
+	}{
+		{name: "rule added", desired: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 30}}, wantChanged: true, want: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 30}, userRule}},
+		{name: "rule unchanged", desired: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 30}}, wantChanged: false, want: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 30}, userRule}},
+		{name: "rule updated", desired: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 10}}, wantChanged: true, want: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 10}, userRule}},
+		{name: "rule removed", desired: []LifecycleRule{{Id: BucketLifecycleRuleId}}, wantChanged: true, want: []LifecycleRule{userRule}},
+		{name: "missing rule removed", desired: []LifecycleRule{{Id: BucketLifecycleRuleId}}, wantChanged: false, want: []LifecycleRule{userRule}},
+	}
+	for _, step := range steps {
+		changed, err := s.ReconcileBucketLifecycle("default", step.desired, ctx)
+		if err != nil {
+			t.Fatalf("%s: can't reconcile lifecycle, error: %s", step.name, err)
+		}
+		if changed != step.wantChanged {
+			t.Errorf("%s: changed is %t, want %t", step.name, changed, step.wantChanged)
+		}
+		rules, err := s.GetBucketLifecycle("default", ctx)
+		if err != nil {
+			t.Fatalf("%s: can't get lifecycle, error: %s", step.name, err)
+		}
+		if !reflect.DeepEqual(rules, step.want) {
+			t.Errorf("%s: expected rules %v, got %v", step.name, step.want, rules)
+		}
+	}
+}
+
+func TestSetBucketUserLifecycle(t *testing.T) {
+	s := newTestStorage(t, Parameters{})
+	ctx := context.Background()
+
+	filterRule := NewFilterLifecycleRule("f1", 30)
+	if _, err := s.ReconcileBucketLifecycle("default", []LifecycleRule{filterRule}, ctx); err != nil {
+		t.Fatalf("can't add filter rule, error: %s", err)
+	}
+
+	// the filter rule is kept when the users replace the rules
+	userRule := LifecycleRule{Id: "short-lived", Prefix: "sensors/", Days: 7}
+	if err := s.SetBucketUserLifecycle("default", []LifecycleRule{userRule}, ctx); err != nil {
+		t.Fatalf("can't set lifecycle, error: %s", err)
+	}
+	rules, err := s.GetBucketLifecycle("default", ctx)
+	if err != nil {
+		t.Fatalf("can't get lifecycle, error: %s", err)
+	}
+	if expected := []LifecycleRule{filterRule, userRule}; !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("expected rules %v, got %v", expected, rules)
+	}
+
+	// the rules read back can be written again unchanged
+	if err := s.SetBucketUserLifecycle("default", rules[:1], ctx); err != nil {
+		t.Fatalf("can't set lifecycle, error: %s", err)
+	}
+	rules, err = s.GetBucketLifecycle("default", ctx)
+	if err != nil {
+		t.Fatalf("can't get lifecycle, error: %s", err)
+	}
+	if expected := []LifecycleRule{filterRule}; !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("expected rules %v, got %v", expected, rules)
+	}
+
+	// but the rules of the filters can't be changed or added
+	for _, rule := range []LifecycleRule{NewFilterLifecycleRule("f1", 1), NewFilterLifecycleRule("f2", 30)} {
+		if err := s.SetBucketUserLifecycle("default", []LifecycleRule{rule}, ctx); err == nil {
+			t.Fatalf("expected rule '%s' with %d days to be refused", rule.Id, rule.Days)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// RetentionGovernance protects the objects from users without the permission to bypass the retention.
+	RetentionGovernance = "governance"
+	// RetentionCompliance protects the objects from every user, the root account included.
+	RetentionCompliance = "compliance"
+)
+
+// ErrObjectLocked is returned when deleting or overwriting an object under legal hold or retention.
+var ErrObjectLocked = errors.New("object is locked")
+
+// ErrObjectLockNotSupported is returned when the backend can't lock objects.
+var ErrObjectLockNotSupported = errors.New("object lock is not supported by the storage backend")
+
+// Retention is the default retention applied to the new objects of a bucket with object lock enabled.
+type Retention struct {
+	// Mode is either governance or compliance, empty means no default retention
+	Mode string `json:"mode,omitempty"`
+	Days int    `json:"days,omitempty"`
+}
+
+func (r Retention) Validate() error {
+	switch r.Mode {
+	case "":
+		if r.Days != 0 {
+			return fmt.Errorf("retention days need a retention mode")
+		}
+		return nil
+	case RetentionGovernance, RetentionCompliance:
+		if r.Days <= 0 {
+			return fmt.Errorf("invalid retention days %d", r.Days)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown retention mode '%s', supported are '%s' and '%s'", r.Mode, RetentionGovernance, RetentionCompliance)
+	}
+}
+
+// ObjectLock describes the protection of an object against deletion and overwrite.
+type ObjectLock struct {
+	LegalHold     bool       `json:"legalHold"`
+	RetentionMode string     `json:"retentionMode,omitempty"`
+	RetainUntil   *time.Time `json:"retainUntil,omitempty"`
+}
+
+// IsLocked reports whether the object can't be deleted or overwritten.
+func (l ObjectLock) IsLocked() bool {
+	return l.LegalHold || (l.RetentionMode != "" && l.RetainUntil != nil && time.Now().Before(*l.RetainUntil))
+}
+
+// objectLocker is implemented by the backends supporting write-once-read-many buckets.
+type objectLocker interface {
+	// CreateLockedBucket creates a new bucket with object lock enabled, which can't be disabled afterwards.
+	CreateLockedBucket(bucketName string, ctx context.Context) error
+	// SetBucketRetention sets the default retention of the new objects of a bucket with object lock enabled.
+	SetBucketRetention(bucketName string, retention Retention, ctx context.Context) error
+	// GetBucketRetention returns the default retention of the bucket, and whether object lock is enabled.
+	GetBucketRetention(bucketName string, ctx context.Context) (Retention, bool, error)
+	// SetObjectLegalHold places or removes a legal hold on the object.
+	SetObjectLegalHold(bucketName string, objectName string, hold bool, ctx context.Context) error
+}
+
+func (s *Storage) objectLocker() (objectLocker, error) {
+	locker, ok := s.backend.(objectLocker)
+	if !ok {
+		return nil, ErrObjectLockNotSupported
+	}
+	return locker, nil
+}
+
+// CreateLockedBucket creates a bucket with object lock enabled and the given default retention.
+func (s *Storage) CreateLockedBucket(bucketName string, retention Retention, ctx context.Context) error {
+	if err := retention.Validate(); err != nil {
+		return err
+	}
+	locker, err := s.objectLocker()
+	if err != nil {
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Creating bucket '%s' with object lock ...", bucketName)
+	err = locker.CreateLockedBucket(bucketName, ctx)
+	if err == nil && retention.Mode != "" {
+		err = locker.SetBucketRetention(bucketName, retention, ctx)
+	}
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Creating bucket '%s' with object lock ... failed, error: %w", bucketName, err)
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Creating bucket '%s' with object lock ... done", bucketName)
+	return nil
+}
+
+// GetBucketRetention returns the default retention of the bucket, and whether object lock is enabled.
+func (s *Storage) GetBucketRetention(bucketName string, ctx context.Context) (Retention, bool, error) {
+	locker, err := s.objectLocker()
+	if err != nil {
+		return Retention{}, false, nil
+	}
+	return locker.GetBucketRetention(bucketName, ctx)
+}
+
+// SetLegalHold places or removes a legal hold on the object holding the block.
+func (s *Storage) SetLegalHold(bucketName string, blockId string, hold bool, ctx context.Context) error {
+	locker, err := s.objectLocker()
+	if err != nil {
+		return err
+	}
+	bucketName, objectName, err := s.locateObject(bucketName, blockId, ctx)
+	if err != nil {
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Setting legal hold %t on object '%s' of bucket '%s' ...", hold, objectName, bucketName)
+	err = locker.SetObjectLegalHold(bucketName, objectName, hold, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Setting legal hold %t on object '%s' of bucket '%s' ... failed, error: %w", hold, objectName, bucketName, err)
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Setting legal hold %t on object '%s' of bucket '%s' ... done", hold, objectName, bucketName)
+	return nil
+}
+
+// GetObjectLock returns the protection of the object holding the block.
+func (s *Storage) GetObjectLock(bucketName string, blockId string, ctx context.Context) (ObjectLock, error) {
+	bucketName, objectName, err := s.locateObject(bucketName, blockId, ctx)
+	if err != nil {
+		return ObjectLock{}, err
+	}
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
+	if err != nil {
+		return ObjectLock{}, err
+	}
+	return info.Lock, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+func TestRetentionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention Retention
+		wantErr   bool
+	}{
+		{name: "no retention", retention: Retention{}},
+		{name: "governance", retention: Retention{Mode: RetentionGovernance, Days: 30}},
+		{name: "compliance", retention: Retention{Mode: RetentionCompliance, Days: 365}},
+		{name: "days without mode", retention: Retention{Days: 30}, wantErr: true},
+		{name: "mode without days", retention: Retention{Mode: RetentionGovernance}, wantErr: true},
+		{name: "unknown mode", retention: Retention{Mode: "forever", Days: 30}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.retention.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("error is %v, want an error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestObjectLockIsLocked(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		lock       ObjectLock
+		wantLocked bool
+	}{
+		{name: "no lock", lock: ObjectLock{}},
+		{name: "legal hold", lock: ObjectLock{LegalHold: true}, wantLocked: true},
+		{name: "running retention", lock: ObjectLock{RetentionMode: RetentionCompliance, RetainUntil: &future}, wantLocked: true},
+		{name: "ended retention", lock: ObjectLock{RetentionMode: RetentionCompliance, RetainUntil: &past}},
+		{name: "legal hold after the retention", lock: ObjectLock{LegalHold: true, RetentionMode: RetentionGovernance, RetainUntil: &past}, wantLocked: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if locked := test.lock.IsLocked(); locked != test.wantLocked {
+				t.Errorf("locked is %t, want %t", locked, test.wantLocked)
+			}
+		})
+	}
+}
+
+func TestDeleteObjectLocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention Retention
+		legalHold bool
+	}{
+		{name: "default retention", retention: Retention{Mode: RetentionGovernance, Days: 1}},
+		{name: "legal hold", legalHold: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorage(t, Parameters{})
+			if err := s.CreateLockedBucket("worm", test.retention, ctx); err != nil {
+				t.Fatal(err)
+			}
+			object := newTestObject(t, "sensors", "temperature=21")
+			attributes := newTestAttributes(t, object, 42)
+			if err := s.UploadObject(attributes, "worm", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if test.legalHold {
+				if err := s.SetLegalHold("worm", attributes.BlockId, true, ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lock, err := s.GetObjectLock("worm", attributes.BlockId, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lock.LegalHold != test.legalHold || lock.RetentionMode != test.retention.Mode {
+				t.Errorf("lock is %+v, want legal hold %t and retention '%s'", lock, test.legalHold, test.retention.Mode)
+			}
+			if err := s.DeleteObject("worm", attributes.BlockId, ctx); !errors.Is(err, ErrObjectLocked) {
+				t.Fatalf("error is %v deleting a locked object, want %v", err, ErrObjectLocked)
+			}
+			if _, _, err := s.GetObject("worm", attributes.BlockId, ctx); err != nil {
+				t.Errorf("locked object is gone, error: %s", err)
+			}
+
+			// the object can be deleted once the legal hold is removed
+			if test.legalHold {
+				if err := s.SetLegalHold("worm", attributes.BlockId, false, ctx); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.DeleteObject("worm", attributes.BlockId, ctx); err != nil {
+					t.Errorf("error is %v deleting a released object", err)
+				}
+			}
+		})
+	}
+}
+
+func TestObjectLockNotSupported(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFilesystemBackend(t.TempDir(), 0, logger.NewWrappedLogger(logger.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStorageWithBackend(t, backend, Parameters{})
+
+	if err := s.CreateLockedBucket("worm", Retention{}, ctx); !errors.Is(err, ErrObjectLockNotSupported) {
+		t.Errorf("error is %v creating a locked bucket, want %v", err, ErrObjectLockNotSupported)
+	}
+	if _, enabled, err := s.GetBucketRetention("default", ctx); err != nil || enabled {
+		t.Errorf("object lock is enabled: %t (%v), want false", enabled, err)
+	}
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLegalHold("default", attributes.BlockId, true, ctx); !errors.Is(err, ErrObjectLockNotSupported) {
+		t.Errorf("error is %v placing a legal hold, want %v", err, ErrObjectLockNotSupported)
+	}
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BackendMemory selects a backend keeping every object in memory, its content is lost on shutdown.
+	BackendMemory = "memory"
+)
+
+// MemoryBackend keeps the buckets and their objects in memory.
+// It is meant for ephemeral runs and for tests, since nothing survives a restart.
+type MemoryBackend struct {
+	mutex   sync.RWMutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	creationDate time.Time
+	lifecycle    []LifecycleRule
+	tags         map[string]string
+	objects      map[string]memoryObject
+	objectLock   bool
+	retention    Retention
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{buckets: make(map[string]*memoryBucket)}
+}
+
+func (m *MemoryBackend) CreateBucket(bucketName string, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.buckets[bucketName]; exists {
+		return fmt.Errorf("bucket '%s' already exists", bucketName)
+	}
+	m.buckets[bucketName] = &memoryBucket{creationDate: time.Now(), objects: make(map[string]memoryObject)}
+	return nil
+}
+
+// CreateLockedBucket creates a bucket whose locked objects can be neither overwritten nor deleted,
+// since the memory backend keeps no previous versions of the objects.
+func (m *MemoryBackend) CreateLockedBucket(bucketName string, ctx context.Context) error {
+	if err := m.CreateBucket(bucketName, ctx); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.buckets[bucketName].objectLock = true
+	return nil
+}
+
+func (m *MemoryBackend) SetBucketRetention(bucketName string, retention Retention, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, err := m.lockedBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.retention = retention
+	return nil
+}
+
+func (m *MemoryBackend) GetBucketRetention(bucketName string, ctx context.Context) (Retention, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return Retention{}, false, ErrBucketNotFound
+	}
+	return bucket.retention, bucket.objectLock, nil
+}
+
+func (m *MemoryBackend) SetObjectLegalHold(bucketName string, objectName string, hold bool, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, err := m.lockedBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	object, exists := bucket.objects[objectName]
+	if !exists {
+		return ErrObjectNotFound
+	}
+	object.info.Lock.LegalHold = hold
+	bucket.objects[objectName] = object
+	return nil
+}
+
+func (m *MemoryBackend) lockedBucket(bucketName string) (*memoryBucket, error) {
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+	if !bucket.objectLock {
+		return nil, fmt.Errorf("object lock is not enabled on bucket '%s'", bucketName)
+	}
+	return bucket, nil
+}
+
+func (m *MemoryBackend) DeleteBucket(bucketName string, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return ErrBucketNotFound
+	}
+	bucket.purgeExpired()
+	if len(bucket.objects) != 0 {
+		return ErrBucketNotEmpty
+	}
+	delete(m.buckets, bucketName)
+	return nil
+}
+
+func (m *MemoryBackend) BucketExists(bucketName string, ctx context.Context) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, exists := m.buckets[bucketName]
+	return exists, nil
+}
+
+func (m *MemoryBackend) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	buckets := make([]BucketInfo, 0, len(m.buckets))
+	for bucketName, bucket := range m.buckets {
+		buckets = append(buckets, BucketInfo{Name: bucketName, CreationDate: bucket.creationDate})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, nil
+}
+
+func (m *MemoryBackend) SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return ErrBucketNotFound
+	}
+	bucket.lifecycle = copyRules(rules)
+	return nil
+}
+
+func (m *MemoryBackend) GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+	return copyRules(bucket.lifecycle), nil
+}
+
+func (m *MemoryBackend) SetBucketTags(bucketName string, tags map[string]string, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return ErrBucketNotFound
+	}
+	bucket.tags = copyMap(tags)
+	return nil
+}
+
+func (m *MemoryBackend) GetBucketTags(bucketName string, ctx context.Context) (map[string]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+	tags := copyMap(bucket.tags)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return tags, nil
+}
+
+func (m *MemoryBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return ErrBucketNotFound
+	}
+	bucket.purgeExpired()
+	if previous, exists := bucket.objects[objectName]; exists && previous.info.Lock.IsLocked() {
+		return ErrObjectLocked
+	}
+
+	now := time.Now()
+	object := memoryObject{
+		data: data,
+		info: ObjectInfo{
+			Key:          objectName,
+			Size:         int64(len(data)),
+			LastModified: now,
+			ContentType:  opts.ContentType,
+			Metadata:     copyMap(opts.Metadata),
+			Tags:         copyMap(opts.Tags),
+		},
+	}
+	if bucket.retention.Mode != "" {
+		object.info.Lock.RetentionMode = bucket.retention.Mode
+		retainUntil := now.AddDate(0, 0, bucket.retention.Days)
+		object.info.Lock.RetainUntil = &retainUntil
+	}
+	bucket.objects[objectName] = object
+	return nil
+}
+
+func (m *MemoryBackend) GetObject(bucketName string, objectName string, ctx context.Context) (io.ReadCloser, ObjectInfo, error) {
+	object, err := m.getObject(bucketName, objectName)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), object.info, nil
+}
+
+func (m *MemoryBackend) StatObject(bucketName string, objectName string, ctx context.Context) (ObjectInfo, error) {
+	object, err := m.getObject(bucketName, objectName)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return object.info, nil
+}
+
+func (m *MemoryBackend) DeleteObject(bucketName string, objectName string, ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return ErrBucketNotFound
+	}
+	if object, exists := bucket.objects[objectName]; exists && object.info.Lock.IsLocked() {
+		return ErrObjectLocked
+	}
+	delete(bucket.objects, objectName)
+	return nil
+}
+
+func (m *MemoryBackend) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	objects := make([]ObjectInfo, 0, len(bucket.objects))
+	for _, object := range bucket.objects {
+		if bucket.isExpired(object) {
+			continue
+		}
+		objects = append(objects, object.info)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *MemoryBackend) getObject(bucketName string, objectName string) (memoryObject, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bucket, exists := m.buckets[bucketName]
+	if !exists {
+		return memoryObject{}, ErrBucketNotFound
+	}
+	object, exists := bucket.objects[objectName]
+	if !exists || bucket.isExpired(object) {
+		return memoryObject{}, ErrObjectNotFound
+	}
+	return object, nil
+}
+
+// isExpired reports whether the object outlived the lifecycle rules of the bucket,
+// expired objects are hidden and get dropped on the next write to the bucket. Locked objects never expire.
+func (b *memoryBucket) isExpired(object memoryObject) bool {
+	return !object.info.Lock.IsLocked() && isObjectExpired(b.lifecycle, object.info)
+}
+
+func (b *memoryBucket) purgeExpired() {
+	for objectName, object := range b.objects {
+		if b.isExpired(object) {
+			delete(b.objects, objectName)
+		}
+	}
+}
+
+func copyRules(rules []LifecycleRule) []LifecycleRule {
+	copied := make([]LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		rule.Tags = copyMap(rule.Tags)
+		copied = append(copied, rule)
+	}
+	return copied
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendBuckets(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+
+	if err := m.CreateBucket("archive", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateBucket("archive", ctx); err == nil {
+		t.Error("expected an error creating the bucket twice")
+	}
+	if exists, err := m.BucketExists("archive", ctx); err != nil || !exists {
+		t.Errorf("bucket exists: %t (%v), want true", exists, err)
+	}
+	if exists, err := m.BucketExists("missing", ctx); err != nil || exists {
+		t.Errorf("missing bucket exists: %t (%v), want false", exists, err)
+	}
+	buckets, err := m.ListBuckets(ctx)
+	if err != nil || len(buckets) != 1 || buckets[0].Name != "archive" {
+		t.Errorf("buckets are %v (%v), want [archive]", buckets, err)
+	}
+
+	tags := map[string]string{"collector-format": FormatBinary}
+	if err := m.SetBucketTags("archive", tags, ctx); err != nil {
+		t.Fatal(err)
+	}
+	tags["collector-format"] = FormatJSON
+	if stored, err := m.GetBucketTags("archive", ctx); err != nil || stored["collector-format"] != FormatBinary {
+		t.Errorf("bucket tags are %v (%v), want them copied", stored, err)
+	}
+
+	if err := m.PutObject("archive", "object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteBucket("archive", ctx); !errors.Is(err, ErrBucketNotEmpty) {
+		t.Errorf("error is %v deleting a bucket with objects, want %v", err, ErrBucketNotEmpty)
+	}
+	if err := m.DeleteObject("archive", "object", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteBucket("archive", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteBucket("archive", ctx); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("error is %v deleting a missing bucket, want %v", err, ErrBucketNotFound)
+	}
+}
+
+func TestMemoryBackendObjects(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+	if err := m.CreateBucket("archive", ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := PutOptions{ContentType: "application/json", Metadata: map[string]string{"poi": "true"}, Tags: map[string]string{"tag": "sensors"}}
+	for _, objectName := range []string{"b", "a"} {
+		if err := m.PutObject("archive", objectName, bytes.NewReader([]byte(objectName+"-data")), 6, opts, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader, info, err := m.GetObject("archive", "a", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "a-data" || info.Size != 6 || info.ContentType != opts.ContentType {
+		t.Errorf("object is %q of %d bytes and type '%s'", data, info.Size, info.ContentType)
+	}
+	if info.Metadata["poi"] != "true" || info.Tags["tag"] != "sensors" {
+		t.Errorf("object metadata is %v and tags are %v", info.Metadata, info.Tags)
+	}
+
+	objects, err := m.ListObjects("archive", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "a" || objects[1].Key != "b" {
+		t.Errorf("objects are %v, want a and b sorted by key", objects)
+	}
+
+	tests := []struct {
+		name       string
+		bucketName string
+		objectName string
+		wantErr    error
+	}{
+		{name: "missing object", bucketName: "archive", objectName: "c", wantErr: ErrObjectNotFound},
+		{name: "missing bucket", bucketName: "missing", objectName: "a", wantErr: ErrBucketNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := m.GetObject(test.bucketName, test.objectName, ctx); !errors.Is(err, test.wantErr) {
+				t.Errorf("get returned %v, want %v", err, test.wantErr)
+			}
+			if _, err := m.StatObject(test.bucketName, test.objectName, ctx); !errors.Is(err, test.wantErr) {
+				t.Errorf("stat returned %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryBackendExpiration(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []LifecycleRule
+		tags       map[string]string
+		age        time.Duration
+		locked     bool
+		wantExpiry bool
+	}{
+		{name: "no rules", age: 48 * time.Hour, wantExpiry: false},
+		{name: "bucket rule", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, age: 48 * time.Hour, wantExpiry: true},
+		{name: "recent object", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, age: time.Hour, wantExpiry: false},
+		{name: "filter rule matching the tags", rules: []LifecycleRule{NewFilterLifecycleRule("f1", 1)}, tags: map[string]string{ObjectTagFilterId: "f1"}, age: 48 * time.Hour, wantExpiry: true},
+		{name: "filter rule of another filter", rules: []LifecycleRule{NewFilterLifecycleRule("f1", 1)}, tags: map[string]string{ObjectTagFilterId: "f2"}, age: 48 * time.Hour, wantExpiry: false},
+		{name: "shortest rule applies", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 30}, NewFilterLifecycleRule("f1", 1)}, tags: map[string]string{ObjectTagFilterId: "f1"}, age: 48 * time.Hour, wantExpiry: true},
+		{name: "locked object", rules: []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, age: 48 * time.Hour, locked: true, wantExpiry: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemoryBackend()
+			if err := m.CreateLockedBucket("archive", ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.SetBucketLifecycle("archive", test.rules, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.PutObject("archive", "object", bytes.NewReader([]byte("data")), 4, PutOptions{Tags: test.tags}, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if test.locked {
+				if err := m.SetObjectLegalHold("archive", "object", true, ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// the object is aged instead of waiting for it
+			object := m.buckets["archive"].objects["object"]
+			object.info.LastModified = time.Now().Add(-test.age)
+			m.buckets["archive"].objects["object"] = object
+
+			_, err := m.StatObject("archive", "object", ctx)
+			if expired := errors.Is(err, ErrObjectNotFound); expired != test.wantExpiry {
+				t.Errorf("object expired: %t (%v), want %t", expired, err, test.wantExpiry)
+			}
+			objects, err := m.ListObjects("archive", ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if listed := len(objects) == 1; listed == test.wantExpiry {
+				t.Errorf("object listed: %t, want %t", listed, !test.wantExpiry)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The user metadata stored along with the objects.
+const (
+	// metadataEncoding records the compression of the object content
+	metadataEncoding = "encoding"
+	// metadataEncryption records the encryption of the object content
+	metadataEncryption = "encryption"
+	// metadataContentSHA256 records the hash of the serialized object, before compression
+	metadataContentSHA256 = "content-sha256"
+	// metadataContentHMAC records the checksum of the serialized object of an encrypted bucket, before compression and encryption,
+	// keyed so that it doesn't reveal the content like a hash would
+	metadataContentHMAC = "content-hmac-sha256"
+	// metadataFormat records the serialization of the object, objects without it are JSON
+	metadataFormat = "format"
+	// metadataBlockId records the id of the block held by the object, to find its index entry from the object
+	metadataBlockId = "block-id"
+
+	metadataTag                = "tag"
+	metadataTagUtf8            = "tag-utf8"
+	metadataMilestoneIndex     = "milestone-index"
+	metadataMilestoneTimestamp = "milestone-timestamp"
+	metadataFilterId           = "filter-id"
+	metadataPOI                = "poi"
+	metadataCollectorId        = "collector-id"
+)
+
+// The tags of the objects, a subset of the metadata usable by the lifecycle rules and the inventory tools of the storage.
+const (
+	ObjectTagTag            = "tag"
+	ObjectTagFilterId       = "filter-id"
+	ObjectTagPOI            = "poi"
+	ObjectTagCollectorId    = "collector-id"
+	ObjectTagMilestoneIndex = "milestone-index"
+)
+
+// objectTagMetadata maps the object tags to the metadata holding their values.
+var objectTagMetadata = map[string]string{
+	ObjectTagTag:            metadataTag,
+	ObjectTagFilterId:       metadataFilterId,
+	ObjectTagPOI:            metadataPOI,
+	ObjectTagCollectorId:    metadataCollectorId,
+	ObjectTagMilestoneIndex: metadataMilestoneIndex,
+}
+
+// objectTags returns the tags of an object from its metadata.
+func objectTags(metadata map[string]string) map[string]string {
+	tags := make(map[string]string, len(objectTagMetadata))
+	for tag, key := range objectTagMetadata {
+		if value, ok := metadata[key]; ok {
+			tags[tag] = value
+		}
+	}
+	return tags
+}
+
+// objectMetadata returns the user metadata and the tags describing the stored block.
+func (s *Storage) objectMetadata(attributes ObjectAttributes, object Object) (map[string]string, map[string]string) {
+	withPOI := strconv.FormatBool(object.Proof != nil)
+	metadata := map[string]string{
+		metadataPOI:         withPOI,
+		metadataCollectorId: s.instanceId,
+		metadataBlockId:     attributes.BlockId,
+	}
+	tags := map[string]string{
+		ObjectTagPOI:         withPOI,
+		ObjectTagCollectorId: s.instanceId,
+	}
+
+	if len(attributes.Tag) > 0 {
+		tagHex := hex.EncodeToString(attributes.Tag)
+		metadata[metadataTag] = tagHex
+		// metadata values must be US-ASCII, the tag is escaped if it is not
+		metadata[metadataTagUtf8] = url.PathEscape(string(attributes.Tag))
+		tags[ObjectTagTag] = tagHex
+	}
+	if attributes.FilterId != "" {
+		metadata[metadataFilterId] = attributes.FilterId
+		tags[ObjectTagFilterId] = attributes.FilterId
+	}
+	if attributes.MilestoneIndex != 0 {
+		milestoneIndex := strconv.FormatUint(uint64(attributes.MilestoneIndex), 10)
+		metadata[metadataMilestoneIndex] = milestoneIndex
+		tags[ObjectTagMilestoneIndex] = milestoneIndex
+	}
+	if attributes.MilestoneTimestamp != 0 {
+		metadata[metadataMilestoneTimestamp] = time.Unix(int64(attributes.MilestoneTimestamp), 0).UTC().Format(time.RFC3339)
+	}
+
+	return metadata, tags
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrVolatileTarget is returned when the objects would be migrated to a backend losing them on shutdown.
+var ErrVolatileTarget = errors.New("the memory backend can't be a migration target, its objects are lost on shutdown")
+
+// NewSiblingStorage returns a storage keeping the objects in the backend described by params, to migrate objects into,
+// which stores them the same way as s: with the same object extension, instance id and master keys.
+func (s *Storage) NewSiblingStorage(params Parameters) (*Storage, error) {
+	if params.Type == BackendMemory {
+		return nil, ErrVolatileTarget
+	}
+	backend, err := newBackend(params, s.WrappedLogger)
+	if err != nil {
+		return nil, err
+	}
+	sibling := NewStorageWithBackend(backend, params, s.WrappedLogger)
+	sibling.objectExtension = s.objectExtension
+	sibling.instanceId = s.instanceId
+	sibling.masterKeys = s.masterKeys
+	return sibling, nil
+}
+
+// ReadObject returns the object stored under the given name, decoded whatever its format, compression and encryption,
+// together with the attributes of its block recorded in the metadata.
+func (s *Storage) ReadObject(bucketName string, objectName string, ctx context.Context) (Object, ObjectAttributes, error) {
+	reader, info, err := s.backend.GetObject(bucketName, objectName, ctx)
+	if err != nil {
+		return Object{}, ObjectAttributes{}, err
+	}
+	decoded, err := s.decodeObject(bucketName, reader, info, ctx)
+	if err != nil {
+		return Object{}, ObjectAttributes{}, err
+	}
+	defer decoded.Close()
+
+	object, err := DecodeObject(decoded, info.Metadata[metadataFormat])
+	if err != nil {
+		return Object{}, ObjectAttributes{}, err
+	}
+
+	attributes := attributesFromInfo(info)
+	// the block id is computed again, the objects stored before it was recorded only have it in their key or index entry
+	if object.Block != nil {
+		blockId, err := object.Block.ID()
+		if err != nil {
+			return Object{}, ObjectAttributes{}, err
+		}
+		attributes.BlockId = hex.EncodeToString(blockId[:])
+	}
+	if attributes.BlockId == "" {
+		return Object{}, ObjectAttributes{}, errors.New("the object holds no block")
+	}
+	return object, completeAttributes(attributes, object), nil
+}
+
+// RemoveObject deletes the object stored under the given name, together with its index entry if it points to it.
+// Removing a missing object is not an error, a locked object is not removed and ErrObjectLocked is returned.
+func (s *Storage) RemoveObject(bucketName string, objectName string, ctx context.Context) error {
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Lock.IsLocked() {
+		return ErrObjectLocked
+	}
+
+	deleted, err := s.evictObject(bucketName, objectName, nil, ctx)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.updateUsage(bucketName, -1, -info.Size)
+	}
+	return nil
+}
+
+// attributesFromInfo returns the attributes of the block recorded in the metadata of the object.
+func attributesFromInfo(info ObjectInfo) ObjectAttributes {
+	attributes := ObjectAttributes{
+		BlockId:  info.Metadata[metadataBlockId],
+		FilterId: info.Metadata[metadataFilterId],
+	}
+	if tag, err := hex.DecodeString(info.Metadata[metadataTag]); err == nil && len(tag) > 0 {
+		attributes.Tag = tag
+	}
+	if milestoneIndex, err := strconv.ParseUint(info.Metadata[metadataMilestoneIndex], 10, 32); err == nil {
+		attributes.MilestoneIndex = uint32(milestoneIndex)
+	}
+	if milestoneTimestamp, err := time.Parse(time.RFC3339, info.Metadata[metadataMilestoneTimestamp]); err == nil {
+		attributes.MilestoneTimestamp = uint32(milestoneTimestamp.Unix())
+	}
+	return attributes
+}
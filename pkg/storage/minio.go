@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// MinioBackend stores the objects in an S3-compliant object storage.
+type MinioBackend struct {
+	client *minio.Client
+	region string
+}
+
+func NewMinioBackend(endpoint string, params Parameters) (*MinioBackend, error) {
+
+	// Initialize minio client object.
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(params.AccessKeyID, params.SecretAccessKey, ""),
+		Secure: params.Secure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MinioBackend{client: client, region: params.Region}, nil
+}
+
+// Client returns the underlying MinIO client.
+func (m *MinioBackend) Client() *minio.Client {
+	return m.client
+}
+
+func (m *MinioBackend) CreateBucket(bucketName string, ctx context.Context) error {
+	return m.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: m.region})
+}
+
+func (m *MinioBackend) CreateLockedBucket(bucketName string, ctx context.Context) error {
+	return m.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: m.region, ObjectLocking: true})
+}
+
+func (m *MinioBackend) SetBucketRetention(bucketName string, retention Retention, ctx context.Context) error {
+	if retention.Mode == "" {
+		// clearing the default retention
+		return m.client.SetObjectLockConfig(ctx, bucketName, nil, nil, nil)
+	}
+	mode := minio.RetentionMode(strings.ToUpper(retention.Mode))
+	validity := uint(retention.Days)
+	unit := minio.Days
+	return m.client.SetObjectLockConfig(ctx, bucketName, &mode, &validity, &unit)
+}
+
+func (m *MinioBackend) GetBucketRetention(bucketName string, ctx context.Context) (Retention, bool, error) {
+	enabled, mode, validity, unit, err := m.client.GetObjectLockConfig(ctx, bucketName)
+	if err != nil {
+		// a bucket without object lock is not an error
+		if minio.ToErrorResponse(err).Code == "ObjectLockConfigurationNotFoundError" {
+			return Retention{}, false, nil
+		}
+		return Retention{}, false, minioError(err)
+	}
+	if enabled != "Enabled" {
+		return Retention{}, false, nil
+	}
+	if mode == nil || validity == nil || unit == nil {
+		return Retention{}, true, nil
+	}
+	days := int(*validity)
+	if *unit == minio.Years {
+		days *= 365
+	}
+	return Retention{Mode: strings.ToLower(string(*mode)), Days: days}, true, nil
+}
+
+func (m *MinioBackend) SetObjectLegalHold(bucketName string, objectName string, hold bool, ctx context.Context) error {
+	status := minio.LegalHoldDisabled
+	if hold {
+		status = minio.LegalHoldEnabled
+	}
+	err := m.client.PutObjectLegalHold(ctx, bucketName, objectName, minio.PutObjectLegalHoldOptions{Status: &status})
+	return minioError(err)
+}
+
+func (m *MinioBackend) BucketExists(bucketName string, ctx context.Context) (bool, error) {
+	return m.client.BucketExists(ctx, bucketName)
+}
+
+func (m *MinioBackend) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	buckets, err := m.client.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		infos = append(infos, BucketInfo{Name: bucket.Name, CreationDate: bucket.CreationDate})
+	}
+	return infos, nil
+}
+
+// SetBucketLifecycle replaces the expiration rules of the bucket,
+// the rules the Collector can't represent, such as transitions, are kept.
+func (m *MinioBackend) SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	config, err := m.getBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]lifecycle.Rule, 0, len(config.Rules)+len(rules))
+	for _, rule := range config.Rules {
+		if _, ok := fromMinioRule(rule); !ok {
+			kept = append(kept, rule)
+		}
+	}
+	for _, rule := range rules {
+		kept = append(kept, toMinioRule(rule))
+	}
+	config.Rules = kept
+	// an empty configuration removes the lifecycle of the bucket
+	return m.client.SetBucketLifecycle(ctx, bucketName, config)
+}
+
+func (m *MinioBackend) GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error) {
+	config, err := m.getBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := []LifecycleRule{}
+	for _, minioRule := range config.Rules {
+		if rule, ok := fromMinioRule(minioRule); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func toMinioRule(rule LifecycleRule) lifecycle.Rule {
+	minioRule := lifecycle.Rule{
+		ID:         rule.Id,
+		Status:     "Enabled",
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(rule.Days)},
+	}
+
+	tags := make([]lifecycle.Tag, 0, len(rule.Tags))
+	for key, value := range rule.Tags {
+		tags = append(tags, lifecycle.Tag{Key: key, Value: value})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+
+	// S3 needs an And filter to combine a prefix with tags or several tags
+	switch {
+	case len(tags) == 0:
+		minioRule.RuleFilter.Prefix = rule.Prefix
+	case len(tags) == 1 && rule.Prefix == "":
+		minioRule.RuleFilter.Tag = tags[0]
+	default:
+		minioRule.RuleFilter.And = lifecycle.And{Prefix: rule.Prefix, Tags: tags}
+	}
+	return minioRule
+}
+
+// fromMinioRule converts an enabled rule expiring the objects after a number of days, it reports false for the other rules.
+func fromMinioRule(minioRule lifecycle.Rule) (LifecycleRule, bool) {
+	if minioRule.Status != "Enabled" || minioRule.Expiration.Days == 0 || !minioRule.Transition.IsNull() ||
+		!minioRule.NoncurrentVersionExpiration.IsDaysNull() || !minioRule.NoncurrentVersionTransition.IsStorageClassEmpty() {
+		return LifecycleRule{}, false
+	}
+
+	rule := LifecycleRule{Id: minioRule.ID, Days: int(minioRule.Expiration.Days)}
+	filter := minioRule.RuleFilter
+	switch {
+	case !filter.And.IsEmpty():
+		rule.Prefix = filter.And.Prefix
+		rule.Tags = make(map[string]string, len(filter.And.Tags))
+		for _, tag := range filter.And.Tags {
+			rule.Tags[tag.Key] = tag.Value
+		}
+	case !filter.Tag.IsEmpty():
+		rule.Tags = map[string]string{filter.Tag.Key: filter.Tag.Value}
+	case filter.Prefix != "":
+		rule.Prefix = filter.Prefix
+	default:
+		// the prefix of the rules written before the filters were introduced
+		rule.Prefix = minioRule.Prefix
+	}
+	return rule, true
+}
+
+// getBucketLifecycle returns the lifecycle of the bucket, empty if the bucket has none.
+func (m *MinioBackend) getBucketLifecycle(bucketName string, ctx context.Context) (*lifecycle.Configuration, error) {
+	config, err := m.client.GetBucketLifecycle(ctx, bucketName)
+	if err != nil {
+		// a bucket without lifecycle is not an error
+		if minio.ToErrorResponse(err).Code == "NoSuchLifecycleConfiguration" {
+			return lifecycle.NewConfiguration(), nil
+		}
+		return nil, minioError(err)
+	}
+	return config, nil
+}
+
+func (m *MinioBackend) DeleteBucket(bucketName string, ctx context.Context) error {
+	return minioError(m.client.RemoveBucket(ctx, bucketName))
+}
+
+func (m *MinioBackend) SetBucketTags(bucketName string, tagMap map[string]string, ctx context.Context) error {
+	if len(tagMap) == 0 {
+		return m.client.RemoveBucketTagging(ctx, bucketName)
+	}
+	bucketTags, err := tags.NewTags(tagMap, false)
+	if err != nil {
+		return err
+	}
+	return m.client.SetBucketTagging(ctx, bucketName, bucketTags)
+}
+
+func (m *MinioBackend) GetBucketTags(bucketName string, ctx context.Context) (map[string]string, error) {
+	bucketTags, err := m.client.GetBucketTagging(ctx, bucketName)
+	if err != nil {
+		// a bucket without tags is not an error
+		if minio.ToErrorResponse(err).Code == "NoSuchTagSet" {
+			return map[string]string{}, nil
+		}
+		return nil, minioError(err)
+	}
+	return bucketTags.ToMap(), nil
+}
+
+func (m *MinioBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	_, err := m.client.PutObject(ctx, bucketName, objectName, reader, size, minio.PutObjectOptions{
+		ContentType:  opts.ContentType,
+		UserMetadata: opts.Metadata,
+		UserTags:     opts.Tags,
+	})
+	return err
+}
+
+func (m *MinioBackend) GetObject(bucketName string, objectName string, ctx context.Context) (io.ReadCloser, ObjectInfo, error) {
+	object, err := m.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, minioError(err)
+	}
+	// the request is only sent on the first access to the object
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, ObjectInfo{}, minioError(err)
+	}
+	return object, minioObjectInfo(info), nil
+}
+
+func (m *MinioBackend) StatObject(bucketName string, objectName string, ctx context.Context) (ObjectInfo, error) {
+	info, err := m.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return minioObjectInfo(info), nil
+}
+
+func (m *MinioBackend) PresignGetObject(bucketName string, objectName string, expiry time.Duration, headers url.Values, ctx context.Context) (*url.URL, error) {
+	presignedURL, err := m.client.PresignedGetObject(ctx, bucketName, objectName, expiry, headers)
+	if err != nil {
+		return nil, minioError(err)
+	}
+	return presignedURL, nil
+}
+
+func (m *MinioBackend) DeleteObject(bucketName string, objectName string, ctx context.Context) error {
+	return m.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+}
+
+func (m *MinioBackend) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for info := range m.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if info.Err != nil {
+			return nil, minioError(info.Err)
+		}
+		objects = append(objects, minioObjectInfo(info))
+	}
+	return objects, nil
+}
+
+// ListObjectsWithMetadata lists the objects together with their user metadata, which only MinIO returns along with the listing:
+// the objects listed by the other S3 services have no metadata.
+func (m *MinioBackend) ListObjectsWithMetadata(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for info := range m.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true, WithMetadata: true}) {
+		if info.Err != nil {
+			return nil, minioError(info.Err)
+		}
+		object := minioObjectInfo(info)
+		object.Metadata = listedUserMetadata(info.UserMetadata)
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// listedUserMetadata returns the user metadata of a listed object, whose keys keep their x-amz-meta- prefix unlike the ones of StatObject.
+func listedUserMetadata(m map[string]string) map[string]string {
+	metadata := make(map[string]string)
+	for key, value := range m {
+		key = strings.ToLower(key)
+		if strings.HasPrefix(key, "x-amz-meta-") {
+			metadata[strings.TrimPrefix(key, "x-amz-meta-")] = value
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+func minioObjectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ContentType:  info.ContentType,
+		Metadata:     lowerKeys(info.UserMetadata),
+		Tags:         info.UserTags,
+		Lock:         minioObjectLock(info),
+	}
+}
+
+// minioObjectLock reads the protection of the object from the headers returned along with it.
+func minioObjectLock(info minio.ObjectInfo) ObjectLock {
+	lock := ObjectLock{
+		LegalHold:     info.Metadata.Get("X-Amz-Object-Lock-Legal-Hold") == string(minio.LegalHoldEnabled),
+		RetentionMode: strings.ToLower(info.Metadata.Get("X-Amz-Object-Lock-Mode")),
+	}
+	if retainUntil, err := time.Parse(time.RFC3339, info.Metadata.Get("X-Amz-Object-Lock-Retain-Until-Date")); err == nil {
+		lock.RetainUntil = &retainUntil
+	}
+	return lock
+}
+
+// lowerKeys returns the map with lower case keys, since S3 returns the user metadata keys in canonical header form.
+func lowerKeys(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	lowered := make(map[string]string, len(m))
+	for key, value := range m {
+		lowered[strings.ToLower(key)] = value
+	}
+	return lowered
+}
+
+// minioError maps the MinIO error responses to the errors defined by the storage package.
+func minioError(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey":
+		return ErrObjectNotFound
+	case "NoSuchBucket":
+		return ErrBucketNotFound
+	case "BucketNotEmpty":
+		return ErrBucketNotEmpty
+	default:
+		return err
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+func TestMinioRuleRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rule LifecycleRule
+	}{
+		{name: "bucket rule", rule: LifecycleRule{Id: BucketLifecycleRuleId, Days: 30}},
+		{name: "prefix", rule: LifecycleRule{Id: "a", Prefix: "sensors/", Days: 7}},
+		{name: "single tag", rule: NewFilterLifecycleRule("f1", 1)},
+		{name: "several tags", rule: LifecycleRule{Id: "a", Tags: map[string]string{"tag": "alerts", "level": "high"}, Days: 1}},
+		{name: "prefix and tag", rule: LifecycleRule{Id: "a", Prefix: "archive/", Tags: map[string]string{"tag": "alerts"}, Days: 90}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, ok := fromMinioRule(toMinioRule(test.rule))
+			if !ok {
+				t.Fatal("converted rule is not recognized")
+			}
+			if !reflect.DeepEqual(rule, test.rule) {
+				t.Errorf("rule is %v, want %v", rule, test.rule)
+			}
+		})
+	}
+}
+
+func TestFromMinioRuleIgnored(t *testing.T) {
+	tests := []struct {
+		name string
+		rule lifecycle.Rule
+	}{
+		{name: "disabled rule", rule: lifecycle.Rule{ID: "a", Status: "Disabled", Expiration: lifecycle.Expiration{Days: 7}}},
+		{name: "expiration at a date", rule: lifecycle.Rule{ID: "a", Status: "Enabled"}},
+		{name: "transition", rule: lifecycle.Rule{ID: "a", Status: "Enabled", Expiration: lifecycle.Expiration{Days: 7}, Transition: lifecycle.Transition{Days: 1, StorageClass: "GLACIER"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rule, ok := fromMinioRule(test.rule); ok {
+				t.Errorf("rule %v is recognized", rule)
+			}
+		})
+	}
+}
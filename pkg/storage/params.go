@@ -1,7 +1,12 @@
 package storage
 
+import "time"
+
 // ParametersRestAPI contains the definition of the parameters used by the Collector to access the S3 storage
 type Parameters struct {
+	// Type defines the kind of backend the objects are stored in
+	Type string `default:"s3" usage:"the storage backend type (s3, filesystem, memory)"`
+
 	// Endpoint defines the endpoint for the S3 storage
 	Endpoint string `default:"" usage:"the storage endpoint"`
 
@@ -17,12 +22,83 @@ type Parameters struct {
 	// DefaultBucketExpirationDays sets the default bucket's expiration days
 	DefaultBucketExpirationDays int `default:"30" usage:"sets the default bucket's expiration days"`
 
+	// DefaultBucketCompression sets the compression of the objects stored in the default bucket
+	DefaultBucketCompression string `default:"" usage:"sets the compression of the objects stored in the default bucket (gzip, zstd)"`
+
+	// DefaultBucketFormat sets the serialization of the objects stored in the default bucket
+	DefaultBucketFormat string `default:"json" usage:"sets the serialization of the objects stored in the default bucket (json, binary)"`
+
 	// Region defines the region of the S3 storage
 	Region string `default:"eu-south-1" usage:"defines the region of the S3 storage"`
 
 	// ObjectExtension sets the file extension for the object inside the storage
 	ObjectExtension string `default:"" usage:"sets the file extension for the object inside the storage"`
 
+	// InstanceId sets the id of this collector, recorded in the metadata of the objects it stores
+	InstanceId string `default:"" usage:"the id of this collector recorded in the objects it stores, defaults to the host name"`
+
 	// Secure defines whether the connection to S3 storage should be secure
 	Secure bool `default:"true" usage:"whether the connection to storage should be secure"`
+
+	Encryption struct {
+		// MasterKeyFile defines the file holding the master keys wrapping the buckets' data keys
+		MasterKeyFile string `default:"" usage:"the file holding the master keys wrapping the buckets' data keys"`
+
+		// DefaultBucketEncryption defines whether the objects stored in the default bucket are encrypted
+		DefaultBucketEncryption bool `default:"false" usage:"whether the objects stored in the default bucket are encrypted"`
+	} `name:"encryption"`
+
+	DefaultBucketQuota struct {
+		// MaxBytes defines the maximum size of the objects stored in the default bucket, 0 means no limit
+		MaxBytes int64 `default:"0" usage:"the maximum size in bytes of the default bucket, 0 means no limit"`
+
+		// MaxObjects defines the maximum number of objects stored in the default bucket, 0 means no limit
+		MaxObjects int `default:"0" usage:"the maximum number of objects of the default bucket, 0 means no limit"`
+
+		// Policy defines what happens to the uploads when the default bucket is full
+		Policy string `default:"reject" usage:"what happens to the uploads when the default bucket is full (reject, evict, overflow)"`
+
+		// OverflowBucket defines the bucket receiving the objects that don't fit in the default bucket with the overflow policy
+		OverflowBucket string `default:"" usage:"the bucket receiving the objects that don't fit in the default bucket with the overflow policy"`
+	} `name:"defaultBucketQuota"`
+
+	Replication struct {
+		// Endpoints defines the additional S3 endpoints the objects are replicated to
+		Endpoints []string `default:"" usage:"the additional S3 endpoints the objects are replicated to"`
+
+		// WriteQuorum defines how many endpoints must acknowledge an upload for it to succeed, 0 means all of them
+		WriteQuorum int `default:"0" usage:"how many endpoints must acknowledge an upload, 0 means all of them"`
+	} `name:"replication"`
+
+	Catalog struct {
+		// Path defines the file of the local metadata index of the stored objects, empty disables the index
+		Path string `default:"" usage:"the file of the local metadata index of the stored objects, empty disables it"`
+	} `name:"catalog"`
+
+	Spool struct {
+		// Path defines the file in which the failed uploads are persisted until they succeed, empty disables the spool
+		Path string `default:"" usage:"the file in which the failed uploads are persisted until they succeed, empty disables the spool"`
+
+		// MinBackoff defines the delay before the first retry of a failed upload, doubled at every further retry
+		MinBackoff time.Duration `default:"5s" usage:"the delay before the first retry of a failed upload, doubled at every further retry"`
+
+		// MaxBackoff defines the maximum delay between the retries of a failed upload
+		MaxBackoff time.Duration `default:"10m" usage:"the maximum delay between the retries of a failed upload"`
+	} `name:"spool"`
+
+	Scrub struct {
+		// Interval defines how often every bucket is scrubbed in background, 0 disables the background scrub
+		Interval time.Duration `default:"0s" usage:"how often every bucket is scrubbed in background, 0 disables it"`
+
+		// Repair defines whether the background scrub re-fetches the damaged objects from the node
+		Repair bool `default:"true" usage:"whether the background scrub re-fetches the damaged objects from the node"`
+	} `name:"scrub"`
+
+	Filesystem struct {
+		// Path defines the directory in which the buckets are stored
+		Path string `default:"storage" usage:"the directory in which the buckets are stored"`
+
+		// SweepInterval defines how often the objects older than their bucket's expiration days are deleted
+		SweepInterval time.Duration `default:"1h" usage:"how often the expired objects are deleted"`
+	} `name:"filesystem"`
 }
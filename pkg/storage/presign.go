@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxPresignExpiry is the longest validity of a presigned URL accepted by S3.
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// ErrPresignNotSupported is returned when the backend can't generate presigned URLs.
+var ErrPresignNotSupported = errors.New("presigned URLs are not supported by the storage backend")
+
+// ErrNoProof is returned when a proof of inclusion is requested for an object stored without one.
+var ErrNoProof = errors.New("object has no proof of inclusion")
+
+// PresignedObject is a time-limited URL downloading a stored object directly from the storage.
+type PresignedObject struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Format is the serialization of the downloaded object, to be read with DecodeObject
+	Format string `json:"format"`
+	// WithPOI tells whether the downloaded object holds a proof of inclusion
+	WithPOI bool `json:"withPOI"`
+	// Compression is the compression the caller must undo before decoding the object, empty when the object is not compressed
+	// or is decompressed by the HTTP client
+	Compression string `json:"compression,omitempty"`
+}
+
+// presigner is implemented by the backends able to generate presigned URLs.
+type presigner interface {
+	// PresignGetObject returns a URL downloading the object without credentials until it expires,
+	// the response is served with the given headers.
+	PresignGetObject(bucketName string, objectName string, expiry time.Duration, headers url.Values, ctx context.Context) (*url.URL, error)
+}
+
+// PresignObject returns a URL downloading the object holding the block until expiry has elapsed.
+// The object is served as stored: gzip objects are decompressed by the HTTP client, while the other compressed objects are served
+// as such and must be decompressed by the caller, as told by the compression of the result; encrypted objects can't be presigned.
+// When withPOI is set, the object must hold a proof of inclusion.
+func (s *Storage) PresignObject(bucketName string, blockId string, withPOI bool, expiry time.Duration, ctx context.Context) (PresignedObject, error) {
+	var presigned PresignedObject
+	backend, ok := s.backend.(presigner)
+	if !ok {
+		return presigned, ErrPresignNotSupported
+	}
+	if expiry <= 0 || expiry > MaxPresignExpiry {
+		return presigned, fmt.Errorf("invalid expiry %s, it must be positive and at most %s", expiry, MaxPresignExpiry)
+	}
+
+	bucketName, objectName, err := s.locateObject(bucketName, blockId, ctx)
+	if err != nil {
+		return presigned, err
+	}
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
+	if err != nil {
+		return presigned, err
+	}
+
+	if info.Metadata[metadataEncryption] != "" {
+		return presigned, fmt.Errorf("object '%s' is encrypted and can only be retrieved through the collector", blockId)
+	}
+	presigned.WithPOI, _ = strconv.ParseBool(info.Metadata[metadataPOI])
+	if withPOI && !presigned.WithPOI {
+		return presigned, ErrNoProof
+	}
+	presigned.Format = info.Metadata[metadataFormat]
+	if presigned.Format == "" {
+		presigned.Format = FormatJSON
+	}
+
+	headers := url.Values{}
+	if presigned.Format == FormatJSON {
+		headers.Set("response-content-type", "application/json")
+	}
+	switch encoding := info.Metadata[metadataEncoding]; encoding {
+	case CompressionNone:
+	case CompressionGzip:
+		// every HTTP client decodes gzip
+		headers.Set("response-content-encoding", encoding)
+	default:
+		// few HTTP clients decode zstd, the object is served as a compressed file
+		headers.Set("response-content-type", "application/"+encoding)
+		presigned.Compression = encoding
+	}
+
+	presigned.ExpiresAt = time.Now().Add(expiry)
+	presignedURL, err := backend.PresignGetObject(bucketName, objectName, expiry, headers, ctx)
+	if err != nil {
+		return presigned, err
+	}
+	presigned.URL = presignedURL.String()
+	return presigned, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// presigningBackend presigns the objects of the memory backend, the URL query holding the requested response headers.
+type presigningBackend struct {
+	*MemoryBackend
+}
+
+func (b *presigningBackend) PresignGetObject(bucketName string, objectName string, expiry time.Duration, headers url.Values, ctx context.Context) (*url.URL, error) {
+	return &url.URL{Scheme: "https", Host: "storage.example.com", Path: "/" + bucketName + "/" + objectName, RawQuery: headers.Encode()}, nil
+}
+
+func TestPresignObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		// wantHeaders are the response headers requested for the URL
+		wantHeaders     url.Values
+		wantCompression string
+	}{
+		{name: "uncompressed", compression: CompressionNone, wantHeaders: url.Values{"response-content-type": {"application/json"}}},
+		{name: "gzip is decoded by the client", compression: CompressionGzip, wantHeaders: url.Values{"response-content-type": {"application/json"}, "response-content-encoding": {"gzip"}}},
+		{name: "zstd is decoded by the caller", compression: CompressionZstd, wantHeaders: url.Values{"response-content-type": {"application/zstd"}}, wantCompression: CompressionZstd},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorageWithBackend(t, &presigningBackend{MemoryBackend: NewMemoryBackend()}, Parameters{})
+			if err := s.SetBucketConfig("default", BucketConfig{Compression: test.compression}, ctx); err != nil {
+				t.Fatal(err)
+			}
+			object := newTestObject(t, "sensors", "temperature=21")
+			attributes := newTestAttributes(t, object, 42)
+			if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			presigned, err := s.PresignObject("default", attributes.BlockId, false, time.Hour, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			presignedURL, err := url.Parse(presigned.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if headers := presignedURL.Query(); headers.Encode() != test.wantHeaders.Encode() {
+				t.Errorf("response headers are %v, want %v", headers, test.wantHeaders)
+			}
+			if presigned.Compression != test.wantCompression {
+				t.Errorf("compression is '%s', want '%s'", presigned.Compression, test.wantCompression)
+			}
+			if presigned.Format != FormatJSON || presigned.WithPOI {
+				t.Errorf("presigned %s object with proof %t, want %s without proof", presigned.Format, presigned.WithPOI, FormatJSON)
+			}
+
+			if _, err := s.PresignObject("default", attributes.BlockId, true, time.Hour, ctx); !errors.Is(err, ErrNoProof) {
+				t.Errorf("error is %v presigning with a proof, want %v", err, ErrNoProof)
+			}
+		})
+	}
+}
+
+func TestPresignObjectNotSupported(t *testing.T) {
+	s := newTestStorage(t, Parameters{})
+	if _, err := s.PresignObject("default", "00", false, time.Hour, context.Background()); !errors.Is(err, ErrPresignNotSupported) {
+		t.Errorf("error is %v, want %v", err, ErrPresignNotSupported)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// privateBucketPrefix prefixes the names in the storage of the private buckets, it is reserved to them.
+const privateBucketPrefix = "private-"
+
+// userIdRegexp matches the valid user ids, which can't contain hyphens so that the private bucket names are unambiguous.
+var userIdRegexp = regexp.MustCompile(`^[a-z0-9]{1,16}$`)
+
+func ValidateUserId(userId string) error {
+	if !userIdRegexp.MatchString(userId) {
+		return fmt.Errorf("invalid user id '%s', it must be 1 to 16 lowercase letters and digits", userId)
+	}
+	return nil
+}
+
+// PrivateBucketName returns the name in the storage of the private bucket the user calls bucketName.
+func PrivateBucketName(userId string, bucketName string) string {
+	return privateBucketPrefix + userId + "-" + bucketName
+}
+
+// IsPrivateBucketName reports whether the bucket name is in the namespace of the private buckets.
+func IsPrivateBucketName(bucketName string) bool {
+	return strings.HasPrefix(bucketName, privateBucketPrefix)
+}
+
+// UserBucketName returns the name the owner gives to the private bucket.
+func UserBucketName(userId string, bucketName string) string {
+	if userId == "" {
+		return bucketName
+	}
+	return strings.TrimPrefix(bucketName, privateBucketPrefix+userId+"-")
+}
+
+// CheckBucketOwner returns ErrBucketNotFound unless the bucket belongs to the user, an empty user id standing for the shared buckets,
+// so that the private buckets are not revealed to the other users.
+func (s *Storage) CheckBucketOwner(bucketName string, userId string, ctx context.Context) error {
+	config, err := s.GetBucketConfig(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	if config.Owner != userId {
+		return ErrBucketNotFound
+	}
+	return nil
+}
+
+// ListOwnedBuckets returns the buckets belonging to the user, the shared buckets for an empty user id.
+func (s *Storage) ListOwnedBuckets(userId string, ctx context.Context) ([]BucketInfo, error) {
+	buckets, err := s.backend.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		err := s.CheckBucketOwner(bucket.Name, userId, ctx)
+		if errors.Is(err, ErrBucketNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, bucket)
+	}
+	return owned, nil
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// QuotaPolicyReject refuses the uploads to a full bucket.
+	QuotaPolicyReject = "reject"
+	// QuotaPolicyEvict deletes the oldest objects of a full bucket to make room for the new ones.
+	QuotaPolicyEvict = "evict"
+	// QuotaPolicyOverflow stores the objects that don't fit in a full bucket in its overflow bucket.
+	QuotaPolicyOverflow = "overflow"
+
+	bucketTagQuotaBytes    = bucketTagPrefix + "quota-bytes"
+	bucketTagQuotaObjects  = bucketTagPrefix + "quota-objects"
+	bucketTagQuotaPolicy   = bucketTagPrefix + "quota-policy"
+	bucketTagQuotaOverflow = bucketTagPrefix + "quota-overflow"
+
+	// usageRefreshInterval is how often the tracked usage of a bucket is recomputed from the storage,
+	// to account for the objects expired by the lifecycle or written by other collectors
+	usageRefreshInterval = 10 * time.Minute
+
+	// evictionHeadroom is the share of the quota freed beyond the room of the new object when a bucket with the evict policy is full,
+	// 1/evictionHeadroom of its limits, so that the next uploads don't evict an object each
+	evictionHeadroom = 20
+)
+
+// ErrQuotaExceeded is returned when uploading to a full bucket with the reject policy.
+var ErrQuotaExceeded = errors.New("bucket quota exceeded")
+
+// Quota limits the size of a bucket, a limit of 0 means no limit.
+type Quota struct {
+	MaxBytes   int64 `json:"maxBytes,omitempty"`
+	MaxObjects int   `json:"maxObjects,omitempty"`
+	// Policy defines what happens to the uploads when the bucket is full, empty means reject
+	Policy string `json:"policy,omitempty"`
+	// OverflowBucket receives the objects that don't fit in the bucket with the overflow policy
+	OverflowBucket string `json:"overflowBucket,omitempty"`
+}
+
+func (q Quota) Validate() error {
+	if q.MaxBytes < 0 || q.MaxObjects < 0 {
+		return fmt.Errorf("invalid quota limits")
+	}
+	switch q.Policy {
+	case "", QuotaPolicyReject, QuotaPolicyEvict:
+		if q.OverflowBucket != "" {
+			return fmt.Errorf("an overflow bucket needs the '%s' quota policy", QuotaPolicyOverflow)
+		}
+		return nil
+	case QuotaPolicyOverflow:
+		if q.OverflowBucket == "" {
+			return fmt.Errorf("the '%s' quota policy needs an overflow bucket", QuotaPolicyOverflow)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown quota policy '%s', supported are '%s', '%s' and '%s'", q.Policy, QuotaPolicyReject, QuotaPolicyEvict, QuotaPolicyOverflow)
+	}
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxBytes > 0 || q.MaxObjects > 0
+}
+
+// fits reports whether the bucket can hold an object of the given size on top of its usage.
+func (q Quota) fits(usage bucketUsage, size int64) bool {
+	if q.MaxBytes > 0 && usage.bytes+size > q.MaxBytes {
+		return false
+	}
+	if q.MaxObjects > 0 && usage.objects+1 > q.MaxObjects {
+		return false
+	}
+	return true
+}
+
+// withHeadroom returns the quota lowered by the headroom left when evicting objects.
+func (q Quota) withHeadroom() Quota {
+	q.MaxBytes -= q.MaxBytes / evictionHeadroom
+	q.MaxObjects -= q.MaxObjects / evictionHeadroom
+	return q
+}
+
+func quotaFromTags(tags map[string]string) Quota {
+	quota := Quota{
+		Policy:         tags[bucketTagQuotaPolicy],
+		OverflowBucket: tags[bucketTagQuotaOverflow],
+	}
+	quota.MaxBytes, _ = strconv.ParseInt(tags[bucketTagQuotaBytes], 10, 64)
+	quota.MaxObjects, _ = strconv.Atoi(tags[bucketTagQuotaObjects])
+	return quota
+}
+
+func (q Quota) applyToTags(tags map[string]string) {
+	if !q.enabled() {
+		return
+	}
+	if q.MaxBytes > 0 {
+		tags[bucketTagQuotaBytes] = strconv.FormatInt(q.MaxBytes, 10)
+	}
+	if q.MaxObjects > 0 {
+		tags[bucketTagQuotaObjects] = strconv.Itoa(q.MaxObjects)
+	}
+	if q.Policy != "" && q.Policy != QuotaPolicyReject {
+		tags[bucketTagQuotaPolicy] = q.Policy
+	}
+	if q.OverflowBucket != "" {
+		tags[bucketTagQuotaOverflow] = q.OverflowBucket
+	}
+}
+
+// bucketUsage is the usage of a bucket tracked by the collector.
+type bucketUsage struct {
+	objects  int
+	bytes    int64
+	computed time.Time
+}
+
+// bucketQuota is the state of the quota of a bucket.
+type bucketQuota struct {
+	// mutex serializes the uploads to the bucket while its quota is applied, without blocking the uploads to the other buckets
+	mutex sync.Mutex
+	// candidates are the objects of the bucket not evicted yet, oldest first, as they were listed
+	candidates []ObjectInfo
+	listed     time.Time
+}
+
+// bucketQuota returns the quota state of the bucket.
+func (s *Storage) bucketQuota(bucketName string) *bucketQuota {
+	s.usageMutex.Lock()
+	defer s.usageMutex.Unlock()
+
+	state, ok := s.bucketQuotas[bucketName]
+	if !ok {
+		state = &bucketQuota{}
+		s.bucketQuotas[bucketName] = state
+	}
+	return state
+}
+
+// getUsage returns the tracked usage of the bucket, computing it from the storage when it is missing or stale.
+// It must be called with the quota mutex of the bucket held.
+func (s *Storage) getUsage(bucketName string, ctx context.Context) (bucketUsage, error) {
+	s.usageMutex.Lock()
+	usage, ok := s.usage[bucketName]
+	s.usageMutex.Unlock()
+	if ok && time.Since(usage.computed) < usageRefreshInterval {
+		return usage, nil
+	}
+
+	objects, bytes, err := s.GetBucketUsage(bucketName, ctx)
+	if err != nil {
+		return bucketUsage{}, err
+	}
+	usage = bucketUsage{objects: objects, bytes: bytes, computed: time.Now()}
+	s.usageMutex.Lock()
+	s.usage[bucketName] = usage
+	s.usageMutex.Unlock()
+	return usage, nil
+}
+
+// updateUsage records the change of the objects and bytes of the bucket, if its usage is tracked.
+func (s *Storage) updateUsage(bucketName string, objects int, bytes int64) {
+	s.usageMutex.Lock()
+	defer s.usageMutex.Unlock()
+
+	usage, ok := s.usage[bucketName]
+	if !ok {
+		return
+	}
+	usage.objects += objects
+	usage.bytes += bytes
+	s.usage[bucketName] = usage
+}
+
+// makeRoom applies the quota of the bucket before storing an object of the given size in place of the replaced object, if any.
+// It returns the bucket the object must be stored in, which is the overflow bucket if the bucket is full. When the object is stored
+// in the bucket its room is reserved in the tracked usage, so that concurrent uploads can't exceed the quota, and the returned
+// function releases the room if the upload fails.
+func (s *Storage) makeRoom(bucketName string, quota Quota, size int64, replaced *ObjectInfo, ctx context.Context) (string, func(), error) {
+	objects, bytes := 1, size
+	if replaced != nil {
+		objects, bytes = 0, size-replaced.Size
+	}
+	release := func() { s.updateUsage(bucketName, -objects, -bytes) }
+
+	if !quota.enabled() {
+		s.updateUsage(bucketName, objects, bytes)
+		return bucketName, release, nil
+	}
+
+	state := s.bucketQuota(bucketName)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	usage, err := s.getUsage(bucketName, ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	// the replaced object frees its room
+	if replaced != nil {
+		usage.objects--
+		usage.bytes -= replaced.Size
+	}
+	if !quota.fits(usage, size) {
+		switch quota.Policy {
+		case QuotaPolicyOverflow:
+			s.WrappedLogger.LogInfof("Bucket '%s' is full, storing object in overflow bucket '%s'", bucketName, quota.OverflowBucket)
+			return quota.OverflowBucket, nil, nil
+		case QuotaPolicyEvict:
+			if err := s.evict(bucketName, state, quota, usage, size, replaced, ctx); err != nil {
+				return "", nil, err
+			}
+		default:
+			return "", nil, fmt.Errorf("bucket '%s': %w", bucketName, ErrQuotaExceeded)
+		}
+	}
+
+	s.updateUsage(bucketName, objects, bytes)
+	return bucketName, release, nil
+}
+
+// locateObject returns the bucket holding the block and the name of its object: the bucket itself,
+// or its overflow bucket when the block was stored there because the bucket was full.
+func (s *Storage) locateObject(bucketName string, blockId string, ctx context.Context) (string, string, error) {
+	objectName, err := s.ResolveObjectName(bucketName, blockId, ctx)
+	if !errors.Is(err, ErrObjectNotFound) {
+		return bucketName, objectName, err
+	}
+	overflowBucket := s.overflowBucket(bucketName, ctx)
+	if overflowBucket == "" {
+		return "", "", err
+	}
+	objectName, err = s.ResolveObjectName(overflowBucket, blockId, ctx)
+	return overflowBucket, objectName, err
+}
+
+// overflowBucket returns the bucket receiving the objects that don't fit in the bucket, empty if it has none.
+func (s *Storage) overflowBucket(bucketName string, ctx context.Context) string {
+	config, err := s.GetBucketConfig(bucketName, ctx)
+	if err != nil || config.Quota.Policy != QuotaPolicyOverflow {
+		return ""
+	}
+	return config.Quota.OverflowBucket
+}
+
+// evict deletes the oldest objects of the bucket until an object of the given size fits with some headroom, the locked objects are kept.
+// The objects are listed once and evicted in order over the next uploads, the bucket being listed again when they are all evicted
+// or when the listing is stale. It must be called with the quota mutex of the bucket held.
+func (s *Storage) evict(bucketName string, state *bucketQuota, quota Quota, usage bucketUsage, size int64, replaced *ObjectInfo, ctx context.Context) error {
+	target := quota.withHeadroom()
+	evicted := 0
+	var evictedBytes int64
+	listed := false
+	for !target.fits(usage, size) {
+		if len(state.candidates) == 0 || time.Since(state.listed) > usageRefreshInterval {
+			// the objects stored since the previous listing are newer than the objects it returned
+			if listed {
+				break
+			}
+			objects, err := s.ListObjects(bucketName, ctx)
+			if err != nil {
+				return err
+			}
+			sort.Slice(objects, func(i, j int) bool { return objects[i].LastModified.Before(objects[j].LastModified) })
+			state.candidates, state.listed = objects, time.Now()
+			listed = true
+			continue
+		}
+
+		object := state.candidates[0]
+		state.candidates = state.candidates[1:]
+		if replaced != nil && object.Key == replaced.Key {
+			continue
+		}
+		deleted, err := s.evictObject(bucketName, object.Key, &object, ctx)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			continue
+		}
+		usage.objects--
+		usage.bytes -= object.Size
+		evicted++
+		evictedBytes += object.Size
+	}
+
+	s.updateUsage(bucketName, -evicted, -evictedBytes)
+	s.WrappedLogger.LogInfof("Evicted %d objects from full bucket '%s'", evicted, bucketName)
+
+	if !quota.fits(usage, size) {
+		return fmt.Errorf("bucket '%s': %w, no more objects can be evicted", bucketName, ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// evictObject deletes the object together with its index entry, it reports false if the object is locked. When the object
+// was listed for eviction, it is not deleted either if it was written again since, as it is no longer among the oldest objects.
+func (s *Storage) evictObject(bucketName string, objectName string, listed *ObjectInfo, ctx context.Context) (bool, error) {
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Lock.IsLocked() {
+		return false, nil
+	}
+	if listed != nil && info.LastModified.After(listed.LastModified) {
+		return false, nil
+	}
+
+	err = s.backend.DeleteObject(bucketName, objectName, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// objects stored under a different key than the block id have an index entry, if it still points to them
+	blockId := info.Metadata[metadataBlockId]
+	if blockId == "" {
+		blockId = strings.TrimSuffix(objectName, s.objectExtension)
+	}
+	s.catalogDelete(bucketName, blockId)
+	if objectName == blockId+s.objectExtension {
+		return true, nil
+	}
+	indexed, err := s.readIndex(bucketName, blockId, ctx)
+	if err == nil && indexed == objectName {
+		err = s.backend.DeleteObject(bucketName, indexKey(blockId), ctx)
+		if err != nil {
+			s.WrappedLogger.LogWarnf("Can't delete index of object '%s' from bucket '%s', error: %s", objectName, bucketName, err)
+		}
+	}
+	return true, nil
+}
+
+// resetUsage forgets the tracked usage of the bucket, to be computed again from the storage.
+func (s *Storage) resetUsage(bucketName string) {
+	s.usageMutex.Lock()
+	defer s.usageMutex.Unlock()
+	delete(s.usage, bucketName)
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestQuotaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   Quota
+		wantErr bool
+	}{
+		{name: "no quota", quota: Quota{}},
+		{name: "reject", quota: Quota{MaxObjects: 10, Policy: QuotaPolicyReject}},
+		{name: "evict", quota: Quota{MaxBytes: 1024, Policy: QuotaPolicyEvict}},
+		{name: "overflow", quota: Quota{MaxObjects: 10, Policy: QuotaPolicyOverflow, OverflowBucket: "overflow"}},
+		{name: "negative limit", quota: Quota{MaxBytes: -1}, wantErr: true},
+		{name: "overflow without bucket", quota: Quota{MaxObjects: 10, Policy: QuotaPolicyOverflow}, wantErr: true},
+		{name: "overflow bucket without overflow policy", quota: Quota{MaxObjects: 10, Policy: QuotaPolicyEvict, OverflowBucket: "overflow"}, wantErr: true},
+		{name: "unknown policy", quota: Quota{MaxObjects: 10, Policy: "drop"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.quota.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("error is %v, want an error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuotaPolicies(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota Quota
+		// wantErr is the error of the upload which doesn't fit
+		wantErr error
+		// wantStored and wantOverflow are the numbers of objects in the bucket and in the overflow bucket
+		wantStored   int
+		wantOverflow int
+		// wantFirst reports whether the first block is still stored
+		wantFirst bool
+	}{
+		{name: "no quota", quota: Quota{}, wantStored: 3, wantFirst: true},
+		{name: "reject", quota: Quota{MaxObjects: 2, Policy: QuotaPolicyReject}, wantErr: ErrQuotaExceeded, wantStored: 2, wantFirst: true},
+		{name: "default policy rejects", quota: Quota{MaxObjects: 2}, wantErr: ErrQuotaExceeded, wantStored: 2, wantFirst: true},
+		{name: "evict", quota: Quota{MaxObjects: 2, Policy: QuotaPolicyEvict}, wantStored: 2, wantFirst: false},
+		{name: "overflow", quota: Quota{MaxObjects: 2, Policy: QuotaPolicyOverflow, OverflowBucket: "overflow"}, wantStored: 2, wantOverflow: 1, wantFirst: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorage(t, Parameters{})
+			if err := s.CreateBucket("overflow", ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetBucketConfig("default", BucketConfig{Quota: test.quota}, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			var blockIds []string
+			for i, data := range []string{"first", "second", "third"} {
+				object := newTestObject(t, "sensors", data)
+				attributes := newTestAttributes(t, object, uint32(42+i))
+				blockIds = append(blockIds, attributes.BlockId)
+				err := s.UploadObject(attributes, "default", object, ctx)
+				if i < 2 || test.wantErr == nil {
+					if err != nil {
+						t.Fatal(err)
+					}
+				} else if !errors.Is(err, test.wantErr) {
+					t.Fatalf("error is %v, want %v", err, test.wantErr)
+				}
+			}
+
+			for bucketName, want := range map[string]int{"default": test.wantStored, "overflow": test.wantOverflow} {
+				objects, err := s.ListObjects(bucketName, ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(objects) != want {
+					t.Errorf("bucket '%s' holds %d objects, want %d", bucketName, len(objects), want)
+				}
+			}
+
+			_, _, err := s.locateObject("default", blockIds[0], ctx)
+			if found := err == nil; found != test.wantFirst {
+				t.Errorf("first block found: %t, want %t", found, test.wantFirst)
+			}
+			// the last block is found from the bucket, even when it was stored in the overflow bucket
+			reader, _, err := s.GetObject("default", blockIds[2], ctx)
+			if found := err == nil; found != (test.wantErr == nil) {
+				t.Errorf("last block found: %t (%v), want %t", found, err, test.wantErr == nil)
+			}
+			if err == nil {
+				reader.Close()
+				if err := s.DeleteObject("default", blockIds[2], ctx); err != nil {
+					t.Errorf("can't delete the last block, error: %s", err)
+				}
+				if _, _, err := s.GetObject("default", blockIds[2], ctx); !errors.Is(err, ErrObjectNotFound) {
+					t.Errorf("error is %v after deleting the last block, want %v", err, ErrObjectNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaReleasedOnFailedUpload(t *testing.T) {
+	ctx := context.Background()
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	s := newTestStorageWithBackend(t, backend, Parameters{})
+	if err := s.SetBucketConfig("default", BucketConfig{Quota: Quota{MaxObjects: 1}}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed upload must not keep the room it reserved
+	backend.err = errors.New("storage unavailable")
+	object := newTestObject(t, "sensors", "first")
+	if err := s.UploadObject(newTestAttributes(t, object, 42), "default", object, ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	backend.err = nil
+	if err := s.UploadObject(newTestAttributes(t, object, 42), "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	usage := s.usage["default"]
+	if usage.objects != 1 {
+		t.Errorf("tracked usage is %d objects, want 1", usage.objects)
+	}
+	other := newTestObject(t, "sensors", "second")
+	if err := s.UploadObject(newTestAttributes(t, other, 43), "default", other, ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("error is %v, want %v", err, ErrQuotaExceeded)
+	}
+}
+
+// listingBackend counts the listings of the memory backend.
+type listingBackend struct {
+	*MemoryBackend
+	lists int
+}
+
+func (b *listingBackend) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	b.lists++
+	return b.MemoryBackend.ListObjects(bucketName, ctx)
+}
+
+func TestEvictInBatches(t *testing.T) {
+	ctx := context.Background()
+	backend := &listingBackend{MemoryBackend: NewMemoryBackend()}
+	s := newTestStorageWithBackend(t, backend, Parameters{})
+	if err := s.SetBucketConfig("default", BucketConfig{Quota: Quota{MaxObjects: 40, Policy: QuotaPolicyEvict}}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var blockIds []string
+	upload := func(i int) {
+		object := newTestObject(t, "sensors", fmt.Sprintf("block %d", i))
+		attributes := newTestAttributes(t, object, uint32(i))
+		blockIds = append(blockIds, attributes.BlockId)
+		if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 40; i++ {
+		upload(i)
+	}
+
+	// the full bucket is evicted down to its quota less the headroom, and listed once for the next evictions
+	backend.lists = 0
+	for i := 40; i < 45; i++ {
+		upload(i)
+	}
+	if backend.lists != 1 {
+		t.Errorf("bucket listed %d times, want 1", backend.lists)
+	}
+	objects, err := s.ListObjects("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 39 {
+		t.Errorf("bucket holds %d objects, want 39", len(objects))
+	}
+	for i, blockId := range blockIds {
+		_, _, err := s.locateObject("default", blockId, ctx)
+		if found, want := err == nil, i >= 6; found != want {
+			t.Errorf("block %d found: %t, want %t", i, found, want)
+		}
+	}
+}
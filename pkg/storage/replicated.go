@@ -0,0 +1,509 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+const (
+	// replicaRetries is the number of times the write failed by a replica is retried once the other replicas acknowledged it
+	replicaRetries = 5
+	// replicaRetryBackoff is the delay before the first retry of a write failed by a replica, doubled at every further retry
+	replicaRetryBackoff = time.Second
+)
+
+// ReplicatedBackend writes every object to several replicas and reads from the first replica able to serve it.
+type ReplicatedBackend struct {
+	*logger.WrappedLogger
+	replicas     []Backend
+	names        []string
+	writeQuorum  int
+	retryBackoff time.Duration
+	// writesMutex guards the last write of each key on each replica, which the next write of the key waits for,
+	// so that the writes of a key reach every replica in the same order even when the slower replicas complete them in background
+	writesMutex sync.Mutex
+	lastWrites  []map[string]chan struct{}
+	// pending counts the writes still running on a replica
+	pending sync.WaitGroup
+}
+
+// NewReplicatedBackend returns a backend replicating the objects over the given replicas.
+// A write succeeds once writeQuorum replicas acknowledge it, a quorum of 0 means every replica.
+func NewReplicatedBackend(replicas []Backend, names []string, writeQuorum int, log *logger.WrappedLogger) (*ReplicatedBackend, error) {
+	if len(replicas) == 0 {
+		return nil, fmt.Errorf("no replicas defined")
+	}
+	if len(names) != len(replicas) {
+		return nil, fmt.Errorf("got %d names for %d replicas", len(names), len(replicas))
+	}
+	if writeQuorum <= 0 {
+		writeQuorum = len(replicas)
+	}
+	if writeQuorum > len(replicas) {
+		return nil, fmt.Errorf("write quorum %d is greater than the number of replicas %d", writeQuorum, len(replicas))
+	}
+
+	lastWrites := make([]map[string]chan struct{}, len(replicas))
+	for i := range lastWrites {
+		lastWrites[i] = make(map[string]chan struct{})
+	}
+	return &ReplicatedBackend{
+		WrappedLogger: logger.NewWrappedLogger(log.LoggerNamed("Replicated")),
+		replicas:      replicas,
+		names:         names,
+		writeQuorum:   writeQuorum,
+		retryBackoff:  replicaRetryBackoff,
+		lastWrites:    lastWrites,
+	}, nil
+}
+
+func newReplicatedMinioBackend(params Parameters, log *logger.WrappedLogger) (*ReplicatedBackend, error) {
+	endpoints := append([]string{params.Endpoint}, params.Replication.Endpoints...)
+
+	replicas := make([]Backend, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		replica, err := NewMinioBackend(endpoint, params)
+		if err != nil {
+			return nil, fmt.Errorf("can't connect to replica '%s', error: %w", endpoint, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return NewReplicatedBackend(replicas, endpoints, params.Replication.WriteQuorum, log)
+}
+
+// Replicas returns the backends the objects are replicated to.
+func (r *ReplicatedBackend) Replicas() []Backend {
+	return r.replicas
+}
+
+// CreateBucket creates the bucket on the replicas missing it.
+func (r *ReplicatedBackend) CreateBucket(bucketName string, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("creating bucket '%s'", bucketName), func(replica Backend) error {
+		exists, err := replica.BucketExists(bucketName, ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		return replica.CreateBucket(bucketName, ctx)
+	})
+}
+
+// DeleteBucket deletes the bucket from the replicas still holding it.
+func (r *ReplicatedBackend) DeleteBucket(bucketName string, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("deleting bucket '%s'", bucketName), func(replica Backend) error {
+		exists, err := replica.BucketExists(bucketName, ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+		return replica.DeleteBucket(bucketName, ctx)
+	})
+}
+
+// CreateLockedBucket creates the bucket with object lock on the replicas missing it, every replica must support object lock.
+func (r *ReplicatedBackend) CreateLockedBucket(bucketName string, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("creating bucket '%s' with object lock", bucketName), func(replica Backend) error {
+		locker, ok := replica.(objectLocker)
+		if !ok {
+			return ErrObjectLockNotSupported
+		}
+		exists, err := replica.BucketExists(bucketName, ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		return locker.CreateLockedBucket(bucketName, ctx)
+	})
+}
+
+func (r *ReplicatedBackend) SetBucketRetention(bucketName string, retention Retention, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("setting retention for bucket '%s'", bucketName), func(replica Backend) error {
+		locker, ok := replica.(objectLocker)
+		if !ok {
+			return ErrObjectLockNotSupported
+		}
+		return locker.SetBucketRetention(bucketName, retention, ctx)
+	})
+}
+
+func (r *ReplicatedBackend) GetBucketRetention(bucketName string, ctx context.Context) (Retention, bool, error) {
+	var retention Retention
+	var enabled bool
+	err := r.read(fmt.Sprintf("retrieving retention for bucket '%s'", bucketName), func(replica Backend) error {
+		locker, ok := replica.(objectLocker)
+		if !ok {
+			return ErrObjectLockNotSupported
+		}
+		var err error
+		retention, enabled, err = locker.GetBucketRetention(bucketName, ctx)
+		return err
+	})
+	return retention, enabled, err
+}
+
+func (r *ReplicatedBackend) SetObjectLegalHold(bucketName string, objectName string, hold bool, ctx context.Context) error {
+	return r.write(objectKey(bucketName, objectName), fmt.Sprintf("setting legal hold on object '%s' of bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		locker, ok := replica.(objectLocker)
+		if !ok {
+			return ErrObjectLockNotSupported
+		}
+		return locker.SetObjectLegalHold(bucketName, objectName, hold, ctx)
+	})
+}
+
+// BucketExists reports whether the bucket exists on every reachable replica,
+// so that a bucket missing from a replica gets created again.
+func (r *ReplicatedBackend) BucketExists(bucketName string, ctx context.Context) (bool, error) {
+	var lastErr error
+	reachable := 0
+	for i, replica := range r.replicas {
+		exists, err := replica.BucketExists(bucketName, ctx)
+		if err != nil {
+			r.WrappedLogger.LogWarnf("Replica '%s' failed checking bucket '%s', error: %s", r.names[i], bucketName, err)
+			lastErr = err
+			continue
+		}
+		if !exists {
+			return false, nil
+		}
+		reachable++
+	}
+	if reachable == 0 {
+		return false, lastErr
+	}
+	return true, nil
+}
+
+// ListBuckets returns the union of the buckets held by the reachable replicas.
+func (r *ReplicatedBackend) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	var lastErr error
+	reachable := 0
+	seen := make(map[string]struct{})
+	var buckets []BucketInfo
+	for i, replica := range r.replicas {
+		replicaBuckets, err := replica.ListBuckets(ctx)
+		if err != nil {
+			r.WrappedLogger.LogWarnf("Replica '%s' failed listing buckets, error: %s", r.names[i], err)
+			lastErr = err
+			continue
+		}
+		reachable++
+		for _, bucket := range replicaBuckets {
+			if _, ok := seen[bucket.Name]; ok {
+				continue
+			}
+			seen[bucket.Name] = struct{}{}
+			buckets = append(buckets, bucket)
+		}
+	}
+	if reachable == 0 {
+		return nil, lastErr
+	}
+	return buckets, nil
+}
+
+func (r *ReplicatedBackend) SetBucketLifecycle(bucketName string, rules []LifecycleRule, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("setting lifecycle for bucket '%s'", bucketName), func(replica Backend) error {
+		return replica.SetBucketLifecycle(bucketName, rules, ctx)
+	})
+}
+
+func (r *ReplicatedBackend) GetBucketLifecycle(bucketName string, ctx context.Context) ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	err := r.read(fmt.Sprintf("retrieving lifecycle for bucket '%s'", bucketName), func(replica Backend) error {
+		var err error
+		rules, err = replica.GetBucketLifecycle(bucketName, ctx)
+		return err
+	})
+	return rules, err
+}
+
+func (r *ReplicatedBackend) SetBucketTags(bucketName string, tags map[string]string, ctx context.Context) error {
+	return r.write(bucketName, fmt.Sprintf("setting tags for bucket '%s'", bucketName), func(replica Backend) error {
+		return replica.SetBucketTags(bucketName, tags, ctx)
+	})
+}
+
+func (r *ReplicatedBackend) GetBucketTags(bucketName string, ctx context.Context) (map[string]string, error) {
+	var tags map[string]string
+	err := r.read(fmt.Sprintf("retrieving tags for bucket '%s'", bucketName), func(replica Backend) error {
+		var err error
+		tags, err = replica.GetBucketTags(bucketName, ctx)
+		return err
+	})
+	return tags, err
+}
+
+func (r *ReplicatedBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	// the content is read once and sent to every replica
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return r.write(objectKey(bucketName, objectName), fmt.Sprintf("uploading object '%s' to bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		return replica.PutObject(bucketName, objectName, bytes.NewReader(data), int64(len(data)), opts, ctx)
+	})
+}
+
+// GetObject returns the object from the first replica holding it.
+func (r *ReplicatedBackend) GetObject(bucketName string, objectName string, ctx context.Context) (io.ReadCloser, ObjectInfo, error) {
+	var object io.ReadCloser
+	var info ObjectInfo
+	err := r.read(fmt.Sprintf("retrieving object '%s' from bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		var err error
+		object, info, err = replica.GetObject(bucketName, objectName, ctx)
+		return err
+	})
+	return object, info, err
+}
+
+func (r *ReplicatedBackend) StatObject(bucketName string, objectName string, ctx context.Context) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := r.read(fmt.Sprintf("retrieving object '%s' info from bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		var err error
+		info, err = replica.StatObject(bucketName, objectName, ctx)
+		return err
+	})
+	return info, err
+}
+
+// PresignGetObject presigns the URL on the first replica holding the object.
+func (r *ReplicatedBackend) PresignGetObject(bucketName string, objectName string, expiry time.Duration, headers url.Values, ctx context.Context) (*url.URL, error) {
+	var presignedURL *url.URL
+	err := r.read(fmt.Sprintf("presigning object '%s' of bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		presigner, ok := replica.(presigner)
+		if !ok {
+			return ErrPresignNotSupported
+		}
+		_, err := replica.StatObject(bucketName, objectName, ctx)
+		if err != nil {
+			return err
+		}
+		presignedURL, err = presigner.PresignGetObject(bucketName, objectName, expiry, headers, ctx)
+		return err
+	})
+	return presignedURL, err
+}
+
+func (r *ReplicatedBackend) DeleteObject(bucketName string, objectName string, ctx context.Context) error {
+	return r.write(objectKey(bucketName, objectName), fmt.Sprintf("deleting object '%s' from bucket '%s'", objectName, bucketName), func(replica Backend) error {
+		return replica.DeleteObject(bucketName, objectName, ctx)
+	})
+}
+
+// ListObjects returns the union of the objects held by the reachable replicas.
+func (r *ReplicatedBackend) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	return r.listObjects(bucketName, func(replica Backend) ([]ObjectInfo, error) {
+		return replica.ListObjects(bucketName, ctx)
+	})
+}
+
+// ListObjectsWithMetadata returns the union of the objects held by the reachable replicas, with their metadata
+// when the replicas return it along with the listing.
+func (r *ReplicatedBackend) ListObjectsWithMetadata(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	return r.listObjects(bucketName, func(replica Backend) ([]ObjectInfo, error) {
+		if lister, ok := replica.(metadataLister); ok {
+			return lister.ListObjectsWithMetadata(bucketName, ctx)
+		}
+		return replica.ListObjects(bucketName, ctx)
+	})
+}
+
+func (r *ReplicatedBackend) listObjects(bucketName string, list func(replica Backend) ([]ObjectInfo, error)) ([]ObjectInfo, error) {
+	var lastErr error
+	reachable := 0
+	seen := make(map[string]int)
+	var objects []ObjectInfo
+	for i, replica := range r.replicas {
+		replicaObjects, err := list(replica)
+		if err != nil {
+			r.WrappedLogger.LogWarnf("Replica '%s' failed listing bucket '%s', error: %s", r.names[i], bucketName, err)
+			lastErr = err
+			continue
+		}
+		reachable++
+		for _, object := range replicaObjects {
+			if index, ok := seen[object.Key]; ok {
+				if object.LastModified.After(objects[index].LastModified) {
+					objects[index] = object
+				}
+				continue
+			}
+			seen[object.Key] = len(objects)
+			objects = append(objects, object)
+		}
+	}
+	if reachable == 0 {
+		return nil, lastErr
+	}
+	return objects, nil
+}
+
+// objectKey returns the key under which the writes of an object are ordered.
+func objectKey(bucketName string, objectName string) string {
+	return bucketName + "/" + objectName
+}
+
+// write runs the operation on every replica concurrently and returns as soon as the write quorum is reached,
+// or as soon as it can no longer be reached. The slower replicas complete the operation in background, after the previous writes
+// of the key on the same replica, and the replicas failing an acknowledged write retry it until it is acknowledged everywhere.
+func (r *ReplicatedBackend) write(key string, operation string, fn func(replica Backend) error) error {
+	type result struct {
+		replica int
+		err     error
+	}
+
+	// the writes of the key are queued on every replica at once, so that every replica runs them in the same order
+	r.writesMutex.Lock()
+	previous := make([]chan struct{}, len(r.replicas))
+	done := make([]chan struct{}, len(r.replicas))
+	for i := range r.replicas {
+		previous[i] = r.lastWrites[i][key]
+		done[i] = make(chan struct{})
+		r.lastWrites[i][key] = done[i]
+	}
+	r.writesMutex.Unlock()
+
+	// buffered so that the slower replicas never block once the outcome is known
+	results := make(chan result, len(r.replicas))
+	acknowledged := make(chan bool, len(r.replicas))
+	r.pending.Add(len(r.replicas))
+	for i, replica := range r.replicas {
+		go func(i int, replica Backend) {
+			defer r.pending.Done()
+			defer r.endWrite(i, key, done[i])
+			if previous[i] != nil {
+				<-previous[i]
+			}
+			err := fn(replica)
+			results <- result{replica: i, err: err}
+			if err == nil {
+				return
+			}
+			r.WrappedLogger.LogWarnf("Replica '%s' failed %s, error: %s", r.names[i], operation, err)
+			// the write is retried only once the other replicas acknowledged it, the next writes of the key waiting for it
+			if <-acknowledged {
+				r.retryWrite(i, replica, operation, fn)
+			}
+		}(i, replica)
+	}
+
+	quorumErr := &QuorumError{Operation: operation, Quorum: r.writeQuorum}
+	for range r.replicas {
+		res := <-results
+		if res.err != nil {
+			quorumErr.Replicas = append(quorumErr.Replicas, r.names[res.replica])
+			quorumErr.Errors = append(quorumErr.Errors, res.err)
+		} else {
+			quorumErr.Acknowledged++
+		}
+
+		if quorumErr.Acknowledged >= r.writeQuorum || len(r.replicas)-len(quorumErr.Errors) < r.writeQuorum {
+			break
+		}
+	}
+
+	success := quorumErr.Acknowledged >= r.writeQuorum
+	for range r.replicas {
+		acknowledged <- success
+	}
+	if success {
+		return nil
+	}
+	return quorumErr
+}
+
+// retryWrite runs again the write failed by the replica, until it succeeds or the retries are exhausted.
+func (r *ReplicatedBackend) retryWrite(i int, replica Backend, operation string, fn func(replica Backend) error) {
+	backoff := r.retryBackoff
+	var err error
+	for retry := 1; retry <= replicaRetries; retry++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = fn(replica)
+		if err == nil {
+			r.WrappedLogger.LogInfof("Replica '%s' completed %s after %d retries", r.names[i], operation, retry)
+			return
+		}
+	}
+	r.WrappedLogger.LogErrorf("Replica '%s' failed %s after %d retries, it diverges from the other replicas until the object is written again or repaired, error: %w", r.names[i], operation, replicaRetries, err)
+}
+
+// endWrite lets the next write of the key run on the replica, and forgets the write if it is the last one.
+func (r *ReplicatedBackend) endWrite(i int, key string, done chan struct{}) {
+	close(done)
+	r.writesMutex.Lock()
+	defer r.writesMutex.Unlock()
+	if r.lastWrites[i][key] == done {
+		delete(r.lastWrites[i], key)
+	}
+}
+
+// QuorumError is returned when a write is acknowledged by fewer replicas than the write quorum.
+// It matches an error with errors.Is when every failed replica failed with it, e.g. when the bucket is missing everywhere.
+type QuorumError struct {
+	Operation    string
+	Acknowledged int
+	Quorum       int
+	// Replicas are the names of the failed replicas, and Errors their errors
+	Replicas []string
+	Errors   []error
+}
+
+func (e *QuorumError) Error() string {
+	failures := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		failures[i] = fmt.Sprintf("%s: %s", e.Replicas[i], err)
+	}
+	return fmt.Sprintf("%s acknowledged by %d replicas, quorum is %d (%s)", e.Operation, e.Acknowledged, e.Quorum, strings.Join(failures, "; "))
+}
+
+func (e *QuorumError) Is(target error) bool {
+	if len(e.Errors) == 0 {
+		return false
+	}
+	for _, err := range e.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// read runs the operation on the replicas in order until one of them succeeds.
+func (r *ReplicatedBackend) read(operation string, fn func(replica Backend) error) error {
+	var lastErr error
+	for i, replica := range r.replicas {
+		err := fn(replica)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		r.WrappedLogger.LogWarnf("Replica '%s' failed %s, error: %s", r.names[i], operation, err)
+		lastErr = err
+	}
+	// the object is reported as missing only if every replica answered it is missing
+	if lastErr != nil {
+		return lastErr
+	}
+	return ErrObjectNotFound
+}
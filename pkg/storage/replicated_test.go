@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+// failingBackend is a memory backend whose uploads fail with err, when it is set.
+type failingBackend struct {
+	*MemoryBackend
+	err error
+}
+
+func (b *failingBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.MemoryBackend.PutObject(bucketName, objectName, reader, size, opts, ctx)
+}
+
+// gatedBackend is a memory backend whose uploads wait for release to be closed.
+type gatedBackend struct {
+	*MemoryBackend
+	release chan struct{}
+}
+
+func (b *gatedBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	<-b.release
+	return b.MemoryBackend.PutObject(bucketName, objectName, reader, size, opts, ctx)
+}
+
+// flakyBackend is a memory backend failing its first uploads, as many as failures.
+type flakyBackend struct {
+	*MemoryBackend
+	failures int32
+}
+
+func (b *flakyBackend) PutObject(bucketName string, objectName string, reader io.Reader, size int64, opts PutOptions, ctx context.Context) error {
+	if atomic.AddInt32(&b.failures, -1) >= 0 {
+		return errors.New("replica unreachable")
+	}
+	return b.MemoryBackend.PutObject(bucketName, objectName, reader, size, opts, ctx)
+}
+
+// newTestReplicas returns memory backends with the default bucket created.
+func newTestReplicas(t *testing.T, count int) []*MemoryBackend {
+	t.Helper()
+	replicas := make([]*MemoryBackend, count)
+	for i := range replicas {
+		replicas[i] = NewMemoryBackend()
+		if err := replicas[i].CreateBucket("default", context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return replicas
+}
+
+func TestReplicatedWriteOrder(t *testing.T) {
+	ctx := context.Background()
+	memories := newTestReplicas(t, 3)
+	slow := &gatedBackend{MemoryBackend: memories[2], release: make(chan struct{})}
+	backend, err := NewReplicatedBackend([]Backend{memories[0], memories[1], slow}, []string{"a", "b", "slow"}, 2, logger.NewWrappedLogger(logger.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.PutObject("default", "object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.DeleteObject("default", "object", ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the deletion must not overtake the upload still waiting on the slow replica
+	close(slow.release)
+	backend.pending.Wait()
+
+	for i, memory := range memories {
+		if _, err := memory.StatObject("default", "object", ctx); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("replica %d: error is %v, want %v", i, err, ErrObjectNotFound)
+		}
+	}
+	if len(backend.lastWrites[2]) != 0 {
+		t.Errorf("%d writes are still queued on the slow replica", len(backend.lastWrites[2]))
+	}
+}
+
+func TestReplicatedWriteRetry(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		failures   int32
+		quorum     int
+		wantErr    bool
+		wantStored bool
+	}{
+		{name: "acknowledged write is retried", failures: 2, quorum: 1, wantStored: true},
+		{name: "retries exhausted", failures: replicaRetries + 1, quorum: 1},
+		{name: "failed write is not retried", failures: 1, quorum: 2, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			memories := newTestReplicas(t, 2)
+			flaky := &flakyBackend{MemoryBackend: memories[1], failures: test.failures}
+			backend, err := NewReplicatedBackend([]Backend{memories[0], flaky}, []string{"a", "flaky"}, test.quorum, logger.NewWrappedLogger(logger.NewNopLogger()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend.retryBackoff = time.Millisecond
+
+			err = backend.PutObject("default", "object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error is %v, want an error: %t", err, test.wantErr)
+			}
+			backend.pending.Wait()
+
+			_, err = memories[1].StatObject("default", "object", ctx)
+			if stored := err == nil; stored != test.wantStored {
+				t.Errorf("flaky replica holds the object: %t, want %t", stored, test.wantStored)
+			}
+		})
+	}
+}
+
+func TestReplicatedWriteQuorum(t *testing.T) {
+	errUnreachable := errors.New("replica unreachable")
+	tests := []struct {
+		name     string
+		failures []error
+		quorum   int
+		wantErr  bool
+		// wantIs is a sentinel the error must match, when every failure matches it
+		wantIs error
+	}{
+		{name: "every replica acknowledges", failures: []error{nil, nil, nil}, quorum: 0},
+		{name: "quorum of all with a failure", failures: []error{nil, errUnreachable, nil}, quorum: 0, wantErr: true, wantIs: errUnreachable},
+		{name: "quorum reached despite a failure", failures: []error{nil, errUnreachable, nil}, quorum: 2},
+		{name: "quorum reached despite two failures", failures: []error{errUnreachable, errUnreachable, nil}, quorum: 1},
+		{name: "quorum missed", failures: []error{errUnreachable, nil, errUnreachable}, quorum: 2, wantErr: true, wantIs: errUnreachable},
+		{name: "every replica fails", failures: []error{ErrBucketNotFound, ErrBucketNotFound}, quorum: 1, wantErr: true, wantIs: ErrBucketNotFound},
+		{name: "mixed failures", failures: []error{ErrBucketNotFound, errUnreachable}, quorum: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			replicas := make([]Backend, len(test.failures))
+			names := make([]string, len(test.failures))
+			working := 0
+			for i, failure := range test.failures {
+				memory := NewMemoryBackend()
+				if err := memory.CreateBucket("default", ctx); err != nil {
+					t.Fatal(err)
+				}
+				replicas[i] = memory
+				if failure != nil {
+					replicas[i] = &failingBackend{MemoryBackend: memory, err: failure}
+				} else {
+					working++
+				}
+				names[i] = string(rune('a' + i))
+			}
+			backend, err := NewReplicatedBackend(replicas, names, test.quorum, logger.NewWrappedLogger(logger.NewNopLogger()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = backend.PutObject("default", "object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error is %v, want an error: %t", err, test.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			var quorumErr *QuorumError
+			if !errors.As(err, &quorumErr) {
+				t.Fatalf("error %v is not a QuorumError", err)
+			}
+			wantQuorum := test.quorum
+			if wantQuorum == 0 {
+				wantQuorum = len(test.failures)
+			}
+			if quorumErr.Quorum != wantQuorum {
+				t.Errorf("quorum is %d, want %d", quorumErr.Quorum, wantQuorum)
+			}
+			if quorumErr.Acknowledged > working || quorumErr.Acknowledged >= wantQuorum {
+				t.Errorf("%d replicas acknowledged, %d work and the quorum is %d", quorumErr.Acknowledged, working, wantQuorum)
+			}
+			if len(quorumErr.Replicas) != len(quorumErr.Errors) || len(quorumErr.Errors) == 0 {
+				t.Errorf("%d failed replicas for %d errors", len(quorumErr.Replicas), len(quorumErr.Errors))
+			}
+			if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+				t.Errorf("error %v doesn't match %v", err, test.wantIs)
+			}
+			if test.wantIs == nil && (errors.Is(err, ErrBucketNotFound) || errors.Is(err, errUnreachable)) {
+				t.Errorf("error %v matches the error of only some replicas", err)
+			}
+		})
+	}
+}
+
+func TestReplicatedReadFallsBack(t *testing.T) {
+	ctx := context.Background()
+	empty, holding := NewMemoryBackend(), NewMemoryBackend()
+	for _, memory := range []*MemoryBackend{empty, holding} {
+		if err := memory.CreateBucket("default", ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := holding.PutObject("default", "object", bytes.NewReader([]byte("data")), 4, PutOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewReplicatedBackend([]Backend{empty, holding}, []string{"empty", "holding"}, 1, logger.NewWrappedLogger(logger.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _, err := backend.GetObject("default", "object", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+	if _, err := backend.StatObject("default", "missing", ctx); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error is %v, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestNewReplicatedBackend(t *testing.T) {
+	log := logger.NewWrappedLogger(logger.NewNopLogger())
+	replicas := []Backend{NewMemoryBackend(), NewMemoryBackend()}
+	tests := []struct {
+		name       string
+		replicas   []Backend
+		names      []string
+		quorum     int
+		wantQuorum int
+		wantErr    bool
+	}{
+		{name: "default quorum is every replica", replicas: replicas, names: []string{"a", "b"}, quorum: 0, wantQuorum: 2},
+		{name: "explicit quorum", replicas: replicas, names: []string{"a", "b"}, quorum: 1, wantQuorum: 1},
+		{name: "quorum above the replicas", replicas: replicas, names: []string{"a", "b"}, quorum: 3, wantErr: true},
+		{name: "missing names", replicas: replicas, names: []string{"a"}, wantErr: true},
+		{name: "no replicas", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, err := NewReplicatedBackend(test.replicas, test.names, test.quorum, log)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error is %v, want an error: %t", err, test.wantErr)
+			}
+			if err == nil && backend.writeQuorum != test.wantQuorum {
+				t.Errorf("write quorum is %d, want %d", backend.writeQuorum, test.wantQuorum)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The problems found scrubbing a bucket.
+const (
+	// ScrubMissing is an index or catalog entry pointing to a missing object
+	ScrubMissing = "missing"
+	// ScrubCorrupt is an object that can't be read, decrypted, decompressed or decoded, or that doesn't match its checksum
+	ScrubCorrupt = "corrupt"
+	// ScrubMismatched is an object holding another block than the one it is stored for, or a proof of inclusion not containing it
+	ScrubMismatched = "mismatched"
+	// ScrubMissingProof is an object stored with a proof of inclusion that holds none
+	ScrubMissingProof = "missing-proof"
+)
+
+// ScrubTarget is an object to be checked by ScrubObject.
+type ScrubTarget struct {
+	Key     string
+	BlockId string
+}
+
+// ScrubIssue is a problem found in an object of a bucket.
+type ScrubIssue struct {
+	BlockId string `json:"blockId"`
+	Key     string `json:"key"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+	// WithPOI tells whether the object was stored with a proof of inclusion
+	WithPOI     bool   `json:"withPOI"`
+	FilterId    string `json:"filterId,omitempty"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError,omitempty"`
+}
+
+// ScrubTargets returns the objects of the bucket to be checked with ScrubObject,
+// together with the issues of the index and catalog entries pointing to missing objects.
+func (s *Storage) ScrubTargets(bucketName string, ctx context.Context) ([]ScrubTarget, []ScrubIssue, error) {
+	since := time.Now()
+	objects, err := s.backend.ListObjects(bucketName, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexed, err := s.indexedObjects(bucketName, objects, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := s.backend.GetBucketLifecycle(bucketName, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := make(map[string]struct{}, len(objects))
+	indexEntries := make(map[string]ObjectInfo)
+	targets := make([]ScrubTarget, 0, len(objects))
+	for _, object := range objects {
+		if isReservedKey(object.Key) {
+			indexEntries[object.Key] = object
+			continue
+		}
+		stored[object.Key] = struct{}{}
+		// the block id recorded in the metadata is only known once the object is read
+		blockId := indexed[object.Key]
+		if blockId == "" {
+			blockId = strings.TrimSuffix(object.Key, s.objectExtension)
+		}
+		targets = append(targets, ScrubTarget{Key: object.Key, BlockId: blockId})
+	}
+
+	var issues []ScrubIssue
+	reported := make(map[string]struct{})
+	for objectName, blockId := range indexed {
+		if _, ok := stored[objectName]; ok {
+			continue
+		}
+		// the object expired through a lifecycle rule matching its key but not its index entry, which is deleted instead of repaired
+		entry := indexEntries[indexKey(blockId)]
+		if isObjectExpired(rules, ObjectInfo{Key: objectName, Tags: entry.Tags, LastModified: entry.LastModified}) {
+			s.dropIndex(bucketName, blockId, ctx)
+			s.catalogDelete(bucketName, blockId)
+			reported[blockId] = struct{}{}
+			continue
+		}
+		issues = append(issues, ScrubIssue{BlockId: blockId, Key: objectName, Problem: ScrubMissing, Detail: "the index points to a missing object"})
+		reported[blockId] = struct{}{}
+	}
+
+	if s.catalog != nil {
+		var expired []string
+		err = s.catalog.ForEach(bucketName, func(entry CatalogEntry) error {
+			if _, ok := stored[entry.Key]; ok {
+				return nil
+			}
+			if _, ok := reported[entry.BlockId]; ok {
+				return nil
+			}
+			// the entries of the objects uploaded while the bucket was being listed are not checked
+			if !entry.LastModified.Before(since) {
+				return nil
+			}
+			// the catalog doesn't see the objects expired by the lifecycle of the storage, their entries are dropped
+			if isObjectExpired(rules, ObjectInfo{Key: entry.Key, Tags: entry.tags(), LastModified: entry.LastModified}) {
+				expired = append(expired, entry.BlockId)
+				return nil
+			}
+			issues = append(issues, ScrubIssue{
+				BlockId:  entry.BlockId,
+				Key:      entry.Key,
+				Problem:  ScrubMissing,
+				Detail:   "the catalog records a missing object",
+				WithPOI:  entry.POI,
+				FilterId: entry.FilterId,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, blockId := range expired {
+			s.catalogDelete(bucketName, blockId)
+		}
+	}
+
+	return targets, issues, nil
+}
+
+// ScrubObject checks that the object can be read and decoded, that it holds the block it is stored for,
+// and that it holds a proof of inclusion of the block when it was stored with one. It returns nil if the object is sound.
+func (s *Storage) ScrubObject(bucketName string, target ScrubTarget, ctx context.Context) (*ScrubIssue, error) {
+	reader, info, err := s.backend.GetObject(bucketName, target.Key, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		// the object was deleted after the bucket was listed
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	issue := &ScrubIssue{
+		BlockId:  s.storedBlockId(target.Key, info, map[string]string{target.Key: target.BlockId}),
+		Key:      target.Key,
+		FilterId: info.Metadata[metadataFilterId],
+	}
+	issue.WithPOI, _ = strconv.ParseBool(info.Metadata[metadataPOI])
+	corrupt := func(detail string) (*ScrubIssue, error) {
+		issue.Problem = ScrubCorrupt
+		issue.Detail = detail
+		return issue, nil
+	}
+
+	decoded, err := s.decodeObject(bucketName, reader, info, ctx)
+	if err != nil {
+		return corrupt(err.Error())
+	}
+	data, err := io.ReadAll(decoded)
+	decoded.Close()
+	if err != nil {
+		return corrupt(err.Error())
+	}
+	if checksum := storedChecksum(info.Metadata); checksum != "" {
+		computed := contentHash(data)
+		if _, keyed := info.Metadata[metadataContentHMAC]; keyed {
+			computed, err = s.keyedChecksum(bucketName, data, ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if checksum != computed {
+			return corrupt("the content doesn't match its checksum")
+		}
+	}
+
+	object, err := DecodeObject(bytes.NewReader(data), info.Metadata[metadataFormat])
+	if err != nil {
+		return corrupt(err.Error())
+	}
+	if object.Block == nil {
+		return corrupt("the object holds no block")
+	}
+	blockId, err := object.Block.ID()
+	if err != nil {
+		return corrupt(err.Error())
+	}
+
+	if hex.EncodeToString(blockId[:]) != strings.TrimPrefix(strings.ToLower(issue.BlockId), "0x") {
+		issue.Problem = ScrubMismatched
+		issue.Detail = fmt.Sprintf("the object holds block '%s'", hex.EncodeToString(blockId[:]))
+		return issue, nil
+	}
+	if object.Proof == nil {
+		if issue.WithPOI {
+			issue.Problem = ScrubMissingProof
+			issue.Detail = "the object was stored with a proof of inclusion but holds none"
+			return issue, nil
+		}
+		return nil, nil
+	}
+	if contains, _ := object.Proof.ContainsValue(blockId); !contains {
+		issue.Problem = ScrubMismatched
+		issue.Detail = "the proof of inclusion doesn't contain the block"
+		return issue, nil
+	}
+	return nil, nil
+}
+
+// RepairObject stores the object under the given name, replacing the damaged or missing object found by a scrub.
+// Unlike UploadObject, the object is written whatever the bucket holds for the block.
+func (s *Storage) RepairObject(bucketName string, objectName string, attributes ObjectAttributes, object Object, ctx context.Context) error {
+	config, err := s.GetBucketConfig(bucketName, ctx)
+	if err != nil {
+		return err
+	}
+	attributes = completeAttributes(attributes, object)
+
+	data, err := encodeObject(object, config.Format)
+	if err != nil {
+		return err
+	}
+	hash, err := s.contentChecksum(bucketName, config, data, ctx)
+	if err != nil {
+		return err
+	}
+
+	unlock := s.uploadLocks.lock(bucketName, attributes.BlockId)
+	defer unlock()
+
+	data, opts, err := s.packObject(bucketName, config, attributes, object, data, hash, ctx)
+	if err != nil {
+		return err
+	}
+
+	s.WrappedLogger.LogInfof("Repairing object '%s' in bucket '%s' ...", objectName, bucketName)
+	err = s.backend.PutObject(bucketName, objectName, bytes.NewReader(data), int64(len(data)), opts, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Repairing object '%s' in bucket '%s' ... failed, error: %w", objectName, bucketName, err)
+		return err
+	}
+	if objectName != attributes.BlockId+s.objectExtension {
+		err = s.writeIndex(bucketName, attributes.BlockId, objectName, opts.Tags, ctx)
+		if err != nil {
+			s.WrappedLogger.LogErrorf("Repairing object '%s' in bucket '%s' ... failed, error: %w", objectName, bucketName, err)
+			return err
+		}
+	}
+
+	s.catalogPut(newCatalogEntry(bucketName, objectName, attributes, object, int64(len(data)), hash))
+	// the size of the replaced object is unknown, the usage is computed again
+	s.resetUsage(bucketName)
+
+	s.WrappedLogger.LogInfof("Repairing object '%s' in bucket '%s' ... done", objectName, bucketName)
+	return nil
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScrubObject(t *testing.T) {
+	block := newTestObject(t, "sensors", "temperature=21")
+	other := newTestObject(t, "sensors", "temperature=22")
+	tests := []struct {
+		name string
+		// damage overwrites the stored object of the block, if set
+		damage      func(t *testing.T, s *Storage, objectName string)
+		withPOI     bool
+		wantProblem string
+	}{
+		{name: "sound object"},
+		{name: "sound object with proof", withPOI: true},
+		{
+			name: "unreadable content",
+			damage: func(t *testing.T, s *Storage, objectName string) {
+				putTestContent(t, s, objectName, []byte("not json"), nil)
+			},
+			wantProblem: ScrubCorrupt,
+		},
+		{
+			name: "content not matching its checksum",
+			damage: func(t *testing.T, s *Storage, objectName string) {
+				putTestContent(t, s, objectName, encodeTestObject(t, block), map[string]string{metadataContentSHA256: "00"})
+			},
+			wantProblem: ScrubCorrupt,
+		},
+		{
+			name: "another block",
+			damage: func(t *testing.T, s *Storage, objectName string) {
+				putTestContent(t, s, objectName, encodeTestObject(t, other), nil)
+			},
+			wantProblem: ScrubMismatched,
+		},
+		{
+			name: "proof lost",
+			damage: func(t *testing.T, s *Storage, objectName string) {
+				putTestContent(t, s, objectName, encodeTestObject(t, block), map[string]string{metadataPOI: "true"})
+			},
+			withPOI:     true,
+			wantProblem: ScrubMissingProof,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorage(t, Parameters{})
+			object := block
+			if test.withPOI {
+				object = withTestProof(t, block)
+			}
+			attributes := newTestAttributes(t, object, 42)
+			if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if test.damage != nil {
+				test.damage(t, s, attributes.BlockId)
+			}
+
+			targets, issues, err := s.ScrubTargets("default", ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(targets) != 1 || targets[0].BlockId != attributes.BlockId || len(issues) != 0 {
+				t.Fatalf("targets are %v with issues %v, want only the block", targets, issues)
+			}
+			issue, err := s.ScrubObject("default", targets[0], ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.wantProblem == "" {
+				if issue != nil {
+					t.Errorf("issue is %+v, want none", issue)
+				}
+				return
+			}
+			if issue == nil || issue.Problem != test.wantProblem || issue.BlockId != attributes.BlockId {
+				t.Fatalf("issue is %+v, want %s", issue, test.wantProblem)
+			}
+			if issue.WithPOI != test.withPOI {
+				t.Errorf("issue with proof is %t, want %t", issue.WithPOI, test.withPOI)
+			}
+
+			// the object stored again from the block is sound
+			if err := s.RepairObject("default", issue.Key, attributes, object, ctx); err != nil {
+				t.Fatal(err)
+			}
+			if issue, err := s.ScrubObject("default", targets[0], ctx); err != nil || issue != nil {
+				t.Errorf("issue of the repaired object is %+v (%v), want none", issue, err)
+			}
+		})
+	}
+}
+
+func TestScrubTargetsMissing(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	s.catalog = newTestCatalog(t)
+	if err := s.SetBucketConfig("default", BucketConfig{KeyLayout: "{tag}/{blockId}"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.UploadObject(attributes, "default", object, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the object disappears behind the back of the collector, its index entry is left
+	objectName, err := s.ResolveObjectName("default", attributes.BlockId, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Backend().DeleteObject("default", objectName, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the catalog records an object which was never indexed
+	lost := CatalogEntry{BlockId: "0xlost", Bucket: "default", Key: "0xlost", POI: true, FilterId: "f1", LastModified: time.Now().Add(-time.Hour)}
+	if err := s.catalog.Put(lost); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, issues, err := s.ScrubTargets("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("targets are %v, want none", targets)
+	}
+	found := make(map[string]ScrubIssue)
+	for _, issue := range issues {
+		if issue.Problem != ScrubMissing {
+			t.Errorf("issue is %+v, want %s", issue, ScrubMissing)
+		}
+		found[issue.BlockId] = issue
+	}
+	if len(found) != 2 {
+		t.Fatalf("issues are %v, want one for the indexed and one for the cataloged object", issues)
+	}
+	if issue := found[attributes.BlockId]; issue.Key != objectName {
+		t.Errorf("issue of the indexed object is %+v, want key '%s'", issue, objectName)
+	}
+	if issue := found[lost.BlockId]; !issue.WithPOI || issue.FilterId != lost.FilterId {
+		t.Errorf("issue of the cataloged object is %+v, want it stored with proof by f1", issue)
+	}
+
+	// a missing object is stored again under its name
+	if err := s.RepairObject("default", objectName, attributes, object, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reader, _, err := s.GetObject("default", attributes.BlockId, ctx); err != nil {
+		t.Errorf("repaired object can't be read, error: %s", err)
+	} else {
+		reader.Close()
+	}
+}
+
+func TestScrubTargetsExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, Parameters{})
+	s.catalog = newTestCatalog(t)
+	if err := s.SetBucketLifecycle("default", []LifecycleRule{{Id: BucketLifecycleRuleId, Days: 1}}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the entry of an object expired by the lifecycle of the storage is dropped, not reported
+	expired := CatalogEntry{BlockId: "0xexpired", Bucket: "default", Key: "0xexpired", LastModified: time.Now().Add(-48 * time.Hour)}
+	if err := s.catalog.Put(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	_, issues, err := s.ScrubTargets("default", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("issues are %v, want none", issues)
+	}
+	if _, err := s.catalog.Get("default", expired.BlockId); err == nil {
+		t.Error("entry of the expired object is kept")
+	}
+}
+
+// encodeTestObject returns the JSON serialization of the object.
+func encodeTestObject(t *testing.T, object Object) []byte {
+	t.Helper()
+	data, err := encodeObject(object, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// putTestContent overwrites the object of the default bucket with the content and metadata, bypassing the storage.
+func putTestContent(t *testing.T, s *Storage, objectName string, data []byte, metadata map[string]string) {
+	t.Helper()
+	err := s.Backend().PutObject("default", objectName, bytes.NewReader(data), int64(len(data)), PutOptions{Metadata: metadata}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// spoolBucket is the bbolt bucket holding the spooled uploads, keyed by their sequence number.
+var spoolBucket = []byte("uploads")
+
+// spoolDueBucket indexes the spooled uploads by the time of their next attempt, keyed by that time followed by their sequence number,
+// so that the due uploads are found without reading the whole spool.
+var spoolDueBucket = []byte("due")
+
+// spoolPollInterval is how often the spool looks for the uploads due to be retried.
+const spoolPollInterval = time.Second
+
+// spoolEntry is an upload waiting in the spool.
+type spoolEntry struct {
+	Bucket      string           `json:"bucket"`
+	Attributes  ObjectAttributes `json:"attributes"`
+	Object      Object           `json:"object"`
+	Attempts    int              `json:"attempts"`
+	EnqueuedAt  time.Time        `json:"enqueuedAt"`
+	NextAttempt time.Time        `json:"nextAttempt"`
+	LastError   string           `json:"lastError,omitempty"`
+}
+
+// SpoolStats describes the uploads waiting in the spool.
+type SpoolStats struct {
+	Depth int `json:"depth"`
+	// OldestEnqueuedAt is when the oldest waiting upload entered the spool
+	OldestEnqueuedAt *time.Time `json:"oldestEnqueuedAt,omitempty"`
+	// AgeSeconds is how long the oldest waiting upload has been in the spool
+	AgeSeconds int64 `json:"ageSeconds"`
+	// Retrying is the number of uploads that failed at least once
+	Retrying  int    `json:"retrying"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Spool is a queue of failed uploads kept in an embedded bbolt database: the objects whose upload failed are persisted locally
+// and retried until they succeed, across restarts.
+type Spool struct {
+	db *bolt.DB
+}
+
+// OpenSpool opens the spool kept in the given file, creating it if needed.
+func OpenSpool(path string) (*Spool, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open spool '%s', error: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		uploads, err := tx.CreateBucketIfNotExists(spoolBucket)
+		if err != nil {
+			return err
+		}
+		if tx.Bucket(spoolDueBucket) != nil {
+			return nil
+		}
+		// the uploads spooled before the index existed are indexed once
+		due, err := tx.CreateBucket(spoolDueBucket)
+		if err != nil {
+			return err
+		}
+		return uploads.ForEach(func(key []byte, value []byte) error {
+			var entry spoolEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			return due.Put(spoolDueKey(entry.NextAttempt, binary.BigEndian.Uint64(key)), nil)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Spool{db: db}, nil
+}
+
+func (p *Spool) Close() error {
+	return p.db.Close()
+}
+
+func (p *Spool) enqueue(entry spoolEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return p.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(spoolBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(spoolKey(id), value); err != nil {
+			return err
+		}
+		return tx.Bucket(spoolDueBucket).Put(spoolDueKey(entry.NextAttempt, id), nil)
+	})
+}
+
+// reschedule replaces the upload, due at previousAttempt, with the entry.
+func (p *Spool) reschedule(id uint64, previousAttempt time.Time, entry spoolEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return p.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(spoolBucket).Put(spoolKey(id), value); err != nil {
+			return err
+		}
+		due := tx.Bucket(spoolDueBucket)
+		if err := due.Delete(spoolDueKey(previousAttempt, id)); err != nil {
+			return err
+		}
+		return due.Put(spoolDueKey(entry.NextAttempt, id), nil)
+	})
+}
+
+func (p *Spool) remove(id uint64, entry spoolEntry) error {
+	return p.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(spoolBucket).Delete(spoolKey(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(spoolDueBucket).Delete(spoolDueKey(entry.NextAttempt, id))
+	})
+}
+
+// due calls fn for every upload whose next attempt is not after now, the earliest first, until fn returns false.
+func (p *Spool) due(now time.Time, fn func(id uint64, entry spoolEntry) bool) error {
+	return p.db.View(func(tx *bolt.Tx) error {
+		uploads := tx.Bucket(spoolBucket)
+		cursor := tx.Bucket(spoolDueBucket).Cursor()
+		end := spoolDueKey(now, ^uint64(0))
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, end) <= 0; key, _ = cursor.Next() {
+			id := binary.BigEndian.Uint64(key[8:])
+			value := uploads.Get(spoolKey(id))
+			if value == nil {
+				continue
+			}
+			var entry spoolEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			if !fn(id, entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// forEach calls fn for every waiting upload, in the order they entered the spool, until fn returns false.
+func (p *Spool) forEach(fn func(id uint64, entry spoolEntry) bool) error {
+	return p.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(spoolBucket).Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			var entry spoolEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			if !fn(binary.BigEndian.Uint64(key), entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// Stats returns the depth and the age of the spool.
+func (p *Spool) Stats() (SpoolStats, error) {
+	var stats SpoolStats
+	var lastAttempt time.Time
+	err := p.forEach(func(_ uint64, entry spoolEntry) bool {
+		stats.Depth++
+		if stats.OldestEnqueuedAt == nil {
+			enqueuedAt := entry.EnqueuedAt
+			stats.OldestEnqueuedAt = &enqueuedAt
+			stats.AgeSeconds = int64(time.Since(enqueuedAt).Seconds())
+		}
+		if entry.Attempts > 0 {
+			stats.Retrying++
+			if entry.NextAttempt.After(lastAttempt) {
+				lastAttempt = entry.NextAttempt
+				stats.LastError = entry.LastError
+			}
+		}
+		return true
+	})
+	return stats, err
+}
+
+func spoolKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func spoolDueKey(nextAttempt time.Time, id uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(nextAttempt.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], id)
+	return key
+}
+
+// retryable reports whether a failed upload may succeed later, the uploads refused by the bucket are not retried.
+func retryable(err error) bool {
+	return !errors.Is(err, ErrQuotaExceeded) && !errors.Is(err, ErrObjectLocked) && !errors.Is(err, ErrBucketNotFound)
+}
+
+// StoreObject uploads the object and, if the spool is enabled and the upload fails, persists the object in the spool,
+// from which the upload is retried in background with exponential backoff, so the error is only logged.
+// Without spool it is the same as UploadObject.
+func (s *Storage) StoreObject(attributes ObjectAttributes, bucketName string, object Object, ctx context.Context) error {
+	err := s.UploadObject(attributes, bucketName, object, ctx)
+	if err == nil || s.spool == nil || !retryable(err) {
+		return err
+	}
+
+	entry := spoolEntry{
+		Bucket:      bucketName,
+		Attributes:  attributes,
+		Object:      object,
+		Attempts:    1,
+		EnqueuedAt:  time.Now(),
+		NextAttempt: time.Now().Add(s.spoolMinBackoff),
+		LastError:   err.Error(),
+	}
+	if spoolErr := s.spool.enqueue(entry); spoolErr != nil {
+		s.WrappedLogger.LogErrorf("Can't spool block '%s' for bucket '%s', error: %w", attributes.BlockId, bucketName, spoolErr)
+		return err
+	}
+	s.WrappedLogger.LogWarnf("Can't upload block '%s' to bucket '%s', retrying in %s, error: %s", attributes.BlockId, bucketName, s.spoolMinBackoff, err)
+	return nil
+}
+
+// SpoolStats returns the depth and the age of the spool.
+func (s *Storage) SpoolStats() (SpoolStats, error) {
+	if s.spool == nil {
+		return SpoolStats{}, fmt.Errorf("the spool is disabled")
+	}
+	return s.spool.Stats()
+}
+
+// uploadSpooled attempts the spooled upload, and removes it from the spool unless it has to be retried.
+func (s *Storage) uploadSpooled(id uint64, entry spoolEntry, ctx context.Context) {
+	err := s.UploadObject(entry.Attributes, entry.Bucket, entry.Object, ctx)
+	if err != nil && retryable(err) {
+		previousAttempt := entry.NextAttempt
+		entry.Attempts++
+		entry.LastError = err.Error()
+		backoff := s.spoolBackoff(entry.Attempts)
+		entry.NextAttempt = time.Now().Add(backoff)
+		s.WrappedLogger.LogWarnf("Can't upload block '%s' to bucket '%s', retrying in %s, error: %s", entry.Attributes.BlockId, entry.Bucket, backoff, err)
+		if err := s.spool.reschedule(id, previousAttempt, entry); err != nil {
+			s.WrappedLogger.LogErrorf("Can't update spooled block '%s' for bucket '%s', error: %w", entry.Attributes.BlockId, entry.Bucket, err)
+		}
+		return
+	}
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Dropping spooled block '%s' for bucket '%s', error: %w", entry.Attributes.BlockId, entry.Bucket, err)
+	}
+	if err := s.spool.remove(id, entry); err != nil {
+		s.WrappedLogger.LogErrorf("Can't remove spooled block '%s' for bucket '%s', error: %w", entry.Attributes.BlockId, entry.Bucket, err)
+	}
+}
+
+// spoolBackoff returns the delay before the next attempt of an upload that failed the given number of times.
+func (s *Storage) spoolBackoff(attempts int) time.Duration {
+	backoff := s.spoolMinBackoff
+	for i := 1; i < attempts && backoff < s.spoolMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.spoolMaxBackoff {
+		return s.spoolMaxBackoff
+	}
+	return backoff
+}
+
+// runSpool retries the spooled uploads when they are due, until the context is canceled.
+func (s *Storage) runSpool(ctx context.Context) {
+	ticker := time.NewTicker(spoolPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.uploadDueSpooled(ctx)
+	}
+}
+
+// uploadDueSpooled attempts the spooled uploads which are due.
+func (s *Storage) uploadDueSpooled(ctx context.Context) {
+	type due struct {
+		id    uint64
+		entry spoolEntry
+	}
+	var uploads []due
+	err := s.spool.due(time.Now(), func(id uint64, entry spoolEntry) bool {
+		uploads = append(uploads, due{id: id, entry: entry})
+		return true
+	})
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Can't read the spool, error: %w", err)
+		return
+	}
+
+	for _, upload := range uploads {
+		if ctx.Err() != nil {
+			return
+		}
+		s.uploadSpooled(upload.id, upload.entry, ctx)
+	}
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/hive.go/core/logger"
+)
+
+// withTestSpool makes the storage spool its failed uploads in the file, and retry them as soon as they fail.
+// The spool is closed at the end of the test.
+func withTestSpool(t *testing.T, s *Storage, path string) *Storage {
+	t.Helper()
+	spool, err := OpenSpool(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	s.spool = spool
+	s.spoolMaxBackoff = time.Minute
+	return s
+}
+
+func TestStoreObjectSpool(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spool", "spool.db")
+	memory := NewMemoryBackend()
+	backend := &failingBackend{MemoryBackend: memory, err: errors.New("storage unreachable")}
+	s := withTestSpool(t, newTestStorageWithBackend(t, backend, Parameters{}), path)
+
+	object := newTestObject(t, "sensors", "temperature=21")
+	attributes := newTestAttributes(t, object, 42)
+	if err := s.StoreObject(attributes, "default", object, ctx); err != nil {
+		t.Fatalf("error is %v, want the failed upload spooled", err)
+	}
+	// the uploads refused by the bucket are not spooled
+	if err := s.StoreObject(attributes, "missing", object, ctx); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("error is %v, want %v", err, ErrBucketNotFound)
+	}
+	stats, err := s.SpoolStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Depth != 1 || stats.Retrying != 1 || stats.LastError != "storage unreachable" {
+		t.Errorf("spool stats are %+v, want one upload failed once", stats)
+	}
+
+	// the upload still fails when retried, and is rescheduled
+	s.uploadDueSpooled(ctx)
+	var entry spoolEntry
+	err = s.spool.forEach(func(_ uint64, spooled spoolEntry) bool {
+		entry = spooled
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Attempts != 2 || entry.Attributes.BlockId != attributes.BlockId {
+		t.Errorf("spooled upload is %+v, want the block attempted twice", entry)
+	}
+	if err := s.spool.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart with the storage back, the upload is retried from the spool file
+	restarted := NewStorageWithBackend(memory, Parameters{DefaultBucketName: "default"}, logger.NewWrappedLogger(logger.NewNopLogger()))
+	s = withTestSpool(t, restarted, path)
+	if stats, err := s.SpoolStats(); err != nil || stats.Depth != 1 {
+		t.Fatalf("spool stats after a restart are %+v (%v), want one upload", stats, err)
+	}
+	s.uploadDueSpooled(ctx)
+	if stats, err := s.SpoolStats(); err != nil || stats.Depth != 0 {
+		t.Errorf("spool stats are %+v (%v), want the spool empty", stats, err)
+	}
+	reader, _, err := s.GetObject("default", attributes.BlockId, ctx)
+	if err != nil {
+		t.Fatalf("spooled block is not stored, error: %s", err)
+	}
+	reader.Close()
+}
+
+func TestSpoolDue(t *testing.T) {
+	spool, err := OpenSpool(filepath.Join(t.TempDir(), "spool.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	now := time.Now()
+	for i, delay := range []time.Duration{time.Hour, -time.Minute, -time.Hour} {
+		entry := spoolEntry{Bucket: "default", Attributes: ObjectAttributes{BlockId: string(rune('a' + i))}, NextAttempt: now.Add(delay)}
+		if err := spool.enqueue(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the due uploads are returned, the earliest first
+	var blockIds []string
+	err = spool.due(now, func(_ uint64, entry spoolEntry) bool {
+		blockIds = append(blockIds, entry.Attributes.BlockId)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blockIds) != 2 || blockIds[0] != "c" || blockIds[1] != "b" {
+		t.Errorf("due uploads are %v, want c and b", blockIds)
+	}
+}
+
+func TestSpoolBackoff(t *testing.T) {
+	s := newTestStorage(t, Parameters{})
+	s.spoolMinBackoff = time.Second
+	s.spoolMaxBackoff = 10 * time.Second
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 5, want: 10 * time.Second},
+		{attempts: 100, want: 10 * time.Second},
+	}
+	for _, test := range tests {
+		if backoff := s.spoolBackoff(test.attempts); backoff != test.want {
+			t.Errorf("backoff after %d attempts is %s, want %s", test.attempts, backoff, test.want)
+		}
+	}
+}
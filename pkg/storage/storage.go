@@ -1,44 +1,152 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/iotaledger/hive.go/core/logger"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
-	"github.com/minio/minio-go/v7/pkg/lifecycle"
 )
 
 type Storage struct {
 	*logger.WrappedLogger
-	client                      *minio.Client
+	backend                     Backend
 	DefaultBucketName           string
 	DefaultBucketExpirationDays int
-	region                      string
-	objectExtension             string
+	DefaultBucketConfig         BucketConfig
+	// ScrubInterval is how often every bucket is scrubbed in background, 0 if never
+	ScrubInterval time.Duration
+	// ScrubRepair tells whether the background scrub repairs the damaged objects
+	ScrubRepair     bool
+	objectExtension string
+	instanceId      string
+	masterKeys      *MasterKeys
+	// bucketsMutex guards the caches of the bucket configurations and data keys, as well as the updates of the bucket tags
+	bucketsMutex  sync.RWMutex
+	bucketConfigs map[string]cachedBucketConfig
+	dataKeys      map[string]*bucketKeys
+	uploadLocks   uploadLocks
+	// lifecycleMutex serializes the updates of the bucket lifecycles
+	lifecycleMutex sync.Mutex
+	// usageMutex guards the usage tracked for the buckets with a quota, and the quota states of the buckets
+	usageMutex   sync.Mutex
+	usage        map[string]bucketUsage
+	bucketQuotas map[string]*bucketQuota
+	// catalog is the local metadata index of the stored objects, nil if it is disabled
+	catalog *Catalog
+	// spool persists the failed uploads until they succeed, nil if it is disabled
+	spool           *Spool
+	spoolMinBackoff time.Duration
+	spoolMaxBackoff time.Duration
 }
 
-func NewStorage(params Parameters, log *logger.WrappedLogger) (Storage, error) {
-
-	// Initialize minio client object.
-	client, err := minio.New(params.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(params.AccessKeyID, params.SecretAccessKey, ""),
-		Secure: params.Secure,
-	})
+func NewStorage(params Parameters, log *logger.WrappedLogger) (*Storage, error) {
+	backend, err := newBackend(params, log)
 	if err != nil {
-		return Storage{}, err
+		return nil, err
+	}
+	storage := NewStorageWithBackend(backend, params, log)
+
+	if params.Encryption.MasterKeyFile != "" {
+		storage.masterKeys, err = NewMasterKeys(params.Encryption.MasterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Catalog.Path != "" {
+		storage.catalog, err = OpenCatalog(params.Catalog.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Spool.Path != "" {
+		storage.spool, err = OpenSpool(params.Spool.Path)
+		if err != nil {
+			return nil, err
+		}
+		storage.spoolMinBackoff = params.Spool.MinBackoff
+		storage.spoolMaxBackoff = params.Spool.MaxBackoff
 	}
 
-	storage := Storage{
+	return storage, nil
+}
+
+// NewStorageWithBackend returns a Storage that keeps the objects in the given backend.
+func NewStorageWithBackend(backend Backend, params Parameters, log *logger.WrappedLogger) *Storage {
+	return &Storage{
 		WrappedLogger:               logger.NewWrappedLogger(log.LoggerNamed("Storage")),
-		client:                      client,
+		backend:                     backend,
 		DefaultBucketName:           params.DefaultBucketName,
 		DefaultBucketExpirationDays: params.DefaultBucketExpirationDays,
-		region:                      params.Region,
-		objectExtension:             params.ObjectExtension,
+		DefaultBucketConfig: BucketConfig{
+			Compression: params.DefaultBucketCompression,
+			Encryption:  params.Encryption.DefaultBucketEncryption,
+			Format:      params.DefaultBucketFormat,
+			Quota: Quota{
+				MaxBytes:       params.DefaultBucketQuota.MaxBytes,
+				MaxObjects:     params.DefaultBucketQuota.MaxObjects,
+				Policy:         params.DefaultBucketQuota.Policy,
+				OverflowBucket: params.DefaultBucketQuota.OverflowBucket,
+			},
+		},
+		ScrubInterval:   params.Scrub.Interval,
+		ScrubRepair:     params.Scrub.Repair,
+		objectExtension: params.ObjectExtension,
+		instanceId:      instanceId(params),
+		bucketConfigs:   make(map[string]cachedBucketConfig),
+		dataKeys:        make(map[string]*bucketKeys),
+		usage:           make(map[string]bucketUsage),
+		bucketQuotas:    make(map[string]*bucketQuota),
 	}
+}
 
-	return storage, nil
+// instanceId returns the id of this collector, the host name if none is configured.
+func instanceId(params Parameters) string {
+	if params.InstanceId != "" {
+		return params.InstanceId
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// Run runs the retries of the spooled uploads and the background routines of the backend, if any, until the context is canceled.
+func (s *Storage) Run(ctx context.Context) {
+	if s.spool != nil {
+		go s.runSpool(ctx)
+	}
+	if r, ok := s.backend.(runner); ok {
+		r.Run(ctx)
+	}
+}
+
+// Close releases the local databases held by the storage.
+func (s *Storage) Close() error {
+	var err error
+	if s.catalog != nil {
+		err = s.catalog.Close()
+	}
+	if s.spool != nil {
+		if spoolErr := s.spool.Close(); spoolErr != nil {
+			err = spoolErr
+		}
+	}
+	return err
+}
+
+// Backend returns the backend the objects are stored in.
+func (s *Storage) Backend() Backend {
+	return s.backend
 }
 
 func (s *Storage) CheckCreateBucket(bucketName string, ctx context.Context) (bool, error) {
@@ -58,7 +166,7 @@ func (s *Storage) CheckCreateBucket(bucketName string, ctx context.Context) (boo
 
 func (s *Storage) CreateBucket(bucketName string, ctx context.Context) error {
 	s.WrappedLogger.LogInfof("Creating bucket '%s' ...", bucketName)
-	err := s.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: s.region})
+	err := s.backend.CreateBucket(bucketName, ctx)
 	if err != nil {
 		s.WrappedLogger.LogErrorf("Creating bucket '%s' ... failed, error: %w", bucketName, err)
 		return err
@@ -68,88 +176,394 @@ func (s *Storage) CreateBucket(bucketName string, ctx context.Context) error {
 	return nil
 }
 
-func (s *Storage) SetBucketExpirationDays(bucketName string, days int, ctx context.Context) error {
-	// days = 0 means that the bucket has no expiration
-	if days == 0 {
-		s.WrappedLogger.LogInfof("No lifecycle for bucket '%s'", bucketName)
-		return nil
+func (s *Storage) BucketExists(bucketName string, ctx context.Context) (bool, error) {
+	exists, err := s.backend.BucketExists(bucketName, ctx)
+	if err == nil && exists {
+		return true, nil
+	} else if err != nil {
+		return false, err
 	}
+	return false, nil
+}
 
-	config := lifecycle.NewConfiguration()
-	config.Rules = []lifecycle.Rule{
-		{
-			ID:     "expire-bucket",
-			Status: "Enabled",
-			Expiration: lifecycle.Expiration{
-				Days: lifecycle.ExpirationDays(days),
-			},
-		},
+// UploadObject stores the object under the key built from the attributes and the key layout of the bucket.
+// The upload is skipped when the bucket already holds an identical object for the block,
+// or when it holds one with a proof of inclusion and the new object has none.
+// When the bucket is full, its quota policy rejects the upload, evicts the oldest objects or stores the object in the overflow bucket.
+func (s *Storage) UploadObject(attributes ObjectAttributes, bucketName string, object Object, ctx context.Context) error {
+	overflowBucket, err := s.uploadObject(attributes, bucketName, object, true, ctx)
+	if err != nil || overflowBucket == "" {
+		return err
 	}
-	err := s.client.SetBucketLifecycle(ctx, bucketName, config)
+	// the overflow bucket doesn't overflow further
+	_, err = s.uploadObject(attributes, overflowBucket, object, false, ctx)
+	return err
+}
+
+// uploadObject stores the object in the bucket, or returns the overflow bucket the object must be stored in instead.
+func (s *Storage) uploadObject(attributes ObjectAttributes, bucketName string, object Object, overflow bool, ctx context.Context) (string, error) {
+	config, err := s.GetBucketConfig(bucketName, ctx)
 	if err != nil {
-		s.WrappedLogger.LogInfof("Failed setting lifecycle for bucket '%s', error: %w", bucketName, err)
+		return "", err
 	}
-	return nil
+
+	layout := config.KeyLayout
+	if attributes.KeyLayout != "" {
+		layout = attributes.KeyLayout
+	}
+	attributes = completeAttributes(attributes, object)
+	objectName := renderKey(layout, attributes)
+
+	data, err := encodeObject(object, config.Format)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := s.contentChecksum(bucketName, config, data, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	unlock := s.uploadLocks.lock(bucketName, attributes.BlockId)
+	defer unlock()
+
+	storedName, stored, err := s.storedObject(bucketName, attributes.BlockId, ctx)
+	if err != nil {
+		return "", err
+	}
+	if reason := skipUpload(stored, hash, object.Proof != nil); reason != "" {
+		s.WrappedLogger.LogDebugf("Skipping upload of object '%s' to bucket '%s', %s", objectName, bucketName, reason)
+		// a block stored by several filters is kept as long as the longest of their retentions
+		filterId, err := s.retainingFilter(bucketName, stored.Metadata[metadataFilterId], attributes.FilterId, ctx)
+		if err != nil || filterId == stored.Metadata[metadataFilterId] {
+			return "", err
+		}
+		return "", s.retainObject(bucketName, storedName, stored, attributes, ctx)
+	}
+	if stored != nil {
+		attributes.FilterId, err = s.retainingFilter(bucketName, stored.Metadata[metadataFilterId], attributes.FilterId, ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	data, opts, err := s.packObject(bucketName, config, attributes, object, data, hash, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// the block was stored under another key layout, a locked previous object is left in place until its protection ends,
+	// as DeleteObject would, and keeps its room
+	movedLocked := stored != nil && storedName != objectName+s.objectExtension && stored.Lock.IsLocked()
+	replaced := stored
+	if movedLocked {
+		replaced = nil
+	}
+
+	quota := config.Quota
+	if !overflow && quota.Policy == QuotaPolicyOverflow {
+		quota.Policy = QuotaPolicyReject
+	}
+	targetBucket, release, err := s.makeRoom(bucketName, quota, int64(len(data)), replaced, ctx)
+	if err != nil {
+		s.WrappedLogger.LogErrorf("Uploading object '%s' to bucket '%s' ... failed, error: %w", objectName, bucketName, err)
+		return "", err
+	}
+	if targetBucket != bucketName {
+		return targetBucket, nil
+	}
+
+	s.WrappedLogger.LogInfof("Uploading object '%s' to bucket '%s' ...", objectName, bucketName)
+	err = s.backend.PutObject(bucketName, objectName+s.objectExtension, bytes.NewReader(data), int64(len(data)), opts, ctx)
+	if err != nil {
+		release()
+		s.WrappedLogger.LogErrorf("Uploading object '%s' to bucket '%s' ... failed, error: %w", objectName, bucketName, err)
+		return "", err
+	}
+
+	// objects stored under a different key than the block id can still be found from the block id through the index
+	if objectName != attributes.BlockId {
+		err = s.writeIndex(bucketName, attributes.BlockId, objectName+s.objectExtension, opts.Tags, ctx)
+		if err != nil {
+			s.WrappedLogger.LogErrorf("Uploading object '%s' to bucket '%s' ... failed, error: %w", objectName, bucketName, err)
+			return "", err
+		}
+	}
+
+	// the block was stored under another key layout, the index now points to the new object
+	if movedLocked {
+		s.WrappedLogger.LogInfof("Keeping locked previous object '%s' in bucket '%s'", storedName, bucketName)
+	}
+	if stored != nil && storedName != objectName+s.objectExtension && !movedLocked {
+		err = s.backend.DeleteObject(bucketName, storedName, ctx)
+		if err != nil {
+			s.WrappedLogger.LogWarnf("Can't delete previous object '%s' from bucket '%s', error: %s", storedName, bucketName, err)
+		}
+		if objectName == attributes.BlockId {
+			err = s.backend.DeleteObject(bucketName, indexKey(attributes.BlockId), ctx)
+			if err != nil {
+				s.WrappedLogger.LogWarnf("Can't delete index of object '%s' from bucket '%s', error: %s", objectName, bucketName, err)
+			}
+		}
+	}
+
+	s.catalogPut(newCatalogEntry(bucketName, objectName+s.objectExtension, attributes, object, int64(len(data)), hash))
+
+	s.WrappedLogger.LogInfof("Uploading object '%s' to bucket '%s' ... done", objectName, bucketName)
+	return "", nil
+}
+
+// packObject compresses and encrypts the serialized object as configured for the bucket,
+// and returns the stored content with the options describing it.
+func (s *Storage) packObject(bucketName string, config BucketConfig, attributes ObjectAttributes, object Object, data []byte, hash string, ctx context.Context) ([]byte, PutOptions, error) {
+	var err error
+	opts := PutOptions{ContentType: "application/json"}
+	opts.Metadata, opts.Tags = s.objectMetadata(attributes, object)
+	opts.Metadata[checksumMetadata(config.Encryption)] = hash
+	if config.Format == FormatBinary {
+		opts.ContentType = "application/octet-stream"
+		opts.Metadata[metadataFormat] = FormatBinary
+	}
+	if config.Compression != CompressionNone {
+		data, err = compress(data, config.Compression)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.ContentType = "application/octet-stream"
+		opts.Metadata[metadataEncoding] = config.Compression
+	}
+	if config.Encryption {
+		aead, err := s.dataKey(bucketName, ctx)
+		if err != nil {
+			return nil, opts, err
+		}
+		data, err = seal(aead, data)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.ContentType = "application/octet-stream"
+		opts.Metadata[metadataEncryption] = EncryptionAESGCM
+	}
+	return data, opts, nil
+}
+
+// GetObject returns a reader on the content of the object holding the block, decrypted and decompressed if needed,
+// together with the format of the content, to be read with DecodeObject.
+func (s *Storage) GetObject(bucketName string, blockId string, ctx context.Context) (io.ReadCloser, string, error) {
+	s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... ", blockId, bucketName)
+	reader, format, err := s.getObject(bucketName, blockId, ctx)
+	if err != nil {
+		s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... failed, error: %w", blockId, bucketName, err)
+		return nil, "", err
+	}
+
+	s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... done", blockId, bucketName)
+	return reader, format, nil
+}
+
+func (s *Storage) getObject(bucketName string, blockId string, ctx context.Context) (io.ReadCloser, string, error) {
+	reader, format, err := s.readObject(bucketName, blockId, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		// the block may have been stored in the overflow bucket while the bucket was full
+		if overflowBucket := s.overflowBucket(bucketName, ctx); overflowBucket != "" {
+			return s.readObject(overflowBucket, blockId, ctx)
+		}
+	}
+	return reader, format, err
 }
 
-func (s *Storage) GetBucketExpirationDays(bucketName string, ctx context.Context) (int, error) {
+func (s *Storage) readObject(bucketName string, blockId string, ctx context.Context) (io.ReadCloser, string, error) {
+	reader, info, err := s.backend.GetObject(bucketName, blockId+s.objectExtension, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		var objectName string
+		objectName, err = s.readIndex(bucketName, blockId, ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		reader, info, err = s.backend.GetObject(bucketName, objectName, ctx)
+		if errors.Is(err, ErrObjectNotFound) {
+			s.dropIndex(bucketName, blockId, ctx)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
 
-	config, err := s.client.GetBucketLifecycle(ctx, bucketName)
+	decoded, err := s.decodeObject(bucketName, reader, info, ctx)
 	if err != nil {
-		s.WrappedLogger.LogInfof("Failed retrieving lifecycle for bucket '%s', error: %w", bucketName, err)
+		return nil, "", err
 	}
-	// days = 0 means that the bucket has no expiration
-	days := 0
-	for _, rule := range config.Rules {
-		if rule.ID == "expire-bucket" && rule.Status == "Enabled" {
-			days = int(rule.Expiration.Days)
-			break
+	format := info.Metadata[metadataFormat]
+	if format == "" {
+		format = FormatJSON
+	}
+	return decoded, format, nil
+}
+
+// decodeObject decrypts and decompresses the content of reader according to the object metadata.
+func (s *Storage) decodeObject(bucketName string, reader io.ReadCloser, info ObjectInfo, ctx context.Context) (io.ReadCloser, error) {
+	var err error
+	switch encryption := info.Metadata[metadataEncryption]; encryption {
+	case "":
+	case EncryptionAESGCM:
+		reader, err = s.openObject(bucketName, reader, ctx)
+		if err != nil {
+			return nil, err
 		}
+	default:
+		reader.Close()
+		return nil, fmt.Errorf("unknown encryption '%s'", encryption)
 	}
 
-	return days, nil
+	decoded, err := decompressReader(reader, info.Metadata[metadataEncoding])
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return decoded, nil
 }
 
-func (s *Storage) BucketExists(bucketName string, ctx context.Context) (bool, error) {
-	exists, err := s.client.BucketExists(ctx, bucketName)
-	if err == nil && exists {
-		return true, nil
-	} else if err != nil {
-		return false, err
+// openObject decrypts the sealed content of reader and closes it.
+func (s *Storage) openObject(bucketName string, reader io.ReadCloser, ctx context.Context) (io.ReadCloser, error) {
+	defer reader.Close()
+
+	aead, err := s.dataKey(bucketName, ctx)
+	if err != nil {
+		return nil, err
 	}
-	return false, nil
+	sealed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := open(aead, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt object, error: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
-func (s *Storage) UploadObject(objectName string, bucketName string, object Object, ctx context.Context) error {
+// DeleteObject removes the object holding the block, together with its index entry, from the bucket or from its overflow bucket.
+func (s *Storage) DeleteObject(bucketName string, blockId string, ctx context.Context) error {
+	bucketName, objectName, err := s.locateObject(bucketName, blockId, ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		// deleting a missing object is not an error
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-	objectReader, err := object.GetByteReader()
+	info, err := s.backend.StatObject(bucketName, objectName, ctx)
 	if err != nil {
 		return err
 	}
+	// in a bucket with object lock S3 would only hide the object behind a delete marker
+	if info.Lock.IsLocked() {
+		return ErrObjectLocked
+	}
 
-	s.WrappedLogger.LogInfof("Uploading object '%s' to bucket '%s' ...", objectName, bucketName)
-	_, err = s.client.PutObject(ctx, bucketName, objectName+s.objectExtension, objectReader, objectReader.Size(), minio.PutObjectOptions{ContentType: "application/json"})
+	err = s.backend.DeleteObject(bucketName, objectName, ctx)
 	if err != nil {
-		s.WrappedLogger.LogErrorf("Uploading object '%s' to bucket '%s' ... failed, error: %w", objectName, bucketName, err)
 		return err
 	}
+	s.updateUsage(bucketName, -1, -info.Size)
+	s.catalogDelete(bucketName, blockId)
 
-	s.WrappedLogger.LogInfof("Uploading object '%s' to bucket '%s' ... done", objectName, bucketName)
+	if objectName != blockId+s.objectExtension {
+		return s.backend.DeleteObject(bucketName, indexKey(blockId), ctx)
+	}
 	return nil
 }
 
-func (s *Storage) GetObject(bucketName string, objectName string, ctx context.Context) (*minio.Object, error) {
-	s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... ", objectName, bucketName)
-	object, err := s.client.GetObject(ctx, bucketName, objectName+s.objectExtension, minio.GetObjectOptions{})
+// ResolveObjectName returns the name of the object holding the block.
+func (s *Storage) ResolveObjectName(bucketName string, blockId string, ctx context.Context) (string, error) {
+	_, err := s.backend.StatObject(bucketName, blockId+s.objectExtension, ctx)
+	if err == nil {
+		return blockId + s.objectExtension, nil
+	}
+	if !errors.Is(err, ErrObjectNotFound) {
+		return "", err
+	}
+	return s.readIndex(bucketName, blockId, ctx)
+}
+
+// ListObjects returns the information about every object stored in the bucket.
+func (s *Storage) ListObjects(bucketName string, ctx context.Context) ([]ObjectInfo, error) {
+	objects, err := s.backend.ListObjects(bucketName, ctx)
 	if err != nil {
-		s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... failed, error: %w", objectName, bucketName, err)
 		return nil, err
 	}
 
-	s.WrappedLogger.LogInfof("Retrieving object '%s' from bucket '%s' ... done", objectName, bucketName)
-	return object, nil
+	// the index entries are not objects of the bucket
+	filtered := objects[:0]
+	for _, object := range objects {
+		if !isReservedKey(object.Key) {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered, nil
+}
+
+// writeIndex points the index entry of the block to the object, the entry carries the tags of the object
+// so that the lifecycle rules matching the tags of the object expire its index entry too.
+func (s *Storage) writeIndex(bucketName string, blockId string, objectName string, tags map[string]string, ctx context.Context) error {
+	opts := PutOptions{ContentType: "text/plain", Tags: tags}
+	return s.backend.PutObject(bucketName, indexKey(blockId), strings.NewReader(objectName), int64(len(objectName)), opts, ctx)
+}
+
+// dropIndex deletes the index entry of the block once its object is found missing, e.g. expired by a lifecycle rule
+// matching the key of the object, which the index entry doesn't share.
+func (s *Storage) dropIndex(bucketName string, blockId string, ctx context.Context) {
+	s.WrappedLogger.LogInfof("Deleting index of missing object '%s' from bucket '%s'", blockId, bucketName)
+	err := s.backend.DeleteObject(bucketName, indexKey(blockId), ctx)
+	if err != nil {
+		s.WrappedLogger.LogWarnf("Can't delete index of object '%s' from bucket '%s', error: %s", blockId, bucketName, err)
+	}
 }
 
-func (s *Storage) DeleteObject(bucketName string, objectName string, ctx context.Context) error {
-	return s.client.RemoveObject(ctx, bucketName, objectName+s.objectExtension, minio.RemoveObjectOptions{})
+func (s *Storage) readIndex(bucketName string, blockId string, ctx context.Context) (string, error) {
+	reader, _, err := s.backend.GetObject(bucketName, indexKey(blockId), ctx)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	objectName, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(objectName), nil
+}
+
+// indexedObjects returns the block ids of the objects stored under a different key than the block id,
+// found through the index entries among the listed objects.
+func (s *Storage) indexedObjects(bucketName string, objects []ObjectInfo, ctx context.Context) (map[string]string, error) {
+	indexed := make(map[string]string)
+	for _, object := range objects {
+		if !isReservedKey(object.Key) {
+			continue
+		}
+		blockId := strings.TrimPrefix(object.Key, indexPrefix)
+		objectName, err := s.readIndex(bucketName, blockId, ctx)
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		indexed[objectName] = blockId
+	}
+	return indexed, nil
+}
+
+// storedBlockId returns the id of the block held by the object: the one recorded in its metadata,
+// or for the objects stored before it was recorded, the one of its index entry or its key.
+func (s *Storage) storedBlockId(objectName string, info ObjectInfo, indexed map[string]string) string {
+	if blockId := info.Metadata[metadataBlockId]; blockId != "" {
+		return blockId
+	}
+	if blockId, ok := indexed[objectName]; ok {
+		return blockId
+	}
+	return strings.TrimSuffix(objectName, s.objectExtension)
 }
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"testing"
+
+	"github.com/iotaledger/hive.go/core/logger"
+	iotago "github.com/iotaledger/iota.go/v3"
+	"github.com/iotaledger/iota.go/v3/merklehasher"
+	_ "golang.org/x/crypto/blake2b"
+)
+
+// newTestStorage returns a storage keeping the objects in memory, with the default bucket created.
+func newTestStorage(t *testing.T, params Parameters) *Storage {
+	t.Helper()
+	return newTestStorageWithBackend(t, NewMemoryBackend(), params)
+}
+
+// newTestStorageWithBackend returns a storage keeping the objects in the backend, with the default bucket created.
+func newTestStorageWithBackend(t *testing.T, backend Backend, params Parameters) *Storage {
+	t.Helper()
+	if params.DefaultBucketName == "" {
+		params.DefaultBucketName = "default"
+	}
+	s := NewStorageWithBackend(backend, params, logger.NewWrappedLogger(logger.NewNopLogger()))
+	if err := s.CreateBucket(params.DefaultBucketName, context.Background()); err != nil {
+		t.Fatalf("can't create bucket, error: %s", err)
+	}
+	return s
+}
+
+// newTestObject returns an object holding a block with a tagged data payload.
+func newTestObject(t *testing.T, tag string, data string) Object {
+	t.Helper()
+	return Object{Block: &iotago.Block{
+		ProtocolVersion: 2,
+		Parents:         iotago.BlockIDs{{1}},
+		Payload:         &iotago.TaggedData{Tag: []byte(tag), Data: []byte(data)},
+	}}
+}
+
+// withTestProof returns the object with a milestone referencing its block and a proof of inclusion of the block.
+func withTestProof(t *testing.T, object Object) Object {
+	t.Helper()
+	blockId := object.Block.MustID()
+	proof, err := merklehasher.NewHasher(crypto.BLAKE2b_256).ComputeProof(iotago.BlockIDs{blockId, {2}, {3}}, blockId)
+	if err != nil {
+		t.Fatalf("can't compute proof, error: %s", err)
+	}
+	object.Milestone = &iotago.Milestone{
+		Index:           42,
+		Timestamp:       1680000042,
+		ProtocolVersion: 2,
+		Parents:         iotago.BlockIDs{blockId},
+		Metadata:        []byte("milestone"),
+	}
+	object.Proof = proof
+	return object
+}
+
+// newTestAttributes returns the attributes of the object as the listener would store it.
+func newTestAttributes(t *testing.T, object Object, milestoneIndex uint32) ObjectAttributes {
+	t.Helper()
+	blockId, err := object.Block.ID()
+	if err != nil {
+		t.Fatalf("can't compute block id, error: %s", err)
+	}
+	tag := object.Block.Payload.(*iotago.TaggedData).Tag
+	return ObjectAttributes{
+		BlockId:            hex.EncodeToString(blockId[:]),
+		Tag:                tag,
+		MilestoneIndex:     milestoneIndex,
+		MilestoneTimestamp: 1680000000 + milestoneIndex,
+	}
+}